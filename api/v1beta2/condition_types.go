@@ -18,6 +18,14 @@ package v1beta2
 
 const ImageFinalizer = "finalizers.fluxcd.io"
 
+const (
+	// StorageThresholdExceededCondition indicates that the records of an
+	// ImageRepository take more room in the tags database than the
+	// threshold set on the controller. It's a warning, which doesn't affect
+	// the readiness of the ImageRepository.
+	StorageThresholdExceededCondition string = "StorageThresholdExceeded"
+)
+
 const (
 	// ImageURLInvalidReason represents the fact that a given repository has an invalid image URL.
 	ImageURLInvalidReason string = "ImageURLInvalid"
@@ -30,6 +38,59 @@ const (
 	// required fields, or the provided credentials do not match.
 	AuthenticationFailedReason string = "AuthenticationFailed"
 
+	// CredentialsExpiredReason signals that the registry rejected credentials
+	// that are known to have expired.
+	CredentialsExpiredReason string = "CredentialsExpired"
+
 	// ReadOperationFailedReason signals a failure caused by a read operation.
 	ReadOperationFailedReason string = "ReadOperationFailed"
+
+	// InsecureRegistryNotAllowedReason signals that an insecure registry is
+	// referenced while insecure registries are disallowed by the controller.
+	InsecureRegistryNotAllowedReason string = "InsecureRegistryNotAllowed"
+
+	// InvalidSchemeReason signals that the scheme of an ImageRepository
+	// can't be used with the rest of its spec.
+	InvalidSchemeReason string = "InvalidScheme"
+
+	// RateLimitedReason signals that the registry rate limited a scan.
+	RateLimitedReason string = "RateLimited"
+
+	// FilterMatchedNothingReason signals that the tag filter of a policy
+	// matched none of the tags of a non-empty repository.
+	FilterMatchedNothingReason string = "FilterMatchedNothing"
+
+	// NotEnoughCandidatesReason signals that fewer tags than the minimum
+	// number of candidates of a policy were left after filtering.
+	NotEnoughCandidatesReason string = "NotEnoughCandidates"
+
+	// PlatformNotFoundReason signals that the platform of a policy is not
+	// in the image index of the latest image.
+	PlatformNotFoundReason string = "PlatformNotFound"
+
+	// DigestUnavailableReason signals that a tag was selected by a policy
+	// but the digest of its manifest could not be resolved from the
+	// registry.
+	DigestUnavailableReason string = "DigestUnavailable"
+
+	// NoMatchingTagReason signals that the policy could not select any of
+	// the tags left after filtering.
+	NoMatchingTagReason string = "NoMatchingTag"
+
+	// TagsTooYoungReason signals that none of the tags of a policy have been
+	// present for longer than its minimum tag age yet.
+	TagsTooYoungReason string = "TagsTooYoung"
+
+	// PinnedReason signals that the latest image of a policy is the tag it
+	// is pinned to.
+	PinnedReason string = "Pinned"
+
+	// PinnedTagNotFoundReason signals that the tag a policy is pinned to is
+	// not in the image repository.
+	PinnedTagNotFoundReason string = "PinnedTagNotFound"
+
+	// RepositoryTooLargeReason signals that the records of an image
+	// repository in the tags database are larger than the threshold set on
+	// the controller.
+	RepositoryTooLargeReason string = "RepositoryTooLarge"
 )
@@ -26,13 +26,54 @@ const ImagePolicyKind = "ImagePolicy"
 // Deprecated: Use ImageFinalizer.
 const ImagePolicyFinalizer = "finalizers.fluxcd.io"
 
+// DryRunAnnotation is the annotation of an ImagePolicy that, when set to
+// "true", makes the controller compute the latest image and report it in the
+// Ready condition and events, without updating the latest image in the
+// status. It allows validating a policy change without triggering the image
+// automation.
+const DryRunAnnotation = "image.toolkit.fluxcd.io/dry-run"
+
+// DebugAnnotation is the annotation of an ImagePolicy that, when set to
+// "true", makes the controller report the candidate tags of the policy in
+// `.status.debug`, to help understand which tags were considered.
+const DebugAnnotation = "image.toolkit.fluxcd.io/debug"
+
+// MaxDebugCandidateTags is the maximum number of candidate tags reported in
+// `.status.debug.candidateTags`.
+const MaxDebugCandidateTags = 20
+
+// ReflectionPolicy describes a policy for if and when to reflect a value from
+// the registry in a field of the status.
+// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+type ReflectionPolicy string
+
+const (
+	// ReflectAlways means that the value is always reflected from the
+	// registry.
+	ReflectAlways ReflectionPolicy = "Always"
+	// ReflectIfNotPresent means that the value is reflected from the
+	// registry only if the field is empty or the latest image changed.
+	ReflectIfNotPresent ReflectionPolicy = "IfNotPresent"
+	// ReflectNever means that the value is never reflected from the
+	// registry and the field is left empty.
+	ReflectNever ReflectionPolicy = "Never"
+)
+
 // ImagePolicySpec defines the parameters for calculating the
 // ImagePolicy.
 type ImagePolicySpec struct {
 	// ImageRepositoryRef points at the object specifying the image
-	// being scanned
-	// +required
-	ImageRepositoryRef meta.NamespacedObjectReference `json:"imageRepositoryRef"`
+	// being scanned. Either ImageRepositoryRef or ImageRepositorySelector
+	// must be set.
+	// +optional
+	ImageRepositoryRef meta.NamespacedObjectReference `json:"imageRepositoryRef,omitempty"`
+	// ImageRepositorySelector selects the ImageRepository specifying the
+	// image being scanned by its labels, among the ImageRepositories in the
+	// namespace of the ImagePolicy. It must match exactly one
+	// ImageRepository. It can't be used along with the name of
+	// ImageRepositoryRef.
+	// +optional
+	ImageRepositorySelector *metav1.LabelSelector `json:"imageRepositorySelector,omitempty"`
 	// Policy gives the particulars of the policy to be followed in
 	// selecting the most recent image
 	// +required
@@ -42,6 +83,46 @@ type ImagePolicySpec struct {
 	// ordered and compared.
 	// +optional
 	FilterTags *TagFilter `json:"filterTags,omitempty"`
+	// MinTagAge is the minimum duration a tag must have been present in the
+	// image repository, since it was first seen by a scan, before it can be
+	// selected.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MinTagAge *metav1.Duration `json:"minTagAge,omitempty"`
+	// MinCandidates is the minimum number of tags that must be left after
+	// the minimum tag age and the tag filter are applied before a tag is
+	// selected. Until then, the policy is not ready and no image is reported.
+	// Defaults to 0, which selects a tag as soon as there is one.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCandidates int `json:"minCandidates,omitempty"`
+	// DigestReflectionPolicy governs the setting of the `.status.latestDigest`
+	// field.
+	//
+	// Never: The digest field is always left empty.
+	//
+	// IfNotPresent: The digest field is set to the digest of the latest image
+	// if the field is empty or the latest image changed.
+	//
+	// Always: The digest field is set to the digest of the latest image on
+	// every reconciliation, to follow tags that are pushed again.
+	// +kubebuilder:default:=Never
+	// +optional
+	DigestReflectionPolicy ReflectionPolicy `json:"digestReflectionPolicy,omitempty"`
+	// Platform is the platform, in the form `os/arch[/variant]`, e.g.
+	// `linux/arm64`, whose manifest digest is reflected when the latest image
+	// is a multi-platform image index. When unset, the digest of the index is
+	// reflected.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]+/[a-z0-9]+(/[a-z0-9]+)?$"
+	// +optional
+	Platform string `json:"platform,omitempty"`
+	// Pin is a tag the latest image is pinned to, e.g. to freeze the policy
+	// on a known-good tag during an incident. When set, the tag is selected
+	// as long as the image repository has it, regardless of the policy and
+	// the tag filter.
+	// +optional
+	Pin string `json:"pin,omitempty"`
 }
 
 // ImagePolicyChoice is a union of all the types of policy that can be
@@ -57,14 +138,92 @@ type ImagePolicyChoice struct {
 	// Numerical set of rules to use for numerical ordering of the tags.
 	// +optional
 	Numerical *NumericalPolicy `json:"numerical,omitempty"`
+	// DateTime set of rules to use for ordering the tags by a date and time
+	// parsed from them.
+	// +optional
+	DateTime *DateTimePolicy `json:"dateTime,omitempty"`
+	// CalVer set of rules to use for ordering the tags by calendar version,
+	// e.g. `2024.10`, comparing the segments of the versions numerically.
+	// +optional
+	CalVer *CalVerPolicy `json:"calver,omitempty"`
+	// Channel selects the latest version of a preset release channel, as a
+	// shorthand for a semver policy combined with a tag prefix.
+	// +optional
+	Channel *ChannelPolicy `json:"channel,omitempty"`
+	// Tag follows a single, typically mutable, tag, e.g. `main`, without
+	// ordering the tags. The digest of the tag is reflected on every
+	// reconciliation, regardless of the digest reflection policy, so that
+	// the latest ref changes when the tag is pushed again.
+	// +optional
+	Tag *TagPolicy `json:"tag,omitempty"`
+	// Size selects the smallest image, from the total size of the
+	// compressed layers of the image of each tag recorded by an
+	// ImageRepository with `.spec.scanSizes` set. The tags of the same size
+	// are ordered by the time they were first seen, the most recent first.
+	// +optional
+	Size *SizePolicy `json:"size,omitempty"`
+	// Tiers ranks the tags in tiers, from the most preferred, before they're
+	// ordered by the policy. The latest tag is selected from the first tier
+	// with a tag selected by the policy, e.g. to only fall back to the
+	// prereleases when there's no stable release. A tag is in the first tier
+	// with a matching glob. The tags matching no tier are ranked last, unless
+	// a tier without globs holds them.
+	// +optional
+	Tiers []TagTier `json:"tiers,omitempty"`
+}
+
+// TagTier specifies a tier of tags ranked by an ImagePolicyChoice.
+type TagTier struct {
+	// Globs are the patterns of the tags of the tier, matched like the glob
+	// of the tag filter, e.g. `*-rc.*`. A tier without globs holds the tags
+	// matching none of the other tiers, e.g. the stable releases.
+	// +optional
+	Globs []string `json:"globs,omitempty"`
 }
 
 // SemVerPolicy specifies a semantic version policy.
 type SemVerPolicy struct {
 	// Range gives a semver range for the image tag; the highest
 	// version within the range that's a tag yields the latest image.
-	// +required
-	Range string `json:"range"`
+	// Either Range or Ranges must be specified.
+	// +optional
+	Range string `json:"range,omitempty"`
+
+	// Ranges gives a list of semver ranges for the image tag; the highest
+	// version within any of the ranges that's a tag yields the latest image.
+	// It can be combined with Range.
+	// +optional
+	Ranges []string `json:"ranges,omitempty"`
+
+	// IncludePrerelease allows prerelease versions to be selected when the
+	// version they are a prerelease of is within the range. When false,
+	// prerelease versions are only selected by a range that references a
+	// prerelease version, e.g. `>=1.2.0-0`.
+	// +optional
+	IncludePrerelease bool `json:"includePrerelease,omitempty"`
+
+	// LockMajor restricts the versions to the major version of the current
+	// latest image, so that a new major version is never selected
+	// automatically. When there's no latest image yet, or its tag isn't a
+	// semantic version, the versions aren't restricted.
+	// +optional
+	LockMajor bool `json:"lockMajor,omitempty"`
+
+	// BuildMetadataAsTiebreak orders the versions that are equal, e.g.
+	// `1.2.3+20240101` and `1.2.3+20240102`, by their build metadata, which
+	// is otherwise ignored as per the semver spec. The metadata is compared
+	// identifier by identifier, numerically when they are numeric and
+	// lexically otherwise.
+	// +optional
+	BuildMetadataAsTiebreak bool `json:"buildMetadataAsTiebreak,omitempty"`
+
+	// Order specifies which end of the range is selected. Given the versions
+	// matching the range, descending order would select the highest version,
+	// and ascending order would select the lowest version.
+	// +kubebuilder:default:="desc"
+	// +kubebuilder:validation:Enum=asc;desc
+	// +optional
+	Order string `json:"order,omitempty"`
 }
 
 // AlphabeticalPolicy specifies a alphabetical ordering policy.
@@ -76,6 +235,15 @@ type AlphabeticalPolicy struct {
 	// +kubebuilder:validation:Enum=asc;desc
 	// +optional
 	Order string `json:"order,omitempty"`
+	// Natural compares the runs of digits in the tags by their numerical
+	// value instead of lexically, so that v2 is ordered before v10.
+	// +optional
+	Natural bool `json:"natural,omitempty"`
+	// CreatedAsTiebreak selects the tag first seen the most recently among
+	// the tags extracted to the same value by the tag filter, instead of an
+	// arbitrary one.
+	// +optional
+	CreatedAsTiebreak bool `json:"createdAsTiebreak,omitempty"`
 }
 
 // NumericalPolicy specifies a numerical ordering policy.
@@ -87,6 +255,111 @@ type NumericalPolicy struct {
 	// +kubebuilder:validation:Enum=asc;desc
 	// +optional
 	Order string `json:"order,omitempty"`
+	// Base specifies the base used to parse the tags as numbers. Base 10
+	// parses decimal numbers, including floating point numbers, and base 16
+	// parses hexadecimal integers, e.g. `1a2f`.
+	// +kubebuilder:default:=10
+	// +kubebuilder:validation:Enum=10;16
+	// +optional
+	Base int `json:"base,omitempty"`
+	// TrimPrefix is removed from the start of each tag before it is parsed,
+	// e.g. `build-` to order tags like `build-1234`. Tags without the prefix
+	// are parsed as they are. The selected tag always keeps its prefix.
+	// +optional
+	TrimPrefix string `json:"trimPrefix,omitempty"`
+	// TrimSuffix is removed from the end of each tag before it is parsed.
+	// Tags without the suffix are parsed as they are. The selected tag always
+	// keeps its suffix.
+	// +optional
+	TrimSuffix string `json:"trimSuffix,omitempty"`
+	// CreatedAsTiebreak orders the tags with the same numerical value, e.g.
+	// `1` and `1.0`, or the tags extracted to the same value by the tag
+	// filter, by the time they were first seen, the most recent first,
+	// instead of arbitrarily.
+	// +optional
+	CreatedAsTiebreak bool `json:"createdAsTiebreak,omitempty"`
+}
+
+// DateTimePolicy specifies an ordering policy based on a date and time
+// parsed from the tags.
+type DateTimePolicy struct {
+	// Layout is the Go time layout used to parse the tags, e.g.
+	// `20060102150405`. It can be combined with the extract of the tag filter
+	// to parse only the part of the tags holding the date and time. The tags
+	// that can't be parsed with the layout are ignored.
+	// +required
+	Layout string `json:"layout"`
+	// Order specifies the sorting order of the tags. Given the dates of the
+	// tags, ascending order would select the most recent date, and descending
+	// order would select the oldest.
+	// +kubebuilder:default:="asc"
+	// +kubebuilder:validation:Enum=asc;desc
+	// +optional
+	Order string `json:"order,omitempty"`
+}
+
+// CalVerPolicy specifies an ordering policy based on calendar versions.
+type CalVerPolicy struct {
+	// Layout is the calendar versioning layout of the tags, e.g. `YYYY.MM`
+	// or `YYYY.0M.0D`, with the segments of https://calver.org: `YYYY`,
+	// `YY`, `0Y`, `MM`, `0M`, `WW`, `0W`, `DD`, `0D`, `MAJOR`, `MINOR` and
+	// `MICRO`. The other characters of the layout are matched literally. The
+	// segments of the tags are compared numerically, from the first one, so
+	// that `2024.10` is more recent than `2024.9`. The tags that don't match
+	// the layout are ignored.
+	// +required
+	Layout string `json:"layout"`
+	// Order specifies the sorting order of the tags. Given the versions of
+	// the tags, ascending order would select the most recent version, and
+	// descending order would select the oldest.
+	// +kubebuilder:default:="asc"
+	// +kubebuilder:validation:Enum=asc;desc
+	// +optional
+	Order string `json:"order,omitempty"`
+}
+
+// ChannelName is the name of a preset release channel.
+// +kubebuilder:validation:Enum=stable;prerelease
+type ChannelName string
+
+const (
+	// ChannelStable selects the highest release version, ignoring
+	// prerelease versions.
+	ChannelStable ChannelName = "stable"
+	// ChannelPrerelease selects the highest version, including prerelease
+	// versions.
+	ChannelPrerelease ChannelName = "prerelease"
+)
+
+// ChannelPolicy specifies a policy selecting the latest version of a preset
+// release channel. It is equivalent to a semver policy, with the tags
+// filtered to the ones starting with the prefix and the prefix extracted
+// before parsing the version.
+type ChannelPolicy struct {
+	// Name of the release channel.
+	// +required
+	Name ChannelName `json:"name"`
+	// Prefix restricts the channel to the tags starting with it, e.g.
+	// `app-`. It is removed from the tags before parsing their version.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// Range gives a semver range restricting the versions of the channel,
+	// e.g. `>=1.0.0`. Defaults to all versions.
+	// +optional
+	Range string `json:"range,omitempty"`
+}
+
+// TagPolicy specifies a policy following the digest of a single tag.
+type TagPolicy struct {
+	// Name of the tag, e.g. `main`.
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Name string `json:"name"`
+}
+
+// SizePolicy specifies a policy selecting the smallest image. The tags whose
+// size is not recorded are ignored.
+type SizePolicy struct {
 }
 
 // TagFilter enables filtering tags based on a set of defined rules
@@ -95,10 +368,46 @@ type TagFilter struct {
 	// tags.
 	// +optional
 	Pattern string `json:"pattern"`
+	// Glob specifies a glob pattern used to filter for image tags, as a
+	// simpler alternative to Pattern, e.g. `v*` or `release-*`. It must match
+	// the whole tag. `*` matches any sequence of characters, `?` matches any
+	// single character and `[...]` matches a character class. Only one of
+	// Pattern and Glob can be set, and Glob can't be used with Extract.
+	// +optional
+	Glob string `json:"glob,omitempty"`
 	// Extract allows a capture group to be extracted from the specified regular
 	// expression pattern, useful before tag evaluation.
 	// +optional
 	Extract string `json:"extract"`
+	// ExtractPadding is the width to which the numbers in the values built
+	// by Extract are left-padded with zeros, so that a composite value like
+	// `$minor.$build` is ordered numerically by the alphabetical policy.
+	// Numbers longer than the width are kept as they are. Zero, the default,
+	// disables the padding.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=32
+	// +optional
+	ExtractPadding int `json:"extractPadding,omitempty"`
+	// Exclude specifies a regular expression pattern used to exclude image
+	// tags matching the pattern. It's applied to the original tags, after
+	// the Pattern.
+	// +optional
+	Exclude string `json:"exclude,omitempty"`
+	// CaseInsensitive makes the Pattern and Exclude regular expressions
+	// match the tags regardless of their case.
+	// +optional
+	CaseInsensitive bool `json:"caseInsensitive,omitempty"`
+	// MaxAge is the maximum duration a tag can have been present in the
+	// image repository, since it was first seen by a scan, to be selected.
+	// Older tags are dropped before the policy is applied.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// DropUnknownAge drops the tags without a first seen time when MaxAge is
+	// set. By default, they're kept.
+	// +optional
+	DropUnknownAge bool `json:"dropUnknownAge,omitempty"`
 }
 
 // ImagePolicyStatus defines the observed state of ImagePolicy
@@ -107,16 +416,99 @@ type ImagePolicyStatus struct {
 	// the image repository, when filtered and ordered according to
 	// the policy.
 	LatestImage string `json:"latestImage,omitempty"`
+	// LatestDigest is the digest of the manifest of the LatestImage, as
+	// resolved according to the digest reflection policy.
+	// +optional
+	LatestDigest string `json:"latestDigest,omitempty"`
+	// LatestRef is the fully qualified reference of the LatestImage, with the
+	// canonical name of the image repository, the tag and, when reflected,
+	// the digest.
+	// +optional
+	LatestRef *ImageRef `json:"latestRef,omitempty"`
+	// LatestImageFirstSeen is the time the tag of the LatestImage was first
+	// seen by a scan of the image repository. It tells how old the
+	// LatestImage is, e.g. to detect that the repository stopped getting new
+	// tags.
+	// +optional
+	LatestImageFirstSeen *metav1.Time `json:"latestImageFirstSeen,omitempty"`
 	// ObservedPreviousImage is the observed previous LatestImage. It is used
 	// to keep track of the previous and current images.
 	// +optional
 	ObservedPreviousImage string `json:"observedPreviousImage,omitempty"`
+	// ImageHistory is the list of the last resolved LatestImages, with the
+	// most recent first. Its length is capped by the controller.
+	// +optional
+	ImageHistory []ImageHistoryEntry `json:"imageHistory,omitempty"`
+	// EffectivePolicy is the policy applied in the last reconciliation, after
+	// defaulting and resolving the policy choice.
+	// +optional
+	EffectivePolicy *ImagePolicyChoice `json:"effectivePolicy,omitempty"`
+	// TotalTagCount is the number of tags of the image repository that were
+	// read in the last reconciliation, before the minimum tag age and the tag
+	// filter were applied.
+	// +optional
+	TotalTagCount int `json:"totalTagCount,omitempty"`
+	// TagCount is the number of tags that were passed to the policy in the
+	// last reconciliation, after the minimum tag age and the tag filter were
+	// applied.
+	// +optional
+	TagCount int `json:"tagCount,omitempty"`
+	// Debug holds details of the last reconciliation meant for debugging.
+	// It's only reported when the ImagePolicy has the debug annotation set
+	// to "true".
+	// +optional
+	Debug *ImagePolicyDebug `json:"debug,omitempty"`
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ImagePolicyDebug holds details of a reconciliation of an ImagePolicy meant
+// for debugging.
+type ImagePolicyDebug struct {
+	// CandidateTags are the tags that were passed to the policy in the last
+	// reconciliation, ordered from the latest by the policy, and truncated
+	// to the first 20 tags.
+	// +optional
+	CandidateTags []string `json:"candidateTags,omitempty"`
+}
+
+// ImageRef is a fully qualified reference to an image.
+type ImageRef struct {
+	// Name is the canonical, registry-qualified name of the image, e.g.
+	// `index.docker.io/library/nginx`.
+	// +required
+	Name string `json:"name"`
+	// Tag is the tag of the image.
+	// +required
+	Tag string `json:"tag"`
+	// Digest is the digest of the manifest of the image.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// String returns the reference in the `<name>:<tag>` form, or in the
+// `<name>:<tag>@<digest>` form when the digest is set.
+func (r ImageRef) String() string {
+	ref := r.Name + ":" + r.Tag
+	if r.Digest != "" {
+		ref += "@" + r.Digest
+	}
+	return ref
+}
+
+// ImageHistoryEntry records an image that was resolved as the LatestImage.
+type ImageHistoryEntry struct {
+	// Image is the resolved image.
+	// +required
+	Image string `json:"image"`
+	// ResolvedAt is the time the image was first resolved as the
+	// LatestImage.
+	// +required
+	ResolvedAt metav1.Time `json:"resolvedAt"`
+}
+
 // GetConditions returns the status conditions of the object.
 func (p ImagePolicy) GetConditions() []metav1.Condition {
 	return p.Status.Conditions
@@ -127,6 +519,31 @@ func (p *ImagePolicy) SetConditions(conditions []metav1.Condition) {
 	p.Status.Conditions = conditions
 }
 
+// IsDryRun returns whether the ImagePolicy has the dry-run annotation set to
+// "true".
+func (p ImagePolicy) IsDryRun() bool {
+	return p.GetAnnotations()[DryRunAnnotation] == "true"
+}
+
+// IsDebug returns whether the ImagePolicy has the debug annotation set to
+// "true".
+func (p ImagePolicy) IsDebug() bool {
+	return p.GetAnnotations()[DebugAnnotation] == "true"
+}
+
+// GetDigestReflectionPolicy returns the digest reflection policy with default.
+// A tag policy always reflects the digest, as following the digest is its
+// purpose.
+func (p ImagePolicy) GetDigestReflectionPolicy() ReflectionPolicy {
+	if p.Spec.Policy.Tag != nil {
+		return ReflectAlways
+	}
+	if p.Spec.DigestReflectionPolicy == "" {
+		return ReflectNever
+	}
+	return p.Spec.DigestReflectionPolicy
+}
+
 // +kubebuilder:storageversion
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
@@ -30,17 +30,49 @@ const ImageRepositoryKind = "ImageRepository"
 // Deprecated: Use ImageFinalizer.
 const ImageRepositoryFinalizer = "finalizers.fluxcd.io"
 
+const (
+	// AuthPolicyEager retrieves the credentials before scanning and uses them
+	// for every scan.
+	AuthPolicyEager = "Eager"
+	// AuthPolicyPreferAnonymous scans anonymously first and only retrieves
+	// the credentials when the registry rejects the anonymous access.
+	AuthPolicyPreferAnonymous = "PreferAnonymous"
+)
+
+const (
+	// SchemeHTTPS connects to the registry over HTTPS only.
+	SchemeHTTPS = "https"
+	// SchemeHTTP connects to the registry over plain HTTP only.
+	SchemeHTTP = "http"
+)
+
+// CredentialsExpiresAtAnnotation is the annotation of the Secret referenced
+// by an ImageRepository that gives the time, in RFC3339 format, at which the
+// credentials it holds expire, e.g. the expiry of a Harbor robot account.
+// It's used to report expired credentials distinctly when the registry rejects
+// them.
+const CredentialsExpiresAtAnnotation = "image.toolkit.fluxcd.io/credentials-expires-at"
+
+// AzureClientIDAnnotation is the annotation of the ServiceAccount referenced
+// by an ImageRepository holding the client ID of the user-assigned managed
+// identity to log in to Azure Container Registry with, when the provider is
+// 'azure'. Without it, the default identity of the controller is used.
+const AzureClientIDAnnotation = "image.toolkit.fluxcd.io/azure-client-id"
+
 // ImageRepositorySpec defines the parameters for scanning an image
 // repository, e.g., `fluxcd/flux`.
 type ImageRepositorySpec struct {
-	// Image is the name of the image repository
+	// Image is the name of the image repository. It can reference the
+	// variables set with the `--image-variables` controller flag, e.g.
+	// `${REGISTRY_HOST}/org/app`.
 	// +required
 	Image string `json:"image,omitempty"`
 	// Interval is the length of time to wait between
-	// scans of the image repository.
+	// scans of the image repository. Defaults to the value of the
+	// `--default-scan-interval` controller flag.
 	// +kubebuilder:validation:Type=string
 	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
-	// +required
+	// +optional
 	Interval metav1.Duration `json:"interval,omitempty"`
 
 	// Timeout for image scanning.
@@ -50,6 +82,16 @@ type ImageRepositorySpec struct {
 	// +optional
 	Timeout *metav1.Duration `json:"timeout,omitempty"`
 
+	// ScanTimeout for the requests to the registry made while scanning, like
+	// listing the tags and looking up their metadata. It allows tolerating a
+	// slow registry while keeping a shorter Timeout for the rest of the
+	// reconciliation.
+	// Defaults to 'Timeout'.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m))+$"
+	// +optional
+	ScanTimeout *metav1.Duration `json:"scanTimeout,omitempty"`
+
 	// SecretRef can be given the name of a secret containing
 	// credentials to use for the image registry. The secret should be
 	// created with `kubectl create secret docker-registry`, or the
@@ -58,7 +100,11 @@ type ImageRepositorySpec struct {
 	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
 
 	// ServiceAccountName is the name of the Kubernetes ServiceAccount used to authenticate
-	// the image pull if the service account has attached pull secrets.
+	// the image pull if the service account has attached pull secrets. With
+	// the 'aws' provider, the IAM role annotated on the service account is
+	// assumed to log in to ECR. With the 'gcp' provider, the Google service
+	// account bound to the service account is used. With the 'azure'
+	// provider, the managed identity annotated on the service account is used.
 	// +kubebuilder:validation:MaxLength=253
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
@@ -98,22 +144,152 @@ type ImageRepositorySpec struct {
 	// +optional
 	ExclusionList []string `json:"exclusionList,omitempty"`
 
-	// The provider used for authentication, can be 'aws', 'azure', 'gcp' or 'generic'.
+	// ScanLimit is the maximum number of tags stored per scan, after the
+	// exclusion list is applied. The tags are kept in the order returned by
+	// the registry. Zero, the default, means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ScanLimit int `json:"scanLimit,omitempty"`
+
+	// Tags is the set of tags to scan instead of listing all the tags of the
+	// repository. Only the tags that exist in the repository are stored,
+	// the others are reported without failing the scan.
+	// +listType=set
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// The provider used for authentication, can be 'aws', 'azure', 'gcp',
+	// 'github' or 'generic'. The 'github' provider mints GitHub App
+	// installation tokens for ghcr.io from the SecretRef.
 	// When not specified, defaults to 'generic'.
-	// +kubebuilder:validation:Enum=generic;aws;azure;gcp
+	// +kubebuilder:validation:Enum=generic;aws;azure;gcp;github
 	// +kubebuilder:default:=generic
 	// +optional
 	Provider string `json:"provider,omitempty"`
 
 	// Insecure allows connecting to a non-TLS HTTP container registry.
+	// The traffic to the registry, including any credentials, is sent
+	// unencrypted and can be read or tampered with by anyone on the network
+	// path, so it should only be used for registries on a trusted network.
+	// Insecure registries can be disallowed for the whole controller with the
+	// `--no-insecure-registries` flag.
 	// +optional
 	Insecure bool `json:"insecure,omitempty"`
+
+	// Scheme is the protocol used to connect to the registry, either 'https'
+	// or 'http', skipping the detection of the protocol supported by the
+	// registry. The 'http' scheme requires Insecure to be set. When not
+	// specified, the protocol is detected, falling back to HTTP for insecure
+	// registries.
+	// +kubebuilder:validation:Enum=https;http
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+
+	// AuthPolicy determines when the credentials from the SecretRef or the
+	// Provider are used. With 'Eager', the credentials are retrieved before
+	// every scan. With 'PreferAnonymous', the repository is scanned
+	// anonymously first, and the credentials are only retrieved when the
+	// registry responds with 401 or 403. When not specified, defaults to
+	// 'Eager'.
+	// +kubebuilder:validation:Enum=Eager;PreferAnonymous
+	// +kubebuilder:default:=Eager
+	// +optional
+	AuthPolicy string `json:"authPolicy,omitempty"`
+
+	// Mirrors is a list of registry hosts, e.g. `mirror.example.com:5000`,
+	// serving the same repository as the registry of the image. When the
+	// registry can't be reached or responds with a server error, the tags
+	// are scanned from each of the mirrors in order. The authentication
+	// options are set up for each mirror host.
+	// +optional
+	Mirrors []string `json:"mirrors,omitempty"`
+
+	// ScanReferrers enables looking up the artifacts referring to the images
+	// of the latest tags, such as signatures and SBOMs, on every scan. The
+	// referrers are reported in the status. This makes two additional
+	// requests to the registry per latest tag.
+	// +optional
+	ScanReferrers bool `json:"scanReferrers,omitempty"`
+
+	// ArtifactTypes restricts the scanned tags to the ones whose manifest has
+	// one of the given artifact types, or config media types when it has no
+	// artifact type, e.g. `application/vnd.oci.image.config.v1+json` to leave
+	// out the Helm charts stored in the repository. The tags of image indexes
+	// are matched on the artifact type of the index, or on the media type of
+	// the index itself. This makes an additional request to the registry per
+	// tag. When not specified, all the tags are scanned.
+	// +listType=set
+	// +optional
+	ArtifactTypes []string `json:"artifactTypes,omitempty"`
+
+	// ScanSizes enables recording the total size of the compressed layers
+	// of the image of each tag, for the ImagePolicies selecting the smallest
+	// image. The size of an image index is the size of its largest image.
+	// The size of a tag is looked up once, in the first scan finding it,
+	// which makes one additional request to the registry per new tag, plus
+	// one per image of an index.
+	// +optional
+	ScanSizes bool `json:"scanSizes,omitempty"`
 }
 
 type ScanResult struct {
 	TagCount   int         `json:"tagCount"`
 	ScanTime   metav1.Time `json:"scanTime,omitempty"`
 	LatestTags []string    `json:"latestTags,omitempty"`
+	// Truncated is true when the scanned tags exceeded the scan limit and
+	// only the first tags up to the limit were stored.
+	Truncated bool `json:"truncated,omitempty"`
+	// Registry is the host of the registry, or of the mirror, that served
+	// the scan.
+	// +optional
+	Registry string `json:"registry,omitempty"`
+	// Referrers lists the artifacts referring to the images of the latest
+	// tags, when referrers scanning is enabled.
+	// +optional
+	Referrers []TagReferrers `json:"referrers,omitempty"`
+	// TagsDigest is the SHA-256 digest of the list of tags stored by the
+	// scan, used to detect that the tags are unchanged in the next scan.
+	// +optional
+	TagsDigest string `json:"tagsDigest,omitempty"`
+	// Unchanged is true when the scan found the same list of tags as the
+	// previous scan. The referrers of the latest tags are then not looked up
+	// again.
+	// +optional
+	Unchanged bool `json:"unchanged,omitempty"`
+	// ListedTagCount is the number of tags listed by the registry, before
+	// the exclusion list and the scan limit were applied.
+	// +optional
+	ListedTagCount int `json:"listedTagCount,omitempty"`
+	// FetchedTagCount is the number of tags whose metadata, i.e. the
+	// referrers of the latest tags and the sizes of the new tags, was
+	// fetched successfully by the scan, counted once per kind of metadata.
+	// The referrers kept from the previous scan aren't counted.
+	// +optional
+	FetchedTagCount int `json:"fetchedTagCount,omitempty"`
+	// FailedTagCount is the number of tags whose metadata failed to be
+	// fetched, which were skipped.
+	// +optional
+	FailedTagCount int `json:"failedTagCount,omitempty"`
+	// LastTagError is a sample of the errors of the tags whose metadata
+	// failed to be fetched, truncated to 256 characters.
+	// +optional
+	LastTagError string `json:"lastTagError,omitempty"`
+}
+
+// TagReferrers lists the artifacts referring to the image of a tag, such as
+// signatures and SBOMs.
+type TagReferrers struct {
+	// Tag is the tag of the image.
+	Tag string `json:"tag"`
+	// Digest is the digest of the image the tag pointed to when scanned.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// Count is the number of artifacts referring to the image.
+	Count int `json:"count"`
+	// ArtifactTypes are the distinct artifact types of the referrers, e.g.
+	// `application/vnd.dev.cosign.artifact.sig.v1+json`.
+	// +optional
+	ArtifactTypes []string `json:"artifactTypes,omitempty"`
 }
 
 // ImageRepositoryStatus defines the observed state of ImageRepository
@@ -140,6 +316,12 @@ type ImageRepositoryStatus struct {
 	// spec.lastScanResult.
 	ObservedExclusionList []string `json:"observedExclusionList,omitempty"`
 
+	// LastScanHTTPStatusCode is the HTTP status code of the registry response
+	// that caused the last scan to fail. It is unset when the last scan
+	// succeeded, or failed without a response from the registry.
+	// +optional
+	LastScanHTTPStatusCode int `json:"lastScanHTTPStatusCode,omitempty"`
+
 	meta.ReconcileRequestStatus `json:",inline"`
 }
 
@@ -155,6 +337,17 @@ func (in ImageRepository) GetTimeout() time.Duration {
 	return duration
 }
 
+// GetScanTimeout returns the scan timeout with default.
+func (in ImageRepository) GetScanTimeout() time.Duration {
+	if in.Spec.ScanTimeout == nil {
+		return in.GetTimeout()
+	}
+	if in.Spec.ScanTimeout.Duration < time.Second {
+		return time.Second
+	}
+	return in.Spec.ScanTimeout.Duration
+}
+
 // GetExclusionList returns the exclusion list with default.
 func (in ImageRepository) GetExclusionList() []string {
 	el := []string{"^.*\\.sig$"}
@@ -173,6 +366,15 @@ func (in ImageRepository) GetProvider() string {
 	return p
 }
 
+// GetAuthPolicy returns the auth policy with default.
+func (in ImageRepository) GetAuthPolicy() string {
+	p := AuthPolicyEager
+	if in.Spec.AuthPolicy != "" {
+		p = in.Spec.AuthPolicy
+	}
+	return p
+}
+
 // GetConditions returns the status conditions of the object.
 func (in ImageRepository) GetConditions() []metav1.Condition {
 	return in.Status.Conditions
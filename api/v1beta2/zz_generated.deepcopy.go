@@ -43,6 +43,67 @@ func (in *AlphabeticalPolicy) DeepCopy() *AlphabeticalPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CalVerPolicy) DeepCopyInto(out *CalVerPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CalVerPolicy.
+func (in *CalVerPolicy) DeepCopy() *CalVerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CalVerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChannelPolicy) DeepCopyInto(out *ChannelPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChannelPolicy.
+func (in *ChannelPolicy) DeepCopy() *ChannelPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChannelPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DateTimePolicy) DeepCopyInto(out *DateTimePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DateTimePolicy.
+func (in *DateTimePolicy) DeepCopy() *DateTimePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DateTimePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageHistoryEntry) DeepCopyInto(out *ImageHistoryEntry) {
+	*out = *in
+	in.ResolvedAt.DeepCopyInto(&out.ResolvedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageHistoryEntry.
+func (in *ImageHistoryEntry) DeepCopy() *ImageHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePolicy) DeepCopyInto(out *ImagePolicy) {
 	*out = *in
@@ -76,7 +137,7 @@ func (in *ImagePolicyChoice) DeepCopyInto(out *ImagePolicyChoice) {
 	if in.SemVer != nil {
 		in, out := &in.SemVer, &out.SemVer
 		*out = new(SemVerPolicy)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Alphabetical != nil {
 		in, out := &in.Alphabetical, &out.Alphabetical
@@ -88,6 +149,38 @@ func (in *ImagePolicyChoice) DeepCopyInto(out *ImagePolicyChoice) {
 		*out = new(NumericalPolicy)
 		**out = **in
 	}
+	if in.DateTime != nil {
+		in, out := &in.DateTime, &out.DateTime
+		*out = new(DateTimePolicy)
+		**out = **in
+	}
+	if in.CalVer != nil {
+		in, out := &in.CalVer, &out.CalVer
+		*out = new(CalVerPolicy)
+		**out = **in
+	}
+	if in.Channel != nil {
+		in, out := &in.Channel, &out.Channel
+		*out = new(ChannelPolicy)
+		**out = **in
+	}
+	if in.Tag != nil {
+		in, out := &in.Tag, &out.Tag
+		*out = new(TagPolicy)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(SizePolicy)
+		**out = **in
+	}
+	if in.Tiers != nil {
+		in, out := &in.Tiers, &out.Tiers
+		*out = make([]TagTier, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyChoice.
@@ -100,6 +193,26 @@ func (in *ImagePolicyChoice) DeepCopy() *ImagePolicyChoice {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePolicyDebug) DeepCopyInto(out *ImagePolicyDebug) {
+	*out = *in
+	if in.CandidateTags != nil {
+		in, out := &in.CandidateTags, &out.CandidateTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyDebug.
+func (in *ImagePolicyDebug) DeepCopy() *ImagePolicyDebug {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePolicyDebug)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePolicyList) DeepCopyInto(out *ImagePolicyList) {
 	*out = *in
@@ -136,10 +249,20 @@ func (in *ImagePolicyList) DeepCopyObject() runtime.Object {
 func (in *ImagePolicySpec) DeepCopyInto(out *ImagePolicySpec) {
 	*out = *in
 	out.ImageRepositoryRef = in.ImageRepositoryRef
+	if in.ImageRepositorySelector != nil {
+		in, out := &in.ImageRepositorySelector, &out.ImageRepositorySelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Policy.DeepCopyInto(&out.Policy)
 	if in.FilterTags != nil {
 		in, out := &in.FilterTags, &out.FilterTags
 		*out = new(TagFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MinTagAge != nil {
+		in, out := &in.MinTagAge, &out.MinTagAge
+		*out = new(v1.Duration)
 		**out = **in
 	}
 }
@@ -157,6 +280,32 @@ func (in *ImagePolicySpec) DeepCopy() *ImagePolicySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePolicyStatus) DeepCopyInto(out *ImagePolicyStatus) {
 	*out = *in
+	if in.LatestRef != nil {
+		in, out := &in.LatestRef, &out.LatestRef
+		*out = new(ImageRef)
+		**out = **in
+	}
+	if in.LatestImageFirstSeen != nil {
+		in, out := &in.LatestImageFirstSeen, &out.LatestImageFirstSeen
+		*out = (*in).DeepCopy()
+	}
+	if in.ImageHistory != nil {
+		in, out := &in.ImageHistory, &out.ImageHistory
+		*out = make([]ImageHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EffectivePolicy != nil {
+		in, out := &in.EffectivePolicy, &out.EffectivePolicy
+		*out = new(ImagePolicyChoice)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Debug != nil {
+		in, out := &in.Debug, &out.Debug
+		*out = new(ImagePolicyDebug)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -176,6 +325,21 @@ func (in *ImagePolicyStatus) DeepCopy() *ImagePolicyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRef) DeepCopyInto(out *ImageRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRef.
+func (in *ImageRef) DeepCopy() *ImageRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageRepository) DeepCopyInto(out *ImageRepository) {
 	*out = *in
@@ -244,6 +408,11 @@ func (in *ImageRepositorySpec) DeepCopyInto(out *ImageRepositorySpec) {
 		*out = new(v1.Duration)
 		**out = **in
 	}
+	if in.ScanTimeout != nil {
+		in, out := &in.ScanTimeout, &out.ScanTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	if in.SecretRef != nil {
 		in, out := &in.SecretRef, &out.SecretRef
 		*out = new(meta.LocalObjectReference)
@@ -264,6 +433,21 @@ func (in *ImageRepositorySpec) DeepCopyInto(out *ImageRepositorySpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ArtifactTypes != nil {
+		in, out := &in.ArtifactTypes, &out.ArtifactTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRepositorySpec.
@@ -333,6 +517,13 @@ func (in *ScanResult) DeepCopyInto(out *ScanResult) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Referrers != nil {
+		in, out := &in.Referrers, &out.Referrers
+		*out = make([]TagReferrers, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanResult.
@@ -348,6 +539,11 @@ func (in *ScanResult) DeepCopy() *ScanResult {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SemVerPolicy) DeepCopyInto(out *SemVerPolicy) {
 	*out = *in
+	if in.Ranges != nil {
+		in, out := &in.Ranges, &out.Ranges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SemVerPolicy.
@@ -360,9 +556,29 @@ func (in *SemVerPolicy) DeepCopy() *SemVerPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SizePolicy) DeepCopyInto(out *SizePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SizePolicy.
+func (in *SizePolicy) DeepCopy() *SizePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SizePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TagFilter) DeepCopyInto(out *TagFilter) {
 	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagFilter.
@@ -374,3 +590,58 @@ func (in *TagFilter) DeepCopy() *TagFilter {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TagPolicy) DeepCopyInto(out *TagPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagPolicy.
+func (in *TagPolicy) DeepCopy() *TagPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TagPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TagReferrers) DeepCopyInto(out *TagReferrers) {
+	*out = *in
+	if in.ArtifactTypes != nil {
+		in, out := &in.ArtifactTypes, &out.ArtifactTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagReferrers.
+func (in *TagReferrers) DeepCopy() *TagReferrers {
+	if in == nil {
+		return nil
+	}
+	out := new(TagReferrers)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TagTier) DeepCopyInto(out *TagTier) {
+	*out = *in
+	if in.Globs != nil {
+		in, out := &in.Globs, &out.Globs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagTier.
+func (in *TagTier) DeepCopy() *TagTier {
+	if in == nil {
+		return nil
+	}
+	out := new(TagTier)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+)
+
+// DatabasePath is the path of the endpoint exporting the tags database on
+// GET and importing it on PUT.
+const DatabasePath = "/database"
+
+// shutdownTimeout is the time given to the requests in progress to complete
+// when the server stops.
+const shutdownTimeout = 10 * time.Second
+
+// Server serves the admin API of the controller, to export the tags database
+// as JSON and import it back. The API has no authentication, so it should
+// only listen on a local address, reached with a port forward.
+type Server struct {
+	// Addr is the address the server listens on.
+	Addr string
+	// Database is the tags database exported and imported by the API.
+	Database database.Dumper
+}
+
+// Start serves the admin API until the context is done. It implements
+// manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("admin")
+
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on the admin address %q: %w", s.Addr, err)
+	}
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+	log.Info("serving the admin API", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection returns false, as every replica of the controller has
+// its own database. It implements manager.LeaderElectionRunnable.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the handler of the admin API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DatabasePath, s.handleDatabase)
+	return mux
+}
+
+// handleDatabase exports the database on GET and imports the request body on
+// PUT.
+func (s *Server) handleDatabase(w http.ResponseWriter, req *http.Request) {
+	log := ctrl.LoggerFrom(req.Context()).WithName("admin")
+
+	switch req.Method {
+	case http.MethodGet:
+		// The dump is buffered, so that a failed export is reported with
+		// its status rather than as a truncated document.
+		var buf bytes.Buffer
+		if err := s.Database.Export(&buf); err != nil {
+			log.Error(err, "failed to export the database")
+			http.Error(w, fmt.Sprintf("failed to export the database: %s", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = buf.WriteTo(w)
+	case http.MethodPut:
+		if err := s.Database.Import(req.Body); err != nil {
+			log.Error(err, "failed to import the database")
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrInvalidDump) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, fmt.Sprintf("failed to import the database: %s", err), status)
+			return
+		}
+		log.Info("imported the database")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
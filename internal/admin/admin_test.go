@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+)
+
+// fakeDumper exports its dump and records the imported one.
+type fakeDumper struct {
+	dump      string
+	imported  string
+	exportErr error
+	importErr error
+}
+
+func (d *fakeDumper) Export(w io.Writer) error {
+	if d.exportErr != nil {
+		return d.exportErr
+	}
+	_, err := io.WriteString(w, d.dump)
+	return err
+}
+
+func (d *fakeDumper) Import(r io.Reader) error {
+	if d.importErr != nil {
+		return d.importErr
+	}
+	b, err := io.ReadAll(r)
+	d.imported = string(b)
+	return err
+}
+
+func TestServer_Handler(t *testing.T) {
+	const dump = `{"version":1,"repos":[{"name":"ghcr.io/org/app","tags":["1.0.0"]}]}`
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		db         *fakeDumper
+		wantStatus int
+		wantBody   string
+		wantImport string
+	}{
+		{
+			name:       "export",
+			method:     http.MethodGet,
+			db:         &fakeDumper{dump: dump},
+			wantStatus: http.StatusOK,
+			wantBody:   dump,
+		},
+		{
+			name:       "failed export",
+			method:     http.MethodGet,
+			db:         &fakeDumper{exportErr: errors.New("closed")},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "failed to export the database: closed",
+		},
+		{
+			name:       "import",
+			method:     http.MethodPut,
+			body:       dump,
+			db:         &fakeDumper{},
+			wantStatus: http.StatusNoContent,
+			wantImport: dump,
+		},
+		{
+			name:       "invalid import",
+			method:     http.MethodPut,
+			db:         &fakeDumper{importErr: fmt.Errorf("%w: unsupported dump version 2", database.ErrInvalidDump)},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "failed to import the database: invalid dump: unsupported dump version 2",
+		},
+		{
+			name:       "failed import",
+			method:     http.MethodPut,
+			db:         &fakeDumper{importErr: errors.New("closed")},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "failed to import the database: closed",
+		},
+		{
+			name:       "unsupported method",
+			method:     http.MethodPost,
+			db:         &fakeDumper{},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			s := &Server{Database: tt.db}
+			req := httptest.NewRequest(tt.method, DatabasePath, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+
+			g.Expect(rec.Code).To(Equal(tt.wantStatus))
+			if tt.wantBody != "" {
+				g.Expect(strings.TrimSpace(rec.Body.String())).To(Equal(tt.wantBody))
+			}
+			g.Expect(tt.db.imported).To(Equal(tt.wantImport))
+		})
+	}
+}
+
+func TestServer_Start(t *testing.T) {
+	g := NewWithT(t)
+
+	s := &Server{Addr: "127.0.0.1:0", Database: &fakeDumper{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	cancel()
+	g.Eventually(errs, 5*time.Second).Should(Receive(BeNil()))
+
+	// An address that can't be listened on fails to start.
+	s.Addr = "invalid-address"
+	g.Expect(s.Start(context.Background())).To(MatchError(ContainSubstring("failed to listen on the admin address")))
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"github.com/fluxcd/pkg/oci"
+)
+
+// providerAuthTTL returns how long an authenticator obtained by logging in
+// with the given provider is cached. It is shorter than the lifetime of the
+// tokens of the provider, so that a cached token doesn't expire during a
+// scan.
+func providerAuthTTL(provider oci.Provider) time.Duration {
+	switch provider {
+	case oci.ProviderAWS:
+		// ECR authorization tokens are valid for 12 hours.
+		return 11 * time.Hour
+	case oci.ProviderAzure:
+		// ACR refresh tokens are valid for 3 hours.
+		return 2*time.Hour + 30*time.Minute
+	default:
+		// GCP access tokens are valid for 1 hour.
+		return 45 * time.Minute
+	}
+}
+
+// authCacheEntry is an authenticator cached for an ImageRepository, along
+// with the image and the provider it was obtained for.
+type authCacheEntry struct {
+	image     string
+	provider  oci.Provider
+	auth      authn.Authenticator
+	expiresAt time.Time
+}
+
+// authCache caches the authenticators obtained by logging in with a cloud
+// provider per ImageRepository, to avoid minting a new token on every scan.
+// A nil authCache caches nothing.
+type authCache struct {
+	mu      sync.Mutex
+	entries map[string]authCacheEntry
+	now     func() time.Time
+}
+
+func newAuthCache() *authCache {
+	return &authCache{
+		entries: make(map[string]authCacheEntry),
+		now:     time.Now,
+	}
+}
+
+// get returns the authenticator cached for the ImageRepository with the given
+// key, if it was obtained for the same image and provider and hasn't expired.
+func (c *authCache) get(key, image string, provider oci.Provider) (authn.Authenticator, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if e.image != image || e.provider != provider || !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.auth, true
+}
+
+// set caches the authenticator for the ImageRepository with the given key.
+func (c *authCache) set(key, image string, provider oci.Provider, auth authn.Authenticator) {
+	if c == nil {
+		return
+	}
+	c.setUntil(key, image, provider, auth, c.now().Add(providerAuthTTL(provider)))
+}
+
+// setUntil caches the authenticator for the ImageRepository with the given
+// key until the given time, for tokens whose expiry is known.
+func (c *authCache) setUntil(key, image string, provider oci.Provider, auth authn.Authenticator, expiresAt time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = authCacheEntry{
+		image:     image,
+		provider:  provider,
+		auth:      auth,
+		expiresAt: expiresAt,
+	}
+}
+
+// delete removes the authenticator cached for the ImageRepository with the
+// given key.
+func (c *authCache) delete(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
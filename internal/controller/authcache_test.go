@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/oci"
+)
+
+func TestAuthCache(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	c := newAuthCache()
+	c.now = func() time.Time { return now }
+
+	image := "123456789000.dkr.ecr.us-east-1.amazonaws.com/foo"
+	auth := &authn.Basic{Username: "AWS", Password: "token"}
+
+	_, ok := c.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeFalse())
+
+	c.set("default/repo", image, oci.ProviderAWS, auth)
+	cached, ok := c.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(cached).To(Equal(auth))
+
+	// Another image or provider for the same object misses the cache.
+	_, ok = c.get("default/repo", image+"/bar", oci.ProviderAWS)
+	g.Expect(ok).To(BeFalse())
+	c.set("default/repo", image, oci.ProviderAWS, auth)
+	_, ok = c.get("default/repo", image, oci.ProviderGCP)
+	g.Expect(ok).To(BeFalse())
+
+	// The entry expires before the token does.
+	c.set("default/repo", image, oci.ProviderAWS, auth)
+	now = now.Add(providerAuthTTL(oci.ProviderAWS) - time.Minute)
+	_, ok = c.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeTrue())
+	now = now.Add(time.Minute)
+	_, ok = c.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeFalse())
+
+	// A token with a known expiry is cached until then.
+	c.setUntil("default/repo", image, oci.ProviderGeneric, auth, now.Add(time.Minute))
+	_, ok = c.get("default/repo", image, oci.ProviderGeneric)
+	g.Expect(ok).To(BeTrue())
+	now = now.Add(time.Minute)
+	_, ok = c.get("default/repo", image, oci.ProviderGeneric)
+	g.Expect(ok).To(BeFalse())
+
+	c.set("default/repo", image, oci.ProviderAWS, auth)
+	c.delete("default/repo")
+	_, ok = c.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeFalse())
+
+	// A nil cache caches nothing.
+	var nilCache *authCache
+	nilCache.set("default/repo", image, oci.ProviderAWS, auth)
+	_, ok = nilCache.get("default/repo", image, oci.ProviderAWS)
+	g.Expect(ok).To(BeFalse())
+	nilCache.delete("default/repo")
+}
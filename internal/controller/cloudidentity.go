@@ -0,0 +1,139 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+
+	awsauth "github.com/fluxcd/pkg/oci/auth/aws"
+	azureauth "github.com/fluxcd/pkg/oci/auth/azure"
+	"github.com/fluxcd/pkg/oci/auth/login"
+)
+
+// awsRoleARNAnnotation is the annotation of a ServiceAccount holding the ARN
+// of the AWS IAM role to assume with the tokens of the ServiceAccount, as
+// with IAM Roles for Service Accounts (IRSA) on EKS.
+const awsRoleARNAnnotation = "eks.amazonaws.com/role-arn"
+
+// awsTokenAudience is the audience of the ServiceAccount tokens exchanged
+// for AWS credentials.
+const awsTokenAudience = "sts.amazonaws.com"
+
+// loginManager logs in to the registries of the cloud providers.
+type loginManager interface {
+	Login(ctx context.Context, url string, ref name.Reference, opts login.ProviderOptions) (authn.Authenticator, error)
+}
+
+// awsIdentity is the AWS IAM role of an ImageRepository, assumed with a
+// token of its ServiceAccount to log in to AWS ECR.
+type awsIdentity struct {
+	roleARN string
+	region  string
+	token   func() ([]byte, error)
+}
+
+// GetIdentityToken implements stscreds.IdentityTokenRetriever.
+func (i *awsIdentity) GetIdentityToken() ([]byte, error) {
+	return i.token()
+}
+
+// loginIdentity is the identity of an ImageRepository used to log in to the
+// registries of the cloud providers, rather than the identity of the
+// controller.
+type loginIdentity struct {
+	// aws is the IAM role assumed to log in to AWS ECR.
+	aws *awsIdentity
+	// azureClientID is the client ID of the user-assigned managed identity
+	// used to log in to Azure ACR.
+	azureClientID string
+}
+
+// cacheKey returns the suffix of the image the authenticators obtained with
+// the identity are cached for, so that a new identity logs in again.
+func (i loginIdentity) cacheKey() string {
+	switch {
+	case i.aws != nil:
+		return "#" + i.aws.roleARN
+	case i.azureClientID != "":
+		return "#" + i.azureClientID
+	default:
+		return ""
+	}
+}
+
+// newLoginManager returns the login manager using the given identity, or the
+// ambient credentials of the controller for the providers it has none for.
+func newLoginManager(ctx context.Context, identity loginIdentity) (loginManager, error) {
+	m := login.NewManager()
+
+	if identity.aws != nil {
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(identity.aws.region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load default AWS configuration: %w", err)
+		}
+		cfg.Credentials = aws.NewCredentialsCache(
+			stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(cfg), identity.aws.roleARN, identity.aws))
+
+		ecr := awsauth.NewClient()
+		ecr.WithConfig(&cfg)
+		m = m.WithECRClient(ecr)
+	}
+
+	if identity.azureClientID != "" {
+		cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ID: azidentity.ClientID(identity.azureClientID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the Azure managed identity credential: %w", err)
+		}
+		m = m.WithACRClient(azureauth.NewClient().WithTokenCredential(cred))
+	}
+
+	return m, nil
+}
+
+// awsIdentityFor returns the AWS identity of the ImageRepository from the
+// role annotation of its ServiceAccount, or nil without one. The tokens of
+// the ServiceAccount are requested with the given context.
+func (r *ImageRepositoryReconciler) awsIdentityFor(ctx context.Context, image string, serviceAccount *corev1.ServiceAccount) *awsIdentity {
+	if serviceAccount == nil {
+		return nil
+	}
+	roleARN := serviceAccount.GetAnnotations()[awsRoleARNAnnotation]
+	if roleARN == "" {
+		return nil
+	}
+	_, region, _ := awsauth.ParseRegistry(image)
+	return &awsIdentity{
+		roleARN: roleARN,
+		region:  region,
+		token: func() ([]byte, error) {
+			token, err := r.serviceAccountToken(ctx, serviceAccount, awsTokenAudience)
+			return []byte(token), err
+		},
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/fluxcd/pkg/oci/auth/login"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+// fakeLoginManager is a loginManager returning the given authenticator.
+type fakeLoginManager struct {
+	auth authn.Authenticator
+}
+
+func (m fakeLoginManager) Login(context.Context, string, name.Reference, login.ProviderOptions) (authn.Authenticator, error) {
+	return m.auth, nil
+}
+
+func TestImageRepositoryReconciler_awsIdentity(t *testing.T) {
+	testImage := "012345678901.dkr.ecr.us-east-1.amazonaws.com/foo/bar"
+	testRoleARN := "arn:aws:iam::012345678901:role/ecr-reader"
+	testNamespace := "test-ns"
+
+	tests := []struct {
+		name            string
+		provider        string
+		annotations     map[string]string
+		noSA            bool
+		wantIdentity    bool
+		wantCredentials []string
+	}{
+		{
+			name:            "role annotation",
+			provider:        "aws",
+			annotations:     map[string]string{awsRoleARNAnnotation: testRoleARN},
+			wantIdentity:    true,
+			wantCredentials: []string{"provider"},
+		},
+		{
+			name:            "no role annotation",
+			provider:        "aws",
+			wantCredentials: []string{"provider"},
+		},
+		{
+			name:            "no service account",
+			provider:        "aws",
+			noSA:            true,
+			wantCredentials: []string{"provider"},
+		},
+		{
+			name:            "other provider",
+			provider:        "azure",
+			annotations:     map[string]string{awsRoleARNAnnotation: testRoleARN},
+			wantCredentials: []string{"provider"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			sa := &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "ecr-reader",
+					Namespace:   testNamespace,
+					Annotations: tt.annotations,
+				},
+			}
+
+			// The fake client doesn't support creating tokens.
+			var audiences []string
+			c := fake.NewClientBuilder().
+				WithObjects(sa).
+				WithInterceptorFuncs(interceptor.Funcs{
+					SubResourceCreate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, subResourceObj client.Object, opts ...client.SubResourceCreateOption) error {
+						g.Expect(subResource).To(Equal("token"))
+						g.Expect(obj.GetName()).To(Equal(sa.Name))
+						tr := subResourceObj.(*authenticationv1.TokenRequest)
+						audiences = tr.Spec.Audiences
+						tr.Status.Token = "sa-token"
+						return nil
+					},
+				}).
+				Build()
+
+			var gotIdentity *awsIdentity
+			var calls int
+			r := &ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Client:        c,
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+				authCache:     newAuthCache(),
+				newLoginManager: func(_ context.Context, identity loginIdentity) (loginManager, error) {
+					gotIdentity = identity.aws
+					calls++
+					return fakeLoginManager{auth: &authn.Basic{Username: "AWS", Password: "token"}}, nil
+				},
+			}
+
+			obj := &imagev1.ImageRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "repo",
+					Namespace: testNamespace,
+				},
+				Spec: imagev1.ImageRepositorySpec{
+					Image:    testImage,
+					Provider: tt.provider,
+				},
+			}
+			if !tt.noSA {
+				obj.Spec.ServiceAccountName = sa.Name
+			}
+
+			ref, err := name.ParseReference(testImage)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, creds, err := r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			var names []string
+			for _, c := range creds {
+				names = append(names, c.name)
+			}
+			g.Expect(names).To(Equal(tt.wantCredentials))
+
+			if !tt.wantIdentity {
+				g.Expect(gotIdentity).To(BeNil())
+				return
+			}
+			g.Expect(gotIdentity).ToNot(BeNil())
+			g.Expect(gotIdentity.roleARN).To(Equal(testRoleARN))
+			g.Expect(gotIdentity.region).To(Equal("us-east-1"))
+
+			// The role is assumed with a token of the service account.
+			token, err := gotIdentity.GetIdentityToken()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(token)).To(Equal("sa-token"))
+			g.Expect(audiences).To(Equal([]string{awsTokenAudience}))
+
+			// The authenticator is cached for the role, and a new role logs
+			// in again.
+			_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(calls).To(Equal(1))
+
+			sa.Annotations[awsRoleARNAnnotation] = testRoleARN + "-new"
+			g.Expect(c.Update(context.TODO(), sa)).To(Succeed())
+			_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(calls).To(Equal(2))
+			g.Expect(gotIdentity.roleARN).To(Equal(testRoleARN + "-new"))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_azureIdentity(t *testing.T) {
+	testImage := "myregistry.azurecr.io/foo/bar"
+	testClientID := "00000000-0000-0000-0000-000000000001"
+	testNamespace := "test-ns"
+
+	tests := []struct {
+		name         string
+		provider     string
+		annotations  map[string]string
+		noSA         bool
+		wantClientID string
+	}{
+		{
+			name:         "client ID annotation",
+			provider:     "azure",
+			annotations:  map[string]string{imagev1.AzureClientIDAnnotation: testClientID},
+			wantClientID: testClientID,
+		},
+		{
+			name:     "no client ID annotation",
+			provider: "azure",
+		},
+		{
+			name:     "no service account",
+			provider: "azure",
+			noSA:     true,
+		},
+		{
+			name:        "other provider",
+			provider:    "aws",
+			annotations: map[string]string{imagev1.AzureClientIDAnnotation: testClientID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			sa := &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "acr-reader",
+					Namespace:   testNamespace,
+					Annotations: tt.annotations,
+				},
+			}
+
+			var gotIdentity loginIdentity
+			var calls int
+			r := &ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Client:        fake.NewClientBuilder().WithObjects(sa).Build(),
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+				authCache:     newAuthCache(),
+				newLoginManager: func(_ context.Context, identity loginIdentity) (loginManager, error) {
+					gotIdentity = identity
+					calls++
+					return fakeLoginManager{auth: &authn.Basic{Username: "00000000-0000-0000-0000-000000000000", Password: "token"}}, nil
+				},
+			}
+
+			obj := &imagev1.ImageRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "repo",
+					Namespace: testNamespace,
+				},
+				Spec: imagev1.ImageRepositorySpec{
+					Image:    testImage,
+					Provider: tt.provider,
+				},
+			}
+			if !tt.noSA {
+				obj.Spec.ServiceAccountName = sa.Name
+			}
+
+			ref, err := name.ParseReference(testImage)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, creds, err := r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(creds).To(HaveLen(1))
+			g.Expect(gotIdentity.azureClientID).To(Equal(tt.wantClientID))
+			if tt.wantClientID == "" {
+				return
+			}
+
+			// The authenticator is cached for the identity, and a new
+			// identity logs in again.
+			_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(calls).To(Equal(1))
+
+			sa.Annotations[imagev1.AzureClientIDAnnotation] = "00000000-0000-0000-0000-000000000002"
+			g.Expect(r.Update(context.TODO(), sa)).To(Succeed())
+			_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(calls).To(Equal(2))
+			g.Expect(gotIdentity.azureClientID).To(Equal("00000000-0000-0000-0000-000000000002"))
+		})
+	}
+}
+
+func TestNewLoginManager(t *testing.T) {
+	g := NewWithT(t)
+
+	// The identities are configured without logging in.
+	_, err := newLoginManager(context.TODO(), loginIdentity{})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = newLoginManager(context.TODO(), loginIdentity{azureClientID: "00000000-0000-0000-0000-000000000001"})
+	g.Expect(err).ToNot(HaveOccurred())
+}
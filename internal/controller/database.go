@@ -16,16 +16,16 @@ limitations under the License.
 
 package controller
 
-// DatabaseWriter implementations record the tags for an image repository.
-type DatabaseWriter interface {
-	SetTags(repo string, tags []string) error
-}
+import "github.com/fluxcd/image-reflector-controller/internal/database"
+
+// DatabaseWriter implementations record the tags for an image repository. See
+// database.Writer.
+type DatabaseWriter = database.Writer
 
 // DatabaseReader implementations get the stored set of tags for an image
-// repository.
-//
-// If no tags are availble for the repo, then implementations should return an
-// empty set of tags.
-type DatabaseReader interface {
-	Tags(repo string) ([]string, error)
-}
+// repository. See database.Reader.
+type DatabaseReader = database.Reader
+
+// DatabaseSizer implementations report the storage used by the tags
+// database. See database.Sizer.
+type DatabaseSizer = database.Sizer
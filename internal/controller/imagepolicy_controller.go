@@ -20,11 +20,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	kuberecorder "k8s.io/client-go/tools/record"
@@ -33,6 +40,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
@@ -43,11 +51,13 @@ import (
 	"github.com/fluxcd/pkg/runtime/acl"
 	"github.com/fluxcd/pkg/runtime/conditions"
 	helper "github.com/fluxcd/pkg/runtime/controller"
+	"github.com/fluxcd/pkg/runtime/logger"
 	"github.com/fluxcd/pkg/runtime/patch"
 	pkgreconcile "github.com/fluxcd/pkg/runtime/reconcile"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	"github.com/fluxcd/image-reflector-controller/internal/policy"
+	"github.com/fluxcd/image-reflector-controller/internal/registry"
 )
 
 // errAccessDenied is returned when an ImageRepository reference in ImagePolicy
@@ -61,6 +71,11 @@ func (e errAccessDenied) Error() string {
 	return e.err.Error()
 }
 
+// Unwrap returns the underlying error.
+func (e errAccessDenied) Unwrap() error {
+	return e.err
+}
+
 // errInvalidPolicy is returned when the policy is invalid and can't be used.
 type errInvalidPolicy struct {
 	err error
@@ -71,8 +86,29 @@ func (e errInvalidPolicy) Error() string {
 	return e.err.Error()
 }
 
+// Unwrap returns the underlying error.
+func (e errInvalidPolicy) Unwrap() error {
+	return e.err
+}
+
 var errNoTagsInDatabase = errors.New("no tags in database")
 
+// errPinnedTagNotFound is returned when the tag an ImagePolicy is pinned to
+// is not in the database.
+var errPinnedTagNotFound = errors.New("pinned tag not found")
+
+// errNotEnoughCandidates is returned when fewer tags than the minimum number
+// of candidates were passed to the policy.
+var errNotEnoughCandidates = errors.New("not enough candidate tags")
+
+// errTagsTooYoung is returned when none of the tags have been present for
+// longer than the minimum tag age of the policy yet.
+var errTagsTooYoung = errors.New("tags too young")
+
+// errPlatformNotFound is returned when the platform of a policy is not in the
+// image index of the latest image.
+var errPlatformNotFound = errors.New("platform not found")
+
 // imagePolicyOwnedConditions is a list of conditions owned by the
 // ImagePolicyReconciler.
 var imagePolicyOwnedConditions = []string{
@@ -94,6 +130,14 @@ var imagePolicyNegativeConditions = []string{
 // from.
 const imageRepoKey = ".spec.imageRepository"
 
+// imageRepoSelectorKey is the index of the ImagePolicies selecting their
+// ImageRepository by labels, by namespace.
+const imageRepoSelectorKey = ".spec.imageRepositorySelector"
+
+// errNoImageRepositorySelected is returned when the label selector of an
+// ImagePolicy matches no ImageRepository.
+var errNoImageRepositorySelected = errors.New("no ImageRepository matches the selector")
+
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagerepositories,verbs=get;list;watch
@@ -109,12 +153,39 @@ type ImagePolicyReconciler struct {
 	ControllerName string
 	Database       DatabaseReader
 	ACLOptions     acl.Options
+	// CrossNamespaceRefsAllowlist is the list of namespaces whose
+	// ImageRepositories can be referenced from other namespaces even when
+	// cross-namespace references are blocked by the ACL options, e.g. a
+	// namespace of shared infrastructure.
+	CrossNamespaceRefsAllowlist []string
+	// ResolveDigest returns the digest of the manifest a tag of an
+	// ImageRepository points to. It's used to reflect the digest of the
+	// latest image according to the digest reflection policy.
+	ResolveDigest func(ctx context.Context, repo *imagev1.ImageRepository, tag, platform string) (string, error)
+	// ImageHistoryLimit is the maximum number of entries kept in the image
+	// history of the status. Zero disables the image history.
+	ImageHistoryLimit int
+	// DefaultScanInterval is the scan interval of the ImageRepositories that
+	// don't specify one.
+	DefaultScanInterval time.Duration
+	// ImageVariables are the values of the variables referenced in the
+	// images of the ImageRepositories, e.g. `${REGISTRY_HOST}`.
+	ImageVariables map[string]string
+	// RedactPatterns are additional patterns whose matches are redacted from
+	// the registry errors reported in the conditions and events, along with
+	// the known credential patterns.
+	RedactPatterns []*regexp.Regexp
 
 	patchOptions []patch.Option
 }
 
 type ImagePolicyReconcilerOptions struct {
 	RateLimiter ratelimiter.RateLimiter
+	// MaxConcurrentReconciles is the maximum number of ImagePolicies
+	// reconciled in parallel. When zero, the manager's global default is used.
+	// The Database must be safe for concurrent reads when this is more than
+	// one.
+	MaxConcurrentReconciles int
 }
 
 func (r *ImagePolicyReconciler) SetupWithManager(mgr ctrl.Manager, opts ImagePolicyReconcilerOptions) error {
@@ -124,6 +195,9 @@ func (r *ImagePolicyReconciler) SetupWithManager(mgr ctrl.Manager, opts ImagePol
 	// it's easy to list those out when an image repo changes.
 	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &imagev1.ImagePolicy{}, imageRepoKey, func(obj client.Object) []string {
 		pol := obj.(*imagev1.ImagePolicy)
+		if pol.Spec.ImageRepositorySelector != nil {
+			return nil
+		}
 
 		namespace := pol.Spec.ImageRepositoryRef.Namespace
 		if namespace == "" {
@@ -138,14 +212,31 @@ func (r *ImagePolicyReconciler) SetupWithManager(mgr ctrl.Manager, opts ImagePol
 		return err
 	}
 
+	// index the policies selecting their image repo by labels by namespace,
+	// as which image repo they select depends on the labels of all the
+	// image repos of the namespace.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &imagev1.ImagePolicy{}, imageRepoSelectorKey, func(obj client.Object) []string {
+		if obj.(*imagev1.ImagePolicy).Spec.ImageRepositorySelector == nil {
+			return nil
+		}
+		return []string{obj.GetNamespace()}
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&imagev1.ImagePolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&imagev1.ImagePolicy{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			annotationsChangedPredicate(imagev1.DryRunAnnotation, imagev1.DebugAnnotation),
+		))).
 		Watches(
 			&imagev1.ImageRepository{},
 			handler.EnqueueRequestsFromMapFunc(r.imagePoliciesForRepository),
+			builder.WithPredicates(repositoryScanChangedPredicate()),
 		).
 		WithOptions(controller.Options{
-			RateLimiter: opts.RateLimiter,
+			RateLimiter:             opts.RateLimiter,
+			MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
 		}).
 		Complete(r)
 }
@@ -208,10 +299,38 @@ func composeImagePolicyReadyMessage(previousTag, latestTag, image string) string
 	return readyMsg
 }
 
+// composeImagePolicyPinnedMessage composes a Ready message for an ImagePolicy
+// pinned to a tag.
+func composeImagePolicyPinnedMessage(previousTag, pinnedTag, image string) string {
+	if previousTag != "" && previousTag != pinnedTag {
+		return fmt.Sprintf("Latest image tag for '%s' pinned to %s, updated from %s", image, pinnedTag, previousTag)
+	}
+	return fmt.Sprintf("Latest image tag for '%s' pinned to %s", image, pinnedTag)
+}
+
+// composeImagePolicyTagMessage composes a Ready message for an ImagePolicy
+// following the digest of a single tag.
+func composeImagePolicyTagMessage(previousDigest, latestDigest, tag, image string) string {
+	if previousDigest != "" && previousDigest != latestDigest {
+		return fmt.Sprintf("Latest image digest for '%s:%s' updated from %s to %s", image, tag, previousDigest, latestDigest)
+	}
+	return fmt.Sprintf("Latest image digest for '%s:%s' resolved to %s", image, tag, latestDigest)
+}
+
+// composeImagePolicyDryRunMessage composes a Ready message for an ImagePolicy
+// in dry-run mode, reporting the latest image tag it would resolve to.
+func composeImagePolicyDryRunMessage(latestTag, image string) string {
+	return fmt.Sprintf("Dry run: latest image tag for '%s' would resolve to %s, the status is left unchanged", image, latestTag)
+}
+
 func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialPatcher, obj *imagev1.ImagePolicy) (result ctrl.Result, retErr error) {
 	oldObj := obj.DeepCopy()
 
 	var resultImage, resultTag, previousTag string
+	var resultDigest, previousDigest string
+	dryRun := obj.IsDryRun()
+	pinned := obj.Spec.Pin != ""
+	followTag := obj.Spec.Policy.Tag != nil
 
 	// If there's no error and no requeue is requested, it's a success. Unlike
 	// other reconcilers, this reconciler doesn't requeue on its own with a
@@ -225,10 +344,25 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 
 	defer func() {
 		readyMsg := composeImagePolicyReadyMessage(previousTag, resultTag, resultImage)
+		if pinned {
+			readyMsg = composeImagePolicyPinnedMessage(previousTag, resultTag, resultImage)
+		} else if followTag {
+			readyMsg = composeImagePolicyTagMessage(previousDigest, resultDigest, resultTag, resultImage)
+		}
+		if dryRun {
+			readyMsg = composeImagePolicyDryRunMessage(resultTag, resultImage)
+		}
 
 		rs := pkgreconcile.NewResultFinalizer(isSuccess, readyMsg)
 		retErr = rs.Finalize(obj, result, retErr)
 
+		// Tell the tag was selected because it's pinned.
+		if pinned && conditions.IsReady(obj) {
+			ready := conditions.Get(obj, meta.ReadyCondition)
+			ready.Reason = imagev1.PinnedReason
+			conditions.Set(obj, ready)
+		}
+
 		// Presence of reconciling means that the reconciliation didn't succeed.
 		// Set the Reconciling reason to ProgressingWithRetry to indicate a
 		// failure retry.
@@ -254,18 +388,36 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 		}
 	}
 
-	// Cleanup the last result.
-	obj.Status.LatestImage = ""
+	// Keep the tag selected before the cleanup, for the policies depending
+	// on it.
+	currentTag := latestTag(oldObj)
+
+	// Cleanup the last result, unless in dry-run mode, which leaves it
+	// untouched.
+	if !dryRun {
+		obj.Status.LatestImage = ""
+		obj.Status.LatestDigest = ""
+		obj.Status.LatestRef = nil
+		obj.Status.LatestImageFirstSeen = nil
+	}
+	obj.Status.Debug = nil
 
 	// Get ImageRepository from reference.
 	repo, err := r.getImageRepository(ctx, obj)
 	if err != nil {
+		// Stall if the reference to the ImageRepository is invalid.
+		if errors.As(err, &errInvalidPolicy{}) {
+			conditions.MarkStalled(obj, "InvalidPolicy", err.Error())
+			result, retErr = ctrl.Result{}, nil
+			return
+		}
+
 		reason := metav1.StatusFailure
-		if _, ok := err.(errAccessDenied); ok {
+		if errors.As(err, &errAccessDenied{}) {
 			reason = aclapi.AccessDeniedReason
 		}
 
-		if apierrors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) || errors.Is(err, errNoImageRepositorySelected) {
 			reason = imagev1.DependencyNotReadyReason
 		}
 
@@ -292,27 +444,81 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	// Construct a policer from the spec.policy.
 	// Read the tags from database and use the policy to obtain a result for the
 	// latest tag.
-	latest, err := r.applyPolicy(ctx, obj, repo)
+	applyStart := time.Now()
+	latest, requeueAfter, err := r.applyPolicy(ctx, obj, repo, currentTag)
+	recordApplyPolicy(obj.GetName(), obj.GetNamespace(), time.Since(applyStart))
 	if err != nil {
 		// Stall if it's an invalid policy.
-		if _, ok := err.(errInvalidPolicy); ok {
+		if errors.As(err, &errInvalidPolicy{}) {
 			conditions.MarkStalled(obj, "InvalidPolicy", err.Error())
 			result, retErr = ctrl.Result{}, nil
 			return
 		}
 
 		// If there's no tag in the database, mark not ready and retry.
-		if err == errNoTagsInDatabase {
+		if errors.Is(err, errNoTagsInDatabase) {
 			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.DependencyNotReadyReason, err.Error())
 			result, retErr = ctrl.Result{}, err
 			return
 		}
 
+		// If the pinned tag isn't in the database, mark not ready and retry,
+		// as later scans may find it.
+		if errors.Is(err, errPinnedTagNotFound) {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.PinnedTagNotFoundReason, err.Error())
+			result, retErr = ctrl.Result{}, err
+			return
+		}
+
+		// If there are fewer candidate tags than required, mark not ready and
+		// retry, as later scans may find more tags.
+		if errors.Is(err, errNotEnoughCandidates) {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.NotEnoughCandidatesReason, err.Error())
+			result, retErr = ctrl.Result{}, err
+			return
+		}
+
+		// If all the tags are younger than the minimum tag age, mark not
+		// ready and wait for the first of them to be old enough, rather
+		// than retrying on error.
+		if errors.Is(err, errTagsTooYoung) {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.TagsTooYoungReason, err.Error())
+			result, retErr = ctrl.Result{RequeueAfter: requeueAfter}, nil
+			return
+		}
+
+		// If the tag filter matched none of the tags, report it distinctly
+		// from an empty repository as it's likely a misconfiguration.
+		if errors.Is(err, policy.ErrFilterMatchedNothing) {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.FilterMatchedNothingReason, err.Error())
+			result, retErr = ctrl.Result{}, err
+			return
+		}
+
+		// If the policy could select none of the tags, e.g. none is within
+		// the semver range, mark not ready and retry, as later scans may find
+		// a matching tag.
+		if errors.Is(err, policy.ErrNoMatchingTag) {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.NoMatchingTagReason, err.Error())
+			result, retErr = ctrl.Result{}, err
+			return
+		}
+
 		conditions.MarkFalse(obj, meta.ReadyCondition, metav1.StatusFailure, err.Error())
 		result, retErr = ctrl.Result{}, err
 		return
 	}
 
+	// In dry-run mode, only report the latest image tag in the Ready
+	// condition, without writing it on the status.
+	if dryRun {
+		resultImage = repo.Spec.Image
+		resultTag = latest
+		conditions.Delete(obj, meta.ReadyCondition)
+		result, retErr = ctrl.Result{RequeueAfter: requeueAfter}, nil
+		return
+	}
+
 	// Write the observations on status.
 	obj.Status.LatestImage = repo.Spec.Image + ":" + latest
 	// If the old latest image and new latest image don't match, set the old
@@ -324,6 +530,7 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	if oldObj.Status.LatestImage != obj.Status.LatestImage {
 		obj.Status.ObservedPreviousImage = oldObj.Status.LatestImage
 	}
+	obj.Status.ImageHistory = updateImageHistory(obj.Status.ImageHistory, obj.Status.LatestImage, r.ImageHistoryLimit)
 	// Parse the observed previous image if any and extract previous tag. This
 	// is used to determine image tag update path.
 	if obj.Status.ObservedPreviousImage != "" {
@@ -336,12 +543,74 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 		previousTag = prevRef.TagStr()
 	}
 
+	// Reflect the digest of the latest image according to the policy.
+	digest, err := r.reflectDigest(ctx, oldObj, obj, repo, latest)
+	if err != nil {
+		reason := imagev1.DigestUnavailableReason
+		if errors.Is(err, errPlatformNotFound) {
+			reason = imagev1.PlatformNotFoundReason
+		}
+		// With IfNotPresent, keep the digest known for the same image, as
+		// the tag is selected regardless of the failure to resolve it again.
+		if obj.GetDigestReflectionPolicy() == imagev1.ReflectIfNotPresent &&
+			oldObj.Status.LatestDigest != "" && oldObj.Status.LatestImage == obj.Status.LatestImage {
+			obj.Status.LatestDigest = oldObj.Status.LatestDigest
+			obj.Status.LatestRef = &imagev1.ImageRef{
+				Name:   repo.Status.CanonicalImageName,
+				Tag:    latest,
+				Digest: obj.Status.LatestDigest,
+			}
+		}
+		e := fmt.Errorf("tag '%s' selected, but failed to resolve its digest: %w", latest, err)
+		conditions.MarkFalse(obj, meta.ReadyCondition, reason, r.redact(e.Error()))
+		result, retErr = ctrl.Result{}, e
+		return
+	}
+	obj.Status.LatestDigest = digest
+	obj.Status.LatestRef = &imagev1.ImageRef{
+		Name:   repo.Status.CanonicalImageName,
+		Tag:    latest,
+		Digest: digest,
+	}
+
+	// Reflect when the latest tag was first seen, to tell how old it is.
+	firstSeen, err := r.Database.TagsFirstSeen(repo.Status.CanonicalImageName)
+	if err != nil {
+		e := fmt.Errorf("failed to read tag first seen times from database: %w", err)
+		conditions.MarkFalse(obj, meta.ReadyCondition, metav1.StatusFailure, e.Error())
+		result, retErr = ctrl.Result{}, e
+		return
+	}
+	if seen, ok := firstSeen[latest]; ok {
+		obj.Status.LatestImageFirstSeen = &metav1.Time{Time: seen}
+	}
+	recordLatestImageFirstSeen(obj.GetName(), obj.GetNamespace(), obj.Status.LatestImageFirstSeen)
+
 	resultImage = repo.Spec.Image
 	resultTag = latest
+	resultDigest = digest
+	if oldObj.Status.LatestImage == obj.Status.LatestImage {
+		previousDigest = oldObj.Status.LatestDigest
+	}
 
 	conditions.Delete(obj, meta.ReadyCondition)
 
-	result, retErr = ctrl.Result{}, nil
+	// A tag pushed again doesn't change the tags of the repository, which
+	// doesn't trigger a reconciliation of the policy. Requeue at the scan
+	// interval of the repository to follow the digest of the tag.
+	if followTag {
+		interval := repo.Spec.Interval.Duration
+		if interval == 0 {
+			interval = r.DefaultScanInterval
+		}
+		if interval > 0 && (requeueAfter == 0 || interval < requeueAfter) {
+			requeueAfter = interval
+		}
+	}
+
+	// Requeue to reevaluate the policy when a tag held back by the minimum tag
+	// age becomes eligible.
+	result, retErr = ctrl.Result{RequeueAfter: requeueAfter}, nil
 	return
 }
 
@@ -357,12 +626,27 @@ func (r *ImagePolicyReconciler) getImageRepository(ctx context.Context, obj *ima
 		repoNamespacedName.Namespace = obj.Spec.ImageRepositoryRef.Namespace
 	}
 
+	// Resolve the ImageRepository selected by labels, if any.
+	if obj.Spec.ImageRepositorySelector != nil {
+		name, err := r.selectImageRepository(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		repoNamespacedName = types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}
+	} else if obj.Spec.ImageRepositoryRef.Name == "" {
+		return nil, errInvalidPolicy{err: errors.New("invalid policy: either the imageRepositoryRef name or the imageRepositorySelector must be set")}
+	}
+
 	// If NoCrossNamespaceRefs is true and ImageRepository and ImagePolicy are
-	// in different namespaces, the ImageRepository can't be accessed.
-	if r.ACLOptions.NoCrossNamespaceRefs && repoNamespacedName.Namespace != obj.GetNamespace() {
-		return nil, errAccessDenied{
-			err: fmt.Errorf("cannot access '%s/%s', cross-namespace references have been blocked", imagev1.ImageRepositoryKind, repoNamespacedName),
+	// in different namespaces, the ImageRepository can't be accessed, unless
+	// its namespace is allowlisted.
+	if r.ACLOptions.NoCrossNamespaceRefs && repoNamespacedName.Namespace != obj.GetNamespace() &&
+		!slices.Contains(r.CrossNamespaceRefsAllowlist, repoNamespacedName.Namespace) {
+		msg := fmt.Sprintf("cannot access '%s/%s', cross-namespace references have been blocked", imagev1.ImageRepositoryKind, repoNamespacedName)
+		if len(r.CrossNamespaceRefsAllowlist) > 0 {
+			msg += fmt.Sprintf(" except to the namespaces %s", strings.Join(r.CrossNamespaceRefsAllowlist, ", "))
 		}
+		return nil, errAccessDenied{err: fmt.Errorf("access denied by NoCrossNamespaceRefs: %s", msg)}
 	}
 
 	// Get the ImageRepository.
@@ -375,47 +659,275 @@ func (r *ImagePolicyReconciler) getImageRepository(ctx context.Context, obj *ima
 	// Check if the ImageRepository allows access to ImagePolicy.
 	aclAuth := acl.NewAuthorization(r.Client)
 	if err := aclAuth.HasAccessToRef(ctx, obj, repoNamespacedName, repo.Spec.AccessFrom); err != nil {
-		return nil, errAccessDenied{err: fmt.Errorf("access denied: %w", err)}
+		if acl.IsAccessDenied(err) && repo.Spec.AccessFrom != nil {
+			err = fmt.Errorf("%w: %s", err, r.accessFromMismatch(ctx, obj.GetNamespace(), repo.Spec.AccessFrom))
+		}
+		return nil, errAccessDenied{err: fmt.Errorf("access denied by AccessFrom: %w", err)}
+	}
+
+	// Expand the variables of the image, which make up the latest image.
+	image, err := registry.ExpandImage(repo.Spec.Image, r.ImageVariables)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %w", imagev1.ImageRepositoryKind, repoNamespacedName, err)
 	}
+	repo.Spec.Image = image
 
 	return repo, nil
 }
 
+// selectImageRepository returns the name of the single ImageRepository of the
+// namespace of the given ImagePolicy matching its label selector.
+func (r *ImagePolicyReconciler) selectImageRepository(ctx context.Context, obj *imagev1.ImagePolicy) (string, error) {
+	if obj.Spec.ImageRepositoryRef.Name != "" {
+		return "", errInvalidPolicy{err: errors.New("invalid policy: the imageRepositoryRef name and the imageRepositorySelector can't be used together")}
+	}
+	selector, err := metav1.LabelSelectorAsSelector(obj.Spec.ImageRepositorySelector)
+	if err != nil {
+		return "", errInvalidPolicy{err: fmt.Errorf("invalid policy: invalid imageRepositorySelector: %w", err)}
+	}
+
+	var repos imagev1.ImageRepositoryList
+	if err := r.List(ctx, &repos, client.InNamespace(obj.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", fmt.Errorf("failed to list the %s matching the selector '%s': %w", imagev1.ImageRepositoryKind, selector, err)
+	}
+	switch len(repos.Items) {
+	case 0:
+		return "", fmt.Errorf("%w '%s'", errNoImageRepositorySelected, selector)
+	case 1:
+		return repos.Items[0].GetName(), nil
+	default:
+		names := make([]string, 0, len(repos.Items))
+		for _, repo := range repos.Items {
+			names = append(names, repo.GetName())
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("the selector '%s' matches %d ImageRepositories instead of one: %s",
+			selector, len(names), strings.Join(names, ", "))
+	}
+}
+
+// accessFromMismatch describes the namespace selectors of the given ACL that
+// the labels of the namespace don't match, to tell why the access was denied.
+func (r *ImagePolicyReconciler) accessFromMismatch(ctx context.Context, namespace string, accessFrom *aclapi.AccessFrom) string {
+	if len(accessFrom.NamespaceSelectors) == 0 {
+		return "'accessFrom' has no namespace selectors"
+	}
+	var selectors []string
+	for _, selector := range accessFrom.NamespaceSelectors {
+		selectors = append(selectors, fmt.Sprintf("{%s}", labels.Set(selector.MatchLabels)))
+	}
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return fmt.Sprintf("the labels of namespace '%s' match none of the namespace selectors %s",
+			namespace, strings.Join(selectors, ", "))
+	}
+	return fmt.Sprintf("the labels {%s} of namespace '%s' match none of the namespace selectors %s",
+		labels.Set(ns.GetLabels()), namespace, strings.Join(selectors, ", "))
+}
+
 // applyPolicy reads the tags of the given repository from the internal database
-// and applies the tag filters and constraints to return the latest image.
-func (r *ImagePolicyReconciler) applyPolicy(ctx context.Context, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository) (string, error) {
+// and applies the tag filters and constraints to return the latest image. It
+// also returns the duration after which a tag held back by the minimum tag age
+// becomes eligible for selection, if any.
+func (r *ImagePolicyReconciler) applyPolicy(ctx context.Context, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository, current string) (string, time.Duration, error) {
+	obj.Status.EffectivePolicy = nil
+	obj.Status.TotalTagCount = 0
+	obj.Status.TagCount = 0
 	policer, err := policy.PolicerFromSpec(obj.Spec.Policy)
 	if err != nil {
-		return "", errInvalidPolicy{err: fmt.Errorf("invalid policy: %w", err)}
+		return "", 0, errInvalidPolicy{err: fmt.Errorf("invalid policy: %w", err)}
 	}
 
+	// Reflect the policy configuration after defaulting.
+	effectivePolicy, err := policy.EffectivePolicyChoice(policer)
+	if err != nil {
+		return "", 0, errInvalidPolicy{err: fmt.Errorf("invalid policy: %w", err)}
+	}
+	obj.Status.EffectivePolicy = effectivePolicy
+
 	// Read tags from database, apply and filter is configured and compute the
-	// result.
+	// result. All the tags are read, not a limited query: they're all
+	// counted in the status, and the filters and the policies, e.g. semver,
+	// don't order the tags by name or by the time they were first seen.
 	tags, err := r.Database.Tags(repo.Status.CanonicalImageName)
 	if err != nil {
-		return "", fmt.Errorf("failed to read tags from database: %w", err)
+		return "", 0, fmt.Errorf("failed to read tags from database: %w", err)
 	}
 
 	if len(tags) == 0 {
-		return "", errNoTagsInDatabase
+		return "", 0, errNoTagsInDatabase
+	}
+	obj.Status.TotalTagCount = len(tags)
+
+	// Select the pinned tag as is, regardless of the tag filter and the
+	// policy, as long as the repository has it.
+	if pin := obj.Spec.Pin; pin != "" {
+		if !slices.Contains(tags, pin) {
+			return "", 0, fmt.Errorf("%w: the tag '%s' is not in the repository", errPinnedTagNotFound, pin)
+		}
+		return pin, 0, nil
 	}
 
-	// Apply tag filter.
-	if obj.Spec.FilterTags != nil {
-		filter, err := policy.NewRegexFilter(obj.Spec.FilterTags.Pattern, obj.Spec.FilterTags.Extract)
+	// Read the times the tags were first seen, for the tag ages and for the
+	// policies breaking their ties with them.
+	var firstSeen map[string]time.Time
+	maxAge := obj.Spec.FilterTags != nil && obj.Spec.FilterTags.MaxAge != nil
+	if obj.Spec.MinTagAge != nil || maxAge || policy.CreatedAsTiebreak(obj.Spec.Policy) {
+		firstSeen, err = r.Database.TagsFirstSeen(repo.Status.CanonicalImageName)
 		if err != nil {
-			return "", errInvalidPolicy{err: fmt.Errorf("failed to filter tags: %w", err)}
+			return "", 0, fmt.Errorf("failed to read tag first seen times from database: %w", err)
 		}
-		filter.Apply(tags)
-		tags = filter.Items()
-		latest, err := policer.Latest(tags)
+	}
+
+	// Read the sizes of the images of the tags for the size policy, which
+	// the ImageRepository only records when scanning them. The policy is
+	// reconciled again once the ImageRepository is changed to scan them.
+	var sizes map[string]int64
+	if obj.Spec.Policy.Size != nil {
+		if !repo.Spec.ScanSizes {
+			return "", 0, errInvalidPolicy{err: fmt.Errorf("invalid policy: the size policy requires the ImageRepository '%s' to have .spec.scanSizes set", repo.Name)}
+		}
+		sizes, err = r.Database.TagSizes(repo.Status.CanonicalImageName)
 		if err != nil {
-			return "", err
+			return "", 0, fmt.Errorf("failed to read tag sizes from database: %w", err)
+		}
+	}
+
+	// Hold back the tags that are younger than the minimum tag age, and drop
+	// the ones older than the maximum tag age.
+	var requeueAfter time.Duration
+	if obj.Spec.MinTagAge != nil || maxAge {
+		now := time.Now()
+		if obj.Spec.MinTagAge != nil {
+			tags, requeueAfter = filterTagsByAge(tags, firstSeen, obj.Spec.MinTagAge.Duration, now)
+			if len(tags) == 0 {
+				return "", requeueAfter, fmt.Errorf("%w: no tags have been present for longer than the minimum tag age of %s", errTagsTooYoung, obj.Spec.MinTagAge.Duration)
+			}
+		}
+		if maxAge {
+			var expiresAfter time.Duration
+			tags, expiresAfter = filterTagsByMaxAge(tags, firstSeen, obj.Spec.FilterTags.MaxAge.Duration,
+				!obj.Spec.FilterTags.DropUnknownAge, now)
+			if len(tags) == 0 {
+				// The tags only get older, only a new tag can be selected.
+				return "", requeueAfter, fmt.Errorf("%w: no tags have been first seen within the maximum tag age of %s", policy.ErrNoMatchingTag, obj.Spec.FilterTags.MaxAge.Duration)
+			}
+			// Reevaluate the policy when the next tag gets too old.
+			if expiresAfter > 0 && (requeueAfter == 0 || expiresAfter < requeueAfter) {
+				requeueAfter = expiresAfter
+			}
+		}
+	}
+
+	// Apply the tag filter and the policy to compute the result.
+	latest, candidates, err := policy.Evaluate(obj.Spec, tags, current, firstSeen, sizes)
+	obj.Status.TagCount = candidates
+	if errors.Is(err, policy.ErrInvalidPolicy) {
+		return "", 0, errInvalidPolicy{err: err}
+	}
+	if obj.IsDebug() {
+		if ordered, _, err := policy.EvaluateN(obj.Spec, tags, current, firstSeen, sizes, imagev1.MaxDebugCandidateTags); err == nil {
+			obj.Status.Debug = &imagev1.ImagePolicyDebug{CandidateTags: ordered}
+		}
+	}
+	if err != nil {
+		return "", requeueAfter, err
+	}
+	if candidates < obj.Spec.MinCandidates {
+		return "", requeueAfter, fmt.Errorf("%w: %d of the required %d tags are available", errNotEnoughCandidates, candidates, obj.Spec.MinCandidates)
+	}
+	ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("policy applied",
+		"policy", policy.Describe(policer), "latest", latest, "candidates", candidates, "tags", len(tags))
+	return latest, requeueAfter, nil
+}
+
+// latestTag returns the tag of the latest image of the object, falling back to
+// the most recent entry of the image history, which is kept across failures.
+// It returns an empty string when there's none.
+func latestTag(obj *imagev1.ImagePolicy) string {
+	image := obj.Status.LatestImage
+	if image == "" && len(obj.Status.ImageHistory) > 0 {
+		image = obj.Status.ImageHistory[0].Image
+	}
+	if image == "" {
+		return ""
+	}
+	ref, err := name.NewTag(image)
+	if err != nil {
+		return ""
+	}
+	return ref.TagStr()
+}
+
+// reflectDigest returns the digest to set as the latest digest of the object
+// according to its digest reflection policy. With IfNotPresent, the digest
+// observed before the reconciliation is kept as long as neither the latest
+// image nor the spec change.
+func (r *ImagePolicyReconciler) reflectDigest(ctx context.Context, oldObj, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository, tag string) (string, error) {
+	switch obj.GetDigestReflectionPolicy() {
+	case imagev1.ReflectNever:
+		return "", nil
+	case imagev1.ReflectIfNotPresent:
+		if oldObj.Status.LatestDigest != "" && oldObj.Status.LatestImage == obj.Status.LatestImage &&
+			oldObj.Status.ObservedGeneration == obj.Generation {
+			return oldObj.Status.LatestDigest, nil
+		}
+	}
+	if r.ResolveDigest == nil {
+		return "", errors.New("resolving digests is not supported")
+	}
+	return r.ResolveDigest(ctx, repo, tag, obj.Spec.Platform)
+}
+
+// filterTagsByAge returns the tags that were first seen at least minAge before
+// now, along with the duration after which the next held back tag becomes old
+// enough. Tags without a first seen time are considered old enough, as they
+// were recorded before the first seen times were tracked.
+func filterTagsByAge(tags []string, firstSeen map[string]time.Time, minAge time.Duration, now time.Time) ([]string, time.Duration) {
+	var result []string
+	var next time.Duration
+	for _, tag := range tags {
+		seen, ok := firstSeen[tag]
+		if !ok {
+			result = append(result, tag)
+			continue
+		}
+		if age := now.Sub(seen); age < minAge {
+			if wait := minAge - age; next == 0 || wait < next {
+				next = wait
+			}
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result, next
+}
+
+// filterTagsByMaxAge returns the tags that were first seen at most maxAge
+// before now, along with the duration after which the next of them gets
+// older than maxAge. Tags without a first seen time are kept if keepUnknown
+// is true.
+func filterTagsByMaxAge(tags []string, firstSeen map[string]time.Time, maxAge time.Duration, keepUnknown bool, now time.Time) ([]string, time.Duration) {
+	var result []string
+	var next time.Duration
+	for _, tag := range tags {
+		seen, ok := firstSeen[tag]
+		if !ok {
+			if keepUnknown {
+				result = append(result, tag)
+			}
+			continue
+		}
+		age := now.Sub(seen)
+		if age > maxAge {
+			continue
 		}
-		return filter.GetOriginalTag(latest), nil
+		if wait := maxAge - age; next == 0 || wait < next {
+			next = wait
+		}
+		result = append(result, tag)
 	}
-	// Compute and return result.
-	return policer.Latest(tags)
+	return result, next
 }
 
 // reconcileDelete handles the deletion of the object.
@@ -423,6 +935,9 @@ func (r *ImagePolicyReconciler) reconcileDelete(ctx context.Context, obj *imagev
 	// Remove our finalizer from the list.
 	controllerutil.RemoveFinalizer(obj, imagev1.ImageFinalizer)
 
+	// Remove the metrics of the object.
+	deleteImagePolicyMetrics(obj.GetName(), obj.GetNamespace())
+
 	// Stop reconciliation as the object is being deleted.
 	return ctrl.Result{}, nil
 }
@@ -434,10 +949,103 @@ func (r *ImagePolicyReconciler) imagePoliciesForRepository(ctx context.Context,
 		log.Error(err, "failed to list ImagePolcies while getting reconcile requests for the same")
 		return nil
 	}
-	reqs := make([]reconcile.Request, len(policies.Items))
-	for i := range policies.Items {
-		reqs[i].NamespacedName.Name = policies.Items[i].GetName()
-		reqs[i].NamespacedName.Namespace = policies.Items[i].GetNamespace()
+	// The policies selecting their repository by labels may have selected
+	// this one before its labels changed, so all of the namespace are
+	// enqueued.
+	var selecting imagev1.ImagePolicyList
+	if err := r.List(ctx, &selecting, client.MatchingFields{imageRepoSelectorKey: obj.GetNamespace()}); err != nil {
+		log.Error(err, "failed to list ImagePolcies while getting reconcile requests for the same")
+		return nil
+	}
+	items := append(policies.Items, selecting.Items...)
+	reqs := make([]reconcile.Request, len(items))
+	for i := range items {
+		reqs[i].NamespacedName.Name = items[i].GetName()
+		reqs[i].NamespacedName.Namespace = items[i].GetNamespace()
 	}
 	return reqs
 }
+
+// redact returns the message with the credentials redacted.
+func (r *ImagePolicyReconciler) redact(msg string) string {
+	return redactCredentials(msg, r.RedactPatterns...)
+}
+
+// annotationsChangedPredicate returns a predicate letting through the
+// updates changing the value of one of the given annotations, which change
+// the result of a reconciliation without a new generation, e.g. removing the
+// dry-run annotation applies the policy and adding the debug annotation
+// reports the candidate tags.
+func annotationsChangedPredicate(keys ...string) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			oldAnnotations, newAnnotations := e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()
+			for _, key := range keys {
+				if oldAnnotations[key] != newAnnotations[key] {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// repositoryScanChangedPredicate returns a predicate letting through the
+// updates of an ImageRepository that can change the result of the policies
+// referring to it, i.e. a new generation, new labels, by which policies may
+// select it, or a scan finding different tags, and ignoring the other status
+// updates, like the ones of every scan finding the same tags. Creations and
+// deletions are let through.
+func repositoryScanChangedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRepo, ok := e.ObjectOld.(*imagev1.ImageRepository)
+			if !ok {
+				return true
+			}
+			newRepo, ok := e.ObjectNew.(*imagev1.ImageRepository)
+			if !ok {
+				return true
+			}
+			if oldRepo.GetGeneration() != newRepo.GetGeneration() ||
+				!maps.Equal(oldRepo.GetLabels(), newRepo.GetLabels()) ||
+				oldRepo.Status.CanonicalImageName != newRepo.Status.CanonicalImageName {
+				return true
+			}
+			return scanResultChanged(oldRepo.Status.LastScanResult, newRepo.Status.LastScanResult)
+		},
+	}
+}
+
+// scanResultChanged returns true if the new scan result found different tags
+// than the old one. Without tags digests to compare, any new scan is
+// considered a change.
+func scanResultChanged(oldResult, newResult *imagev1.ScanResult) bool {
+	if oldResult == nil || newResult == nil {
+		return oldResult != newResult
+	}
+	if oldResult.TagsDigest == "" || newResult.TagsDigest == "" {
+		return !oldResult.ScanTime.Equal(&newResult.ScanTime)
+	}
+	return oldResult.TagsDigest != newResult.TagsDigest || oldResult.TagCount != newResult.TagCount
+}
+
+// updateImageHistory returns the image history with the given image recorded
+// as the most recent entry, if it isn't already, and trimmed to the limit.
+// Unlike the observed previous image, the history is kept across failures, so
+// recovering from a failure with the same image doesn't add an entry.
+func updateImageHistory(history []imagev1.ImageHistoryEntry, image string, limit int) []imagev1.ImageHistoryEntry {
+	if limit <= 0 {
+		return nil
+	}
+	if len(history) == 0 || history[0].Image != image {
+		history = append([]imagev1.ImageHistoryEntry{{Image: image, ResolvedAt: metav1.Now()}}, history...)
+	}
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history
+}
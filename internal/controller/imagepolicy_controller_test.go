@@ -19,21 +19,43 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	aclapis "github.com/fluxcd/pkg/apis/acl"
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/acl"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/patch"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/database"
 	"github.com/fluxcd/image-reflector-controller/internal/policy"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
 )
 
 func TestImagePolicyReconciler_deleteBeforeFinalizer(t *testing.T) {
@@ -80,11 +102,13 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 	tests := []struct {
 		name                  string
 		aclOpts               acl.Options
+		allowlist             []string
 		imagePolicySpec       imagev1.ImagePolicySpec
 		policyNamespaceLabels map[string]string
 		imageRepoNamespace    string
 		imageRepoAccessFrom   *aclapis.AccessFrom
 		wantErr               bool
+		wantErrMsg            string
 		wantRepo              string
 	}{
 		{
@@ -109,6 +133,58 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 			},
 			imageRepoNamespace: testNamespace2,
 			wantErr:            true,
+			wantErrMsg:         "access denied by NoCrossNamespaceRefs",
+		},
+		{
+			name:      "NoCrossNamespaceRefs=true, repo in allowlisted namespace, ACL authorized",
+			aclOpts:   acl.Options{NoCrossNamespaceRefs: true},
+			allowlist: []string{testNamespace2},
+			imagePolicySpec: imagev1.ImagePolicySpec{
+				ImageRepositoryRef: meta.NamespacedObjectReference{
+					Name:      testImageRepoName,
+					Namespace: testNamespace2,
+				},
+			},
+			imageRepoNamespace: testNamespace2,
+			imageRepoAccessFrom: &aclapis.AccessFrom{
+				NamespaceSelectors: []aclapis.NamespaceSelector{
+					{MatchLabels: map[string]string{}},
+				},
+			},
+			wantRepo: testImageRepoName,
+		},
+		{
+			name:      "NoCrossNamespaceRefs=true, repo in allowlisted namespace, ACL not authorized",
+			aclOpts:   acl.Options{NoCrossNamespaceRefs: true},
+			allowlist: []string{testNamespace2},
+			imagePolicySpec: imagev1.ImagePolicySpec{
+				ImageRepositoryRef: meta.NamespacedObjectReference{
+					Name:      testImageRepoName,
+					Namespace: testNamespace2,
+				},
+			},
+			imageRepoNamespace: testNamespace2,
+			wantErr:            true,
+			wantErrMsg:         "access denied by AccessFrom",
+		},
+		{
+			name:      "NoCrossNamespaceRefs=true, repo in namespace not allowlisted",
+			aclOpts:   acl.Options{NoCrossNamespaceRefs: true},
+			allowlist: []string{"shared-infra"},
+			imagePolicySpec: imagev1.ImagePolicySpec{
+				ImageRepositoryRef: meta.NamespacedObjectReference{
+					Name:      testImageRepoName,
+					Namespace: testNamespace2,
+				},
+			},
+			imageRepoNamespace: testNamespace2,
+			imageRepoAccessFrom: &aclapis.AccessFrom{
+				NamespaceSelectors: []aclapis.NamespaceSelector{
+					{MatchLabels: map[string]string{}},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "cross-namespace references have been blocked except to the namespaces shared-infra",
 		},
 		{
 			name: "referred repo does not exist",
@@ -206,7 +282,8 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 					{MatchLabels: map[string]string{"xxx": "yyy"}},
 				},
 			},
-			wantErr: true,
+			wantErr:    true,
+			wantErrMsg: "access denied by AccessFrom: 'test-ns2/test-repo' can't be accessed due to ACL labels mismatch on namespace 'test-ns1': the labels {foo1=bar1,foo2=bar2} of namespace 'test-ns1' match none of the namespace selectors {aaa=bbb}, {mmm=nnn}, {xxx=yyy}",
 		},
 	}
 
@@ -238,10 +315,11 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 			clientBuilder.WithObjects(imagePolicyNS, imageRepoNS, imageRepo)
 
 			r := &ImagePolicyReconciler{
-				EventRecorder: record.NewFakeRecorder(32),
-				Client:        clientBuilder.Build(),
-				ACLOptions:    tt.aclOpts,
-				patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+				EventRecorder:               record.NewFakeRecorder(32),
+				Client:                      clientBuilder.Build(),
+				ACLOptions:                  tt.aclOpts,
+				CrossNamespaceRefsAllowlist: tt.allowlist,
+				patchOptions:                getPatchOptions(imagePolicyOwnedConditions, "irc"),
 			}
 
 			obj := &imagev1.ImagePolicy{
@@ -255,6 +333,9 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 
 			repo, err := r.getImageRepository(context.TODO(), obj)
 			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if tt.wantErrMsg != "" {
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErrMsg))
+			}
 			if err == nil {
 				g.Expect(repo.Name).To(Equal(tt.wantRepo))
 			}
@@ -262,20 +343,119 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 	}
 }
 
+func TestImagePolicyReconciler_selectImageRepository(t *testing.T) {
+	newRepo := func(name, namespace string, labels map[string]string) *imagev1.ImageRepository {
+		repo := &imagev1.ImageRepository{}
+		repo.Name = name
+		repo.Namespace = namespace
+		repo.Labels = labels
+		return repo
+	}
+
+	tests := []struct {
+		name      string
+		ref       meta.NamespacedObjectReference
+		selector  *metav1.LabelSelector
+		wantRepo  string
+		wantErr   string
+		wantStall bool
+	}{
+		{
+			name:     "selector matching one repo",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			wantRepo: "foo",
+		},
+		{
+			name:     "selector matching no repo",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "baz"}},
+			wantErr:  "no ImageRepository matches the selector 'app=baz'",
+		},
+		{
+			name:     "selector matching several repos",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			wantErr:  "the selector 'team=a' matches 2 ImageRepositories instead of one: bar, foo",
+		},
+		{
+			name:      "selector along with a name",
+			ref:       meta.NamespacedObjectReference{Name: "foo"},
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			wantErr:   "can't be used together",
+			wantStall: true,
+		},
+		{
+			name:      "neither selector nor name",
+			wantErr:   "either the imageRepositoryRef name or the imageRepositorySelector must be set",
+			wantStall: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newRepo("foo", "default", map[string]string{"app": "foo", "team": "a"}),
+				newRepo("bar", "default", map[string]string{"app": "bar", "team": "a"}),
+				// Repositories in other namespaces are never selected.
+				newRepo("foo", "other", map[string]string{"app": "foo"}),
+			).Build()
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+			}
+
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Spec.ImageRepositoryRef = tt.ref
+			obj.Spec.ImageRepositorySelector = tt.selector
+
+			repo, err := r.getImageRepository(context.TODO(), obj)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(errors.As(err, &errInvalidPolicy{})).To(Equal(tt.wantStall))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(repo.Name).To(Equal(tt.wantRepo))
+			g.Expect(repo.Namespace).To(Equal("default"))
+		})
+	}
+}
+
 func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 	tests := []struct {
-		name       string
-		policy     imagev1.ImagePolicyChoice
-		filter     *imagev1.TagFilter
-		db         *mockDatabase
-		wantErr    bool
-		wantResult string
+		name                string
+		policy              imagev1.ImagePolicyChoice
+		filter              *imagev1.TagFilter
+		minTagAge           *metav1.Duration
+		minCandidates       int
+		db                  *mockDatabase
+		wantErr             bool
+		wantErrIs           error
+		wantInvalidPolicy   bool
+		wantResult          string
+		wantEffectivePolicy *imagev1.ImagePolicyChoice
 	}{
 		{
 			name:    "invalid policy",
 			policy:  imagev1.ImagePolicyChoice{},
 			wantErr: true,
 		},
+		{
+			name: "more than one policy",
+			policy: imagev1.ImagePolicyChoice{
+				SemVer:    &imagev1.SemVerPolicy{Range: "1.0.x"},
+				Numerical: &imagev1.NumericalPolicy{Order: policy.NumericalOrderAsc},
+			},
+			db:                &mockDatabase{TagData: []string{"1.0.0", "1.0.1"}},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
 		{
 			name:    "database read fail",
 			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
@@ -293,6 +473,9 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
 			db:         &mockDatabase{TagData: []string{"1.0.0", "2.0.0", "1.0.1", "1.2.0"}},
 			wantResult: "1.0.1",
+			wantEffectivePolicy: &imagev1.ImagePolicyChoice{
+				SemVer: &imagev1.SemVerPolicy{Ranges: []string{"1.0.x"}, Order: "desc"},
+			},
 		},
 		{
 			name:       "semver with 'v' prefix, no tag filter",
@@ -312,6 +495,79 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			db:         &mockDatabase{TagData: []string{"v1.0.0", "v2.0.0", "v1.0.1", "v1.2.0"}},
 			wantResult: "v1.0.1",
 		},
+		{
+			name:       "semver ascending, no tag filter",
+			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x", Order: "asc"}},
+			db:         &mockDatabase{TagData: []string{"1.0.0", "2.0.0", "1.0.1", "1.2.0"}},
+			wantResult: "1.0.0",
+		},
+		{
+			name:      "semver with min tag age, just seen tag excluded",
+			policy:    imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			minTagAge: &metav1.Duration{Duration: 10 * time.Minute},
+			db: &mockDatabase{
+				TagData: []string{"1.0.0", "1.0.1", "1.0.2"},
+				FirstSeenData: map[string]time.Time{
+					"1.0.0": time.Now().Add(-time.Hour),
+					"1.0.1": time.Now().Add(-20 * time.Minute),
+					"1.0.2": time.Now(),
+				},
+			},
+			wantResult: "1.0.1",
+		},
+		{
+			name:      "semver with min tag age, no tag old enough",
+			policy:    imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			minTagAge: &metav1.Duration{Duration: 10 * time.Minute},
+			db: &mockDatabase{
+				TagData: []string{"1.0.0"},
+				FirstSeenData: map[string]time.Time{
+					"1.0.0": time.Now(),
+				},
+			},
+			wantErr:   true,
+			wantErrIs: errTagsTooYoung,
+		},
+		{
+			name:   "semver with max tag age, old tag excluded",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			filter: &imagev1.TagFilter{MaxAge: &metav1.Duration{Duration: 24 * time.Hour}},
+			db: &mockDatabase{
+				TagData: []string{"1.0.0", "1.0.1", "1.0.2"},
+				FirstSeenData: map[string]time.Time{
+					"1.0.0": time.Now().Add(-time.Hour),
+					"1.0.1": time.Now().Add(-2 * time.Hour),
+					"1.0.2": time.Now().Add(-48 * time.Hour),
+				},
+			},
+			wantResult: "1.0.1",
+		},
+		{
+			name:   "semver with max tag age, no tag recent enough",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			filter: &imagev1.TagFilter{MaxAge: &metav1.Duration{Duration: 24 * time.Hour}, DropUnknownAge: true},
+			db: &mockDatabase{
+				TagData: []string{"1.0.0", "1.0.1"},
+				FirstSeenData: map[string]time.Time{
+					"1.0.0": time.Now().Add(-48 * time.Hour),
+				},
+			},
+			wantErr:   true,
+			wantErrIs: policy.ErrNoMatchingTag,
+		},
+		{
+			name:       "semver ranges, no tag filter",
+			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Ranges: []string{"1.4.x", "2.1.x"}}},
+			db:         &mockDatabase{TagData: []string{"1.4.0", "1.4.1", "2.0.0", "2.1.3", "2.2.0"}},
+			wantResult: "2.1.3",
+		},
+		{
+			name:              "semver ranges with invalid entry",
+			policy:            imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Ranges: []string{"1.4.x", "1x"}}},
+			db:                &mockDatabase{TagData: []string{"1.4.0"}},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
 		{
 			name:    "invalid tag filter",
 			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
@@ -319,6 +575,21 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			db:      &mockDatabase{TagData: []string{"1.0.0", "1.0.1"}},
 			wantErr: true,
 		},
+		{
+			name:          "fewer candidates than required",
+			policy:        imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			filter:        &imagev1.TagFilter{Pattern: `^1\.0\.`},
+			minCandidates: 3,
+			db:            &mockDatabase{TagData: []string{"1.0.0", "1.0.1", "1.2.0"}},
+			wantErr:       true,
+		},
+		{
+			name:          "enough candidates",
+			policy:        imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			minCandidates: 3,
+			db:            &mockDatabase{TagData: []string{"1.0.0", "1.0.1", "1.2.0"}},
+			wantResult:    "1.0.1",
+		},
 		{
 			name:   "valid tag filter with numerical policy",
 			policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{Order: policy.NumericalOrderAsc}},
@@ -342,6 +613,9 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 				"foo-aaa", "bar-bbb", "foo-zzz", "baz-nnn", "foo-ooo",
 			}},
 			wantResult: "foo-zzz",
+			wantEffectivePolicy: &imagev1.ImagePolicyChoice{
+				Alphabetical: &imagev1.AlphabeticalPolicy{Order: "asc"},
+			},
 		},
 	}
 
@@ -363,11 +637,23 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			}
 			obj.Spec.Policy = tt.policy
 			obj.Spec.FilterTags = tt.filter
+			obj.Spec.MinTagAge = tt.minTagAge
+			obj.Spec.MinCandidates = tt.minCandidates
 
 			repo := &imagev1.ImageRepository{}
 
-			result, err := r.applyPolicy(context.TODO(), obj, repo)
+			result, _, err := r.applyPolicy(context.TODO(), obj, repo, "")
 			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if tt.wantErrIs != nil {
+				g.Expect(err).To(MatchError(tt.wantErrIs))
+			}
+			if tt.wantInvalidPolicy {
+				g.Expect(err).To(BeAssignableToTypeOf(errInvalidPolicy{}))
+				g.Expect(obj.Status.EffectivePolicy).To(BeNil())
+			}
+			if tt.wantEffectivePolicy != nil {
+				g.Expect(obj.Status.EffectivePolicy).To(Equal(tt.wantEffectivePolicy))
+			}
 			if err == nil {
 				g.Expect(result).To(Equal(tt.wantResult))
 			}
@@ -375,32 +661,265 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 	}
 }
 
-func TestComposeImagePolicyReadyMessage(t *testing.T) {
-	testImage := "foo/bar"
+func TestImagePolicyReconciler_applyPolicyTagCount(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &ImagePolicyReconciler{
+		Database: &mockDatabase{
+			TagData: []string{"1.0.0", "1.0.1", "1.0.2", "foo-aaa", "foo-bbb"},
+			FirstSeenData: map[string]time.Time{
+				"1.0.2": time.Now(),
+			},
+		},
+	}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}}
+	obj.Spec.FilterTags = &imagev1.TagFilter{Pattern: `^1\.`}
+	obj.Spec.MinTagAge = &metav1.Duration{Duration: 10 * time.Minute}
+
+	result, _, err := r.applyPolicy(context.TODO(), obj, &imagev1.ImageRepository{}, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(Equal("1.0.1"))
+	g.Expect(obj.Status.TotalTagCount).To(Equal(5))
+	g.Expect(obj.Status.TagCount).To(Equal(2))
+}
+
+func TestImagePolicyReconciler_tagsTooYoung(t *testing.T) {
+	g := NewWithT(t)
 
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 1}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+	obj.Spec.MinTagAge = &metav1.Duration{Duration: 10 * time.Minute}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database: &mockDatabase{
+			TagData:       []string{"1.0.0"},
+			FirstSeenData: map[string]time.Time{"1.0.0": time.Now().Add(-4 * time.Minute)},
+		},
+		patchOptions: getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	// The policy waits for the tag to be old enough, rather than failing.
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	result, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 6*time.Minute, 5*time.Second))
+	g.Expect(result.RequeueAfter).To(BeNumerically("<=", 6*time.Minute))
+	g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.TagsTooYoungReason))
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(ContainSubstring("minimum tag age of 10m0s"))
+	g.Expect(conditions.IsStalled(obj)).To(BeFalse())
+	g.Expect(obj.Status.LatestImage).To(BeEmpty())
+}
+
+func TestImagePolicyReconciler_tagsTooOld(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 1}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+	obj.Spec.FilterTags = &imagev1.TagFilter{MaxAge: &metav1.Duration{Duration: 24 * time.Hour}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database: &mockDatabase{
+			TagData:       []string{"1.0.0"},
+			FirstSeenData: map[string]time.Time{"1.0.0": time.Now().Add(-48 * time.Hour)},
+		},
+		patchOptions: getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	// The tags older than the maximum tag age match nothing, until a new tag
+	// is scanned.
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).To(MatchError(policy.ErrNoMatchingTag))
+	g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.NoMatchingTagReason))
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(ContainSubstring("maximum tag age of 24h0m0s"))
+}
+
+func TestImagePolicyReconciler_applyPolicyCreatedAsTiebreak(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	r := &ImagePolicyReconciler{
+		Database: &mockDatabase{
+			TagData: []string{"build-7-abc", "build-7-def", "build-6-fed"},
+			FirstSeenData: map[string]time.Time{
+				"build-7-abc": now,
+				"build-7-def": now.Add(-time.Hour),
+				"build-6-fed": now.Add(time.Hour),
+			},
+		},
+	}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}}
+	obj.Spec.FilterTags = &imagev1.TagFilter{Pattern: `^build-(?P<n>[0-9]+)-`, Extract: `$n`}
+
+	result, _, err := r.applyPolicy(context.TODO(), obj, &imagev1.ImageRepository{}, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(Equal("build-7-def"))
+
+	// The rebuild of the same build number first seen the most recently is
+	// selected.
+	obj.Spec.Policy.Numerical.CreatedAsTiebreak = true
+	result, _, err = r.applyPolicy(context.TODO(), obj, &imagev1.ImageRepository{}, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(Equal("build-7-abc"))
+}
+
+func TestImagePolicyReconciler_filterMatchedNothing(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "example.com/foo/bar"
+	repo.Status.CanonicalImageName = "example.com/foo/bar"
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 3}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+	obj.Spec.FilterTags = &imagev1.TagFilter{Pattern: "^rel-"}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	recorder := record.NewFakeRecorder(32)
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: recorder,
+		Database:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0"}},
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.FilterMatchedNothingReason))
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(ContainSubstring("none of the 3 tags matched the pattern '^rel-'"))
+
+	g.Expect(recorder.Events).To(Receive(Equal(
+		"Warning FilterMatchedNothing tag filter matched nothing: none of the 3 tags matched the pattern '^rel-'",
+	)))
+}
+
+func TestImagePolicyReconciler_latestImageFirstSeen(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "example.com/foo/bar"
+	repo.Status.CanonicalImageName = "example.com/foo/bar"
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+	obj.Spec.DigestReflectionPolicy = imagev1.ReflectNever
+
+	firstSeen := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	db := &mockDatabase{
+		TagData:       []string{"1.0.0", "1.1.0"},
+		FirstSeenData: map[string]time.Time{"1.1.0": firstSeen},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("example.com/foo/bar:1.1.0"))
+	g.Expect(obj.Status.LatestImageFirstSeen).ToNot(BeNil())
+	g.Expect(obj.Status.LatestImageFirstSeen.Time).To(BeTemporally("==", firstSeen))
+
+	// Without a known first seen time, the field is left empty.
+	db.FirstSeenData = nil
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImageFirstSeen).To(BeNil())
+}
+
+func TestImagePolicyReconciler_pin(t *testing.T) {
 	tests := []struct {
-		name        string
-		previousTag string
-		latestTag   string
-		image       string
-		wantMessage string
+		name       string
+		pin        string
+		wantErr    bool
+		wantReason string
+		wantImage  string
 	}{
 		{
-			name:        "no previous tag",
-			latestTag:   "1.0.0",
-			wantMessage: "Latest image tag for 'foo/bar' resolved to 1.0.0",
+			name:       "pinned tag in the repository",
+			pin:        "1.0.0",
+			wantReason: imagev1.PinnedReason,
+			wantImage:  "example.com/foo/bar:1.0.0",
 		},
 		{
-			name:        "different previous tag",
-			previousTag: "1.0.0",
-			latestTag:   "1.1.0",
-			wantMessage: "Latest image tag for 'foo/bar' updated from 1.0.0 to 1.1.0",
+			name:       "pinned tag outside of the policy",
+			pin:        "2.0.0-rc.1",
+			wantReason: imagev1.PinnedReason,
+			wantImage:  "example.com/foo/bar:2.0.0-rc.1",
 		},
 		{
-			name:        "same previous and latest tags",
-			previousTag: "1.0.0",
-			latestTag:   "1.0.0",
-			wantMessage: "Latest image tag for 'foo/bar' resolved to 1.0.0",
+			name:       "pinned tag not in the repository",
+			pin:        "0.9.0",
+			wantErr:    true,
+			wantReason: imagev1.PinnedTagNotFoundReason,
+		},
+		{
+			name:       "not pinned",
+			wantReason: meta.SucceededReason,
+			wantImage:  "example.com/foo/bar:1.2.0",
 		},
 	}
 
@@ -408,8 +927,1167 @@ func TestComposeImagePolicyReadyMessage(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			result := composeImagePolicyReadyMessage(tt.previousTag, tt.latestTag, testImage)
-			g.Expect(result).To(Equal(tt.wantMessage))
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			repo := &imagev1.ImageRepository{}
+			repo.Name = "test-repo"
+			repo.Namespace = "default"
+			repo.Spec.Image = "example.com/foo/bar"
+			repo.Status.CanonicalImageName = "example.com/foo/bar"
+			repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 4}
+
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Generation = 1
+			obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+			obj.Spec.Pin = tt.pin
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0-rc.1"}},
+				patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(tt.wantReason))
+			g.Expect(obj.Status.LatestImage).To(Equal(tt.wantImage))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_noMatchingTag(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "example.com/foo/bar"
+	repo.Status.CanonicalImageName = "example.com/foo/bar"
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 3}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "2.x"}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0"}},
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.NoMatchingTagReason))
+	g.Expect(conditions.IsStalled(obj)).To(BeFalse())
+}
+
+func TestImagePolicyReconciler_digestReflection(t *testing.T) {
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-digest-"+randStringRunes(5), []string{"1.0.0", "1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(mustParseTag(t, imgRepo+":1.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	latestDigest := desc.Digest.String()
+
+	tests := []struct {
+		name            string
+		policy          imagev1.ReflectionPolicy
+		lastImage       string
+		lastDigest      string
+		withoutResolver bool
+		wantDigest      string
+		wantErr         bool
+	}{
+		{
+			name:       "never by default",
+			lastImage:  imgRepo + ":1.1.0",
+			lastDigest: "sha256:old",
+			wantDigest: "",
+		},
+		{
+			name:       "never",
+			policy:     imagev1.ReflectNever,
+			wantDigest: "",
+		},
+		{
+			name:       "always",
+			policy:     imagev1.ReflectAlways,
+			lastImage:  imgRepo + ":1.1.0",
+			lastDigest: "sha256:old",
+			wantDigest: latestDigest,
+		},
+		{
+			name:       "if not present without a digest",
+			policy:     imagev1.ReflectIfNotPresent,
+			lastImage:  imgRepo + ":1.1.0",
+			wantDigest: latestDigest,
+		},
+		{
+			name:       "if not present with a digest of the same image",
+			policy:     imagev1.ReflectIfNotPresent,
+			lastImage:  imgRepo + ":1.1.0",
+			lastDigest: "sha256:old",
+			wantDigest: "sha256:old",
+		},
+		{
+			name:       "if not present with a digest of another image",
+			policy:     imagev1.ReflectIfNotPresent,
+			lastImage:  imgRepo + ":1.0.0",
+			lastDigest: "sha256:old",
+			wantDigest: latestDigest,
+		},
+		{
+			name:            "without resolver",
+			policy:          imagev1.ReflectAlways,
+			withoutResolver: true,
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			repo := &imagev1.ImageRepository{}
+			repo.Name = "test-repo"
+			repo.Namespace = "default"
+			repo.Spec.Image = imgRepo
+			repo.Status.CanonicalImageName = imgRepo
+			repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+			obj.Spec.DigestReflectionPolicy = tt.policy
+			obj.Status.LatestImage = tt.lastImage
+			obj.Status.LatestDigest = tt.lastDigest
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+			repoReconciler := &ImageRepositoryReconciler{Client: c}
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}},
+				patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+			}
+			if !tt.withoutResolver {
+				r.ResolveDigest = repoReconciler.ResolveDigest
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if tt.wantErr {
+				g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+				g.Expect(obj.Status.LatestDigest).To(BeEmpty())
+				g.Expect(obj.Status.LatestRef).To(BeNil())
+				return
+			}
+			g.Expect(obj.Status.LatestImage).To(Equal(imgRepo + ":1.1.0"))
+			g.Expect(obj.Status.LatestDigest).To(Equal(tt.wantDigest))
+			g.Expect(obj.Status.LatestRef).To(Equal(&imagev1.ImageRef{Name: imgRepo, Tag: "1.1.0", Digest: tt.wantDigest}))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_digestErrorRedacted(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 1}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+	obj.Spec.DigestReflectionPolicy = imagev1.ReflectAlways
+
+	recorder := record.NewFakeRecorder(32)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: recorder,
+		Database:      &mockDatabase{TagData: []string{"1.0.0"}},
+		ResolveDigest: func(ctx context.Context, repo *imagev1.ImageRepository, tag, platform string) (string, error) {
+			return "", errors.New("GET https://auth.internal.example.com/token?access_token=s3cr3t: unexpected status code 403")
+		},
+		RedactPatterns: []*regexp.Regexp{regexp.MustCompile(`auth\.internal\.example\.com`)},
+		patchOptions:   getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	// The registry error is redacted from the condition and the event.
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.DigestUnavailableReason))
+	msg := conditions.GetMessage(obj, meta.ReadyCondition)
+	g.Expect(msg).To(ContainSubstring("GET https://[REDACTED]/token?access_token=[REDACTED]"))
+	g.Expect(msg).ToNot(ContainSubstring("s3cr3t"))
+	g.Expect(msg).ToNot(ContainSubstring("internal.example.com"))
+	var event string
+	g.Expect(recorder.Events).To(Receive(&event))
+	g.Expect(event).To(HavePrefix("Warning DigestUnavailable"))
+	g.Expect(event).ToNot(ContainSubstring("s3cr3t"))
+	g.Expect(event).ToNot(ContainSubstring("internal.example.com"))
+}
+
+func TestImagePolicyReconciler_digestReflectionPlatform(t *testing.T) {
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo := test.RegistryName(registryServer) + "/test-platform-" + randStringRunes(5)
+
+	// Push an image index with an image per platform.
+	var idx v1.ImageIndex = empty.Index
+	digests := map[string]string{}
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	} {
+		img, err := random.Image(512, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		platform := p
+		digests[platform.String()] = d.String()
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+	if err := remote.WriteIndex(mustParseTag(t, imgRepo+":1.0.0"), idx); err != nil {
+		t.Fatal(err)
+	}
+	indexDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		platform   string
+		wantDigest string
+		wantReason string
+	}{
+		{
+			name:       "without platform",
+			wantDigest: indexDigest.String(),
+		},
+		{
+			name:       "with platform",
+			platform:   "linux/amd64",
+			wantDigest: digests["linux/amd64"],
+		},
+		{
+			name:       "with platform without variant",
+			platform:   "linux/arm64",
+			wantDigest: digests["linux/arm64/v8"],
+		},
+		{
+			name:       "with platform not in the index",
+			platform:   "windows/amd64",
+			wantReason: imagev1.PlatformNotFoundReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			repo := &imagev1.ImageRepository{}
+			repo.Name = "test-repo"
+			repo.Namespace = "default"
+			repo.Spec.Image = imgRepo
+			repo.Status.CanonicalImageName = imgRepo
+			repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 1}
+
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+			obj.Spec.DigestReflectionPolicy = imagev1.ReflectAlways
+			obj.Spec.Platform = tt.platform
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{TagData: []string{"1.0.0"}},
+				ResolveDigest: (&ImageRepositoryReconciler{Client: c}).ResolveDigest,
+				patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj)
+			if tt.wantReason != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(tt.wantReason))
+				g.Expect(obj.Status.LatestDigest).To(BeEmpty())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(obj.Status.LatestDigest).To(Equal(tt.wantDigest))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_digestUnavailable(t *testing.T) {
+	// The registry fails the manifest requests once the images are loaded,
+	// as when the credentials are rejected.
+	var failManifests atomic.Bool
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failManifests.Load() && strings.Contains(r.URL.Path, "/manifests/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-unavailable-"+randStringRunes(5), []string{"1.0.0", "1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failManifests.Store(true)
+
+	tests := []struct {
+		name       string
+		policy     imagev1.ReflectionPolicy
+		lastImage  string
+		lastDigest string
+		wantDigest string
+	}{
+		{
+			name:       "always",
+			policy:     imagev1.ReflectAlways,
+			lastImage:  imgRepo + ":1.1.0",
+			lastDigest: "sha256:old",
+		},
+		{
+			name:       "if not present with a digest of the same image",
+			policy:     imagev1.ReflectIfNotPresent,
+			lastImage:  imgRepo + ":1.1.0",
+			lastDigest: "sha256:old",
+			wantDigest: "sha256:old",
+		},
+		{
+			name:       "if not present with a digest of another image",
+			policy:     imagev1.ReflectIfNotPresent,
+			lastImage:  imgRepo + ":1.0.0",
+			lastDigest: "sha256:old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			repo := &imagev1.ImageRepository{}
+			repo.Name = "test-repo"
+			repo.Namespace = "default"
+			repo.Spec.Image = imgRepo
+			repo.Status.CanonicalImageName = imgRepo
+			repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+			// A new generation has the digest resolved again with
+			// IfNotPresent.
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Generation = 2
+			obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+			obj.Spec.DigestReflectionPolicy = tt.policy
+			obj.Status.ObservedGeneration = 1
+			obj.Status.LatestImage = tt.lastImage
+			obj.Status.LatestDigest = tt.lastDigest
+
+			recorder := record.NewFakeRecorder(32)
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: recorder,
+				Database:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}},
+				ResolveDigest: (&ImageRepositoryReconciler{Client: c}).ResolveDigest,
+				patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.DigestUnavailableReason))
+			g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(ContainSubstring("tag '1.1.0' selected, but failed to resolve its digest"))
+			g.Expect(obj.Status.LatestImage).To(Equal(imgRepo + ":1.1.0"))
+			g.Expect(obj.Status.LatestDigest).To(Equal(tt.wantDigest))
+			if tt.wantDigest == "" {
+				g.Expect(obj.Status.LatestRef).To(BeNil())
+			} else {
+				g.Expect(obj.Status.LatestRef).To(Equal(&imagev1.ImageRef{Name: imgRepo, Tag: "1.1.0", Digest: tt.wantDigest}))
+			}
+			g.Expect(recorder.Events).To(Receive(HavePrefix("Warning DigestUnavailable")))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_tagPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Spec.Interval = metav1.Duration{Duration: 5 * time.Minute}
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{Name: "main"}}
+	// The digest of a tag policy is reflected regardless of the digest
+	// reflection policy.
+	obj.Spec.DigestReflectionPolicy = imagev1.ReflectNever
+
+	digest := "sha256:aaaa"
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{TagData: []string{"1.0.0", "main"}},
+		ResolveDigest: func(ctx context.Context, repo *imagev1.ImageRepository, tag, platform string) (string, error) {
+			return digest, nil
+		},
+		patchOptions: getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	result, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:main"))
+	g.Expect(obj.Status.LatestRef.Digest).To(Equal("sha256:aaaa"))
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(Equal("Latest image digest for 'ghcr.io/example/app:main' resolved to sha256:aaaa"))
+	// The policy is reevaluated at the scan interval to follow the tag.
+	g.Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+
+	// The tag is pushed again.
+	digest = "sha256:bbbb"
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:main"))
+	g.Expect(obj.Status.LatestRef.Digest).To(Equal("sha256:bbbb"))
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(Equal("Latest image digest for 'ghcr.io/example/app:main' updated from sha256:aaaa to sha256:bbbb"))
+}
+
+func TestImagePolicyReconciler_sizePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		scanSizes bool
+		wantImage string
+		wantErr   string
+	}{
+		{
+			name:      "with the sizes scanned",
+			scanSizes: true,
+			wantImage: "ghcr.io/example/app:1.1.0-alpine",
+		},
+		{
+			name:    "without the sizes scanned",
+			wantErr: "the size policy requires the ImageRepository 'test-repo' to have .spec.scanSizes set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+			repo := &imagev1.ImageRepository{}
+			repo.Name = "test-repo"
+			repo.Namespace = "default"
+			repo.Spec.Image = "ghcr.io/example/app"
+			repo.Spec.ScanSizes = tt.scanSizes
+			repo.Status.CanonicalImageName = repo.Spec.Image
+			repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 3}
+
+			obj := &imagev1.ImagePolicy{}
+			obj.Name = "test-policy"
+			obj.Namespace = "default"
+			obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}}
+
+			now := time.Now()
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+			r := &ImagePolicyReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+				Database: &mockDatabase{
+					TagData:  []string{"1.0.0", "1.0.0-alpine", "1.1.0-alpine"},
+					SizeData: map[string]int64{"1.0.0": 300, "1.0.0-alpine": 100, "1.1.0-alpine": 100},
+					FirstSeenData: map[string]time.Time{
+						"1.0.0-alpine": now.Add(-time.Hour),
+						"1.1.0-alpine": now,
+					},
+				},
+				patchOptions: getPatchOptions(imagePolicyOwnedConditions, "irc"),
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj)
+			if tt.wantErr != "" {
+				// The policy is stalled rather than retried.
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(conditions.IsStalled(obj)).To(BeTrue())
+				g.Expect(conditions.GetReason(obj, meta.StalledCondition)).To(Equal("InvalidPolicy"))
+				g.Expect(conditions.GetMessage(obj, meta.StalledCondition)).To(ContainSubstring(tt.wantErr))
+				g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(obj.Status.LatestImage).To(Equal(tt.wantImage))
+			g.Expect(obj.Status.EffectivePolicy).To(Equal(&imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}}))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_imageHistory(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 1}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+
+	db := &mockDatabase{}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:            c,
+		EventRecorder:     record.NewFakeRecorder(32),
+		Database:          db,
+		ImageHistoryLimit: 2,
+		patchOptions:      getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	var images []string
+	for _, tags := range [][]string{
+		{"1.0.0"},
+		{"1.0.0"},
+		{"1.0.0", "1.1.0"},
+		{"1.0.0", "1.1.0", "1.2.0"},
+	} {
+		db.TagData = tags
+		sp := patch.NewSerialPatcher(obj, r.Client)
+		_, err := r.reconcile(context.TODO(), sp, obj)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		images = images[:0]
+		for _, entry := range obj.Status.ImageHistory {
+			g.Expect(entry.ResolvedAt.IsZero()).To(BeFalse())
+			images = append(images, entry.Image)
+		}
+	}
+	// The unchanged image is recorded once, and the oldest image is trimmed.
+	g.Expect(images).To(Equal([]string{"ghcr.io/example/app:1.2.0", "ghcr.io/example/app:1.1.0"}))
+	g.Expect(obj.Status.ObservedPreviousImage).To(Equal("ghcr.io/example/app:1.1.0"))
+
+	// Disabling the image history clears it.
+	r.ImageHistoryLimit = 0
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.ImageHistory).To(BeEmpty())
+}
+
+func TestImagePolicyReconciler_dryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+
+	db := &mockDatabase{TagData: []string{"1.0.0"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:1.0.0"))
+
+	// In dry-run mode, a newer tag is only reported in the Ready condition.
+	obj.Annotations = map[string]string{imagev1.DryRunAnnotation: "true"}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "2.x"}}
+	db.TagData = []string{"1.0.0", "2.0.0"}
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:1.0.0"))
+	g.Expect(obj.Status.LatestRef.Tag).To(Equal("1.0.0"))
+	g.Expect(conditions.IsReady(obj)).To(BeTrue())
+	g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(Equal(
+		"Dry run: latest image tag for 'ghcr.io/example/app' would resolve to 2.0.0, the status is left unchanged"))
+	g.Expect(obj.Status.EffectivePolicy.SemVer.Ranges).To(Equal([]string{"2.x"}))
+
+	// Removing the annotation applies the policy.
+	obj.Annotations = nil
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:2.0.0"))
+	g.Expect(obj.Status.ObservedPreviousImage).To(Equal("ghcr.io/example/app:1.0.0"))
+}
+
+func TestImagePolicyReconciler_lockMajor(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 2}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0", LockMajor: true}}
+
+	db := &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:            c,
+		EventRecorder:     record.NewFakeRecorder(32),
+		Database:          db,
+		ImageHistoryLimit: 1,
+		patchOptions:      getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	// Without a latest image, the highest version is selected.
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:1.1.0"))
+
+	// A new major version isn't selected, a new minor version is.
+	db.TagData = []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"}
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:1.2.0"))
+
+	// A failure clears the latest image, the major version is then read
+	// from the image history.
+	db.ReadError = errors.New("read failure")
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(BeEmpty())
+
+	db.ReadError = nil
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.LatestImage).To(Equal("ghcr.io/example/app:1.2.0"))
+}
+
+func TestImagePolicyReconciler_debug(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = "ghcr.io/example/app"
+	repo.Status.CanonicalImageName = repo.Spec.Image
+	repo.Status.LastScanResult = &imagev1.ScanResult{TagCount: 30}
+
+	obj := &imagev1.ImagePolicy{}
+	obj.Name = "test-policy"
+	obj.Namespace = "default"
+	obj.Spec.ImageRepositoryRef = meta.NamespacedObjectReference{Name: repo.Name}
+	obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}}
+
+	var tags []string
+	for i := 0; i < 30; i++ {
+		tags = append(tags, fmt.Sprintf("1.%d.0", i))
+	}
+	tags = append(tags, "2.0.0")
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, obj).WithStatusSubresource(obj).Build()
+	r := &ImagePolicyReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{TagData: tags},
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	// Without the annotation, the candidate tags aren't reported.
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.Debug).To(BeNil())
+
+	// With the annotation, the candidate tags are reported from the latest
+	// and truncated.
+	obj.Annotations = map[string]string{imagev1.DebugAnnotation: "true"}
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.TagCount).To(Equal(31))
+	g.Expect(obj.Status.Debug).ToNot(BeNil())
+	g.Expect(obj.Status.Debug.CandidateTags).To(HaveLen(imagev1.MaxDebugCandidateTags))
+	g.Expect(obj.Status.Debug.CandidateTags[:2]).To(Equal([]string{"1.29.0", "1.28.0"}))
+
+	// Removing the annotation clears them.
+	obj.Annotations = nil
+	sp = patch.NewSerialPatcher(obj, r.Client)
+	_, err = r.reconcile(context.TODO(), sp, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Status.Debug).To(BeNil())
+}
+
+func TestImagePolicyReconciler_applyPolicyConcurrent(t *testing.T) {
+	g := NewWithT(t)
+
+	db := database.NewBadgerDatabase(testBadgerDB)
+	repo := &imagev1.ImageRepository{}
+	repo.Status.CanonicalImageName = "example.com/concurrent-" + randStringRunes(5)
+	g.Expect(db.SetTags(repo.Status.CanonicalImageName, []string{
+		"1.0.0", "1.0.1", "1.1.0", "2.0.0", "foo-aaa", "foo-zzz",
+	})).To(Succeed())
+
+	r := &ImagePolicyReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imagePolicyOwnedConditions, "irc"),
+	}
+
+	choices := []struct {
+		policy     imagev1.ImagePolicyChoice
+		filter     *imagev1.TagFilter
+		wantResult string
+	}{
+		{
+			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			wantResult: "1.1.0",
+		},
+		{
+			policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Order: policy.AlphabeticalOrderDesc}},
+			filter:     &imagev1.TagFilter{Pattern: "^foo-"},
+			wantResult: "foo-aaa",
+		},
+	}
+
+	// The results are asserted once all the policies are applied, as the
+	// test must not be failed from other goroutines.
+	type result struct {
+		latest string
+		want   string
+		err    error
+	}
+	results := make([]result, 20*len(choices))
+	var wg sync.WaitGroup
+	for i := range results {
+		c := choices[i%len(choices)]
+		wg.Add(1)
+		go func(i int, policy imagev1.ImagePolicyChoice, filter *imagev1.TagFilter, want string) {
+			defer wg.Done()
+			obj := &imagev1.ImagePolicy{}
+			obj.Spec.Policy = policy
+			obj.Spec.FilterTags = filter
+
+			latest, _, err := r.applyPolicy(context.TODO(), obj, repo, "")
+			results[i] = result{latest: latest, want: want, err: err}
+		}(i, c.policy, c.filter, c.wantResult)
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		g.Expect(res.err).ToNot(HaveOccurred())
+		g.Expect(res.latest).To(Equal(res.want))
+	}
+}
+
+func TestFilterTagsByAge(t *testing.T) {
+	now := time.Now()
+	minAge := 10 * time.Minute
+
+	tests := []struct {
+		name        string
+		tags        []string
+		firstSeen   map[string]time.Time
+		now         time.Time
+		wantTags    []string
+		wantRequeue time.Duration
+	}{
+		{
+			name:      "all tags old enough",
+			tags:      []string{"a", "b"},
+			firstSeen: map[string]time.Time{"a": now.Add(-time.Hour), "b": now.Add(-minAge)},
+			now:       now,
+			wantTags:  []string{"a", "b"},
+		},
+		{
+			name:        "just seen tag excluded",
+			tags:        []string{"a", "b"},
+			firstSeen:   map[string]time.Time{"a": now.Add(-time.Hour), "b": now},
+			now:         now,
+			wantTags:    []string{"a"},
+			wantRequeue: minAge,
+		},
+		{
+			name:      "just seen tag included after aging past the threshold",
+			tags:      []string{"a", "b"},
+			firstSeen: map[string]time.Time{"a": now.Add(-time.Hour), "b": now},
+			now:       now.Add(minAge + time.Second),
+			wantTags:  []string{"a", "b"},
+		},
+		{
+			name:        "requeue for the earliest eligible tag",
+			tags:        []string{"a", "b"},
+			firstSeen:   map[string]time.Time{"a": now.Add(-2 * time.Minute), "b": now.Add(-5 * time.Minute)},
+			now:         now,
+			wantRequeue: 5 * time.Minute,
+		},
+		{
+			name:     "tag without first seen time included",
+			tags:     []string{"a"},
+			now:      now,
+			wantTags: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tags, requeue := filterTagsByAge(tt.tags, tt.firstSeen, minAge, tt.now)
+			g.Expect(tags).To(Equal(tt.wantTags))
+			g.Expect(requeue).To(Equal(tt.wantRequeue))
+		})
+	}
+}
+
+func TestFilterTagsByMaxAge(t *testing.T) {
+	now := time.Now()
+	maxAge := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		tags        []string
+		firstSeen   map[string]time.Time
+		keepUnknown bool
+		wantTags    []string
+		wantRequeue time.Duration
+	}{
+		{
+			name:        "old tag dropped",
+			tags:        []string{"a", "b"},
+			firstSeen:   map[string]time.Time{"a": now.Add(-maxAge - time.Hour), "b": now.Add(-time.Hour)},
+			wantTags:    []string{"b"},
+			wantRequeue: maxAge - time.Hour,
+		},
+		{
+			name:        "requeue for the earliest expiring tag",
+			tags:        []string{"a", "b"},
+			firstSeen:   map[string]time.Time{"a": now.Add(-2 * time.Hour), "b": now.Add(-time.Hour)},
+			wantTags:    []string{"a", "b"},
+			wantRequeue: maxAge - 2*time.Hour,
+		},
+		{
+			name:      "all tags too old",
+			tags:      []string{"a"},
+			firstSeen: map[string]time.Time{"a": now.Add(-maxAge - time.Second)},
+		},
+		{
+			name:        "tag without first seen time kept",
+			tags:        []string{"a"},
+			keepUnknown: true,
+			wantTags:    []string{"a"},
+		},
+		{
+			name: "tag without first seen time dropped",
+			tags: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tags, requeue := filterTagsByMaxAge(tt.tags, tt.firstSeen, maxAge, tt.keepUnknown, now)
+			g.Expect(tags).To(Equal(tt.wantTags))
+			g.Expect(requeue).To(Equal(tt.wantRequeue))
+		})
+	}
+}
+
+func TestComposeImagePolicyReadyMessage(t *testing.T) {
+	testImage := "foo/bar"
+
+	tests := []struct {
+		name        string
+		previousTag string
+		latestTag   string
+		image       string
+		wantMessage string
+	}{
+		{
+			name:        "no previous tag",
+			latestTag:   "1.0.0",
+			wantMessage: "Latest image tag for 'foo/bar' resolved to 1.0.0",
+		},
+		{
+			name:        "different previous tag",
+			previousTag: "1.0.0",
+			latestTag:   "1.1.0",
+			wantMessage: "Latest image tag for 'foo/bar' updated from 1.0.0 to 1.1.0",
+		},
+		{
+			name:        "same previous and latest tags",
+			previousTag: "1.0.0",
+			latestTag:   "1.0.0",
+			wantMessage: "Latest image tag for 'foo/bar' resolved to 1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result := composeImagePolicyReadyMessage(tt.previousTag, tt.latestTag, testImage)
+			g.Expect(result).To(Equal(tt.wantMessage))
+		})
+	}
+}
+
+func TestRepositoryScanChangedPredicate(t *testing.T) {
+	scanTime := metav1.Now()
+	later := metav1.NewTime(scanTime.Add(time.Minute))
+
+	tests := []struct {
+		name       string
+		oldRepo    func(*imagev1.ImageRepository)
+		newRepo    func(*imagev1.ImageRepository)
+		wantUpdate bool
+	}{
+		{
+			name:       "first scan",
+			oldRepo:    func(r *imagev1.ImageRepository) { r.Status.LastScanResult = nil },
+			wantUpdate: true,
+		},
+		{
+			name: "scan finding the same tags",
+			newRepo: func(r *imagev1.ImageRepository) {
+				r.Status.LastScanResult.ScanTime = later
+				r.Status.LastScanResult.Unchanged = true
+			},
+		},
+		{
+			name: "scan finding different tags",
+			newRepo: func(r *imagev1.ImageRepository) {
+				r.Status.LastScanResult.ScanTime = later
+				r.Status.LastScanResult.TagsDigest = "sha256:def"
+			},
+			wantUpdate: true,
+		},
+		{
+			name: "new scan without tags digest",
+			oldRepo: func(r *imagev1.ImageRepository) {
+				r.Status.LastScanResult.TagsDigest = ""
+			},
+			newRepo: func(r *imagev1.ImageRepository) {
+				r.Status.LastScanResult.TagsDigest = ""
+				r.Status.LastScanResult.ScanTime = later
+			},
+			wantUpdate: true,
+		},
+		{
+			name:       "new labels",
+			newRepo:    func(r *imagev1.ImageRepository) { r.Labels = map[string]string{"app": "foo"} },
+			wantUpdate: true,
+		},
+		{
+			name:    "unrelated status update",
+			newRepo: func(r *imagev1.ImageRepository) { r.Status.ObservedGeneration = 2 },
+		},
+		{
+			name:       "new generation",
+			newRepo:    func(r *imagev1.ImageRepository) { r.Generation = 2 },
+			wantUpdate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			base := &imagev1.ImageRepository{}
+			base.Generation = 1
+			base.Status.CanonicalImageName = "example.com/foo/bar"
+			base.Status.LastScanResult = &imagev1.ScanResult{
+				TagCount:   3,
+				ScanTime:   scanTime,
+				TagsDigest: "sha256:abc",
+			}
+			oldRepo, newRepo := base.DeepCopy(), base.DeepCopy()
+			if tt.oldRepo != nil {
+				tt.oldRepo(oldRepo)
+			}
+			if tt.newRepo != nil {
+				tt.newRepo(newRepo)
+			}
+
+			p := repositoryScanChangedPredicate()
+			g.Expect(p.Update(event.UpdateEvent{ObjectOld: oldRepo, ObjectNew: newRepo})).To(Equal(tt.wantUpdate))
+			g.Expect(p.Create(event.CreateEvent{Object: newRepo})).To(BeTrue())
+			g.Expect(p.Delete(event.DeleteEvent{Object: newRepo})).To(BeTrue())
+		})
+	}
+}
+
+func TestAnnotationsChangedPredicate(t *testing.T) {
+	tests := []struct {
+		name           string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		wantUpdate     bool
+	}{
+		{
+			name:           "annotation added",
+			newAnnotations: map[string]string{imagev1.DryRunAnnotation: "true"},
+			wantUpdate:     true,
+		},
+		{
+			name:           "annotation removed",
+			oldAnnotations: map[string]string{imagev1.DryRunAnnotation: "true"},
+			wantUpdate:     true,
+		},
+		{
+			name:           "annotation changed",
+			oldAnnotations: map[string]string{imagev1.DryRunAnnotation: "true"},
+			newAnnotations: map[string]string{imagev1.DryRunAnnotation: "false"},
+			wantUpdate:     true,
+		},
+		{
+			name:           "annotation unchanged",
+			oldAnnotations: map[string]string{imagev1.DryRunAnnotation: "true"},
+			newAnnotations: map[string]string{imagev1.DryRunAnnotation: "true", "foo": "bar"},
+		},
+		{
+			name:           "debug annotation added",
+			newAnnotations: map[string]string{imagev1.DebugAnnotation: "true"},
+			wantUpdate:     true,
+		},
+		{
+			name:           "debug annotation removed",
+			oldAnnotations: map[string]string{imagev1.DryRunAnnotation: "true", imagev1.DebugAnnotation: "true"},
+			newAnnotations: map[string]string{imagev1.DryRunAnnotation: "true"},
+			wantUpdate:     true,
+		},
+		{
+			name:           "other annotation changed",
+			newAnnotations: map[string]string{"foo": "bar"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldObj, newObj := &imagev1.ImagePolicy{}, &imagev1.ImagePolicy{}
+			oldObj.Annotations = tt.oldAnnotations
+			newObj.Annotations = tt.newAnnotations
+
+			p := annotationsChangedPredicate(imagev1.DryRunAnnotation, imagev1.DebugAnnotation)
+			g.Expect(p.Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj})).To(Equal(tt.wantUpdate))
 		})
 	}
 }
@@ -17,18 +17,33 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,9 +52,11 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	kuberecorder "k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 
@@ -49,23 +66,38 @@ import (
 	"github.com/fluxcd/pkg/oci/auth/login"
 	"github.com/fluxcd/pkg/runtime/conditions"
 	helper "github.com/fluxcd/pkg/runtime/controller"
+	"github.com/fluxcd/pkg/runtime/logger"
 	"github.com/fluxcd/pkg/runtime/patch"
 	"github.com/fluxcd/pkg/runtime/predicates"
 	"github.com/fluxcd/pkg/runtime/reconcile"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+	"github.com/fluxcd/image-reflector-controller/internal/gcp"
+	"github.com/fluxcd/image-reflector-controller/internal/github"
+	"github.com/fluxcd/image-reflector-controller/internal/registry"
 	"github.com/fluxcd/image-reflector-controller/internal/secret"
 )
 
 // latestTagsCount is the number of tags to use as latest tags.
 const latestTagsCount = 10
 
+// githubTokenExpiryMargin is how long before it expires a GitHub App
+// installation token is refreshed, so that it doesn't expire during a scan.
+const githubTokenExpiryMargin = 5 * time.Minute
+
+// gcpTokenExpiryMargin is how long before it expires an access token of a
+// Google service account is refreshed, so that it doesn't expire during a
+// scan.
+const gcpTokenExpiryMargin = 5 * time.Minute
+
 // imageRepositoryOwnedConditions is a list of conditions owned by the
 // ImageRepositoryReconciler.
 var imageRepositoryOwnedConditions = []string{
 	meta.ReadyCondition,
 	meta.ReconcilingCondition,
 	meta.StalledCondition,
+	imagev1.StorageThresholdExceededCondition,
 }
 
 // imageRepositoryNegativeConditions is a list of negative polarity conditions
@@ -74,6 +106,7 @@ var imageRepositoryOwnedConditions = []string{
 var imageRepositoryNegativeConditions = []string{
 	meta.StalledCondition,
 	meta.ReconcilingCondition,
+	imagev1.StorageThresholdExceededCondition,
 }
 
 // Reasons for scan.
@@ -100,6 +133,7 @@ func getPatchOptions(ownedConditions []string, controllerName string) []patch.Op
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
 
 // ImageRepositoryReconciler reconciles a ImageRepository object
 type ImageRepositoryReconciler struct {
@@ -111,10 +145,53 @@ type ImageRepositoryReconciler struct {
 	Database       interface {
 		DatabaseWriter
 		DatabaseReader
+		DatabaseSizer
 	}
 	DeprecatedLoginOpts login.ProviderOptions
+	// RedactPatterns are additional patterns whose matches are redacted from
+	// the messages of the conditions and events, along with the known
+	// credential patterns.
+	RedactPatterns []*regexp.Regexp
+	// NoInsecureRegistries disallows scanning registries over plain HTTP,
+	// regardless of the insecure setting of the ImageRepositories.
+	NoInsecureRegistries bool
+	// DefaultTransport, if set, is used to connect to the registries of the
+	// ImageRepositories without a certificate secret reference, e.g. to
+	// trust the CA certificates of internal registries.
+	DefaultTransport *http.Transport
+	// ScanJitter is the maximum fraction of the scan interval added to it.
+	// The added duration is derived from the namespace and name of the
+	// ImageRepositories, to spread the scans of the ones sharing the same
+	// interval.
+	ScanJitter float64
+	// ScanConcurrency is the maximum number of tags whose metadata, such as
+	// their referrers, is fetched at the same time during a scan. Values
+	// lower than one fetch them one at a time.
+	ScanConcurrency int
+	// DefaultScanInterval is the scan interval of the ImageRepositories that
+	// don't specify one. Zero leaves them without an interval.
+	DefaultScanInterval time.Duration
+	// ImageVariables are the values of the variables referenced in the
+	// images of the ImageRepositories, e.g. `${REGISTRY_HOST}`.
+	ImageVariables map[string]string
+	// DigestRetries is the number of times resolving the digest of a tag is
+	// retried after a transient failure, with an exponential backoff.
+	DigestRetries int
+	// RepoSizeThreshold is the number of bytes the records of an
+	// ImageRepository can take in the database before it's marked with the
+	// StorageThresholdExceeded condition. Zero disables the condition.
+	RepoSizeThreshold int64
 
 	patchOptions []patch.Option
+	authCache    *authCache
+	scans        *inFlightScans
+	rateLimits   *rateLimitBackoffs
+	// newGCPIdentity returns the Google service account bound with Workload
+	// Identity, defaults to newGCPIdentity.
+	newGCPIdentity func(googleServiceAccount string) (*gcp.WorkloadIdentity, error)
+	// newLoginManager returns the manager logging in to the registries of
+	// the cloud providers, defaults to newLoginManager.
+	newLoginManager func(ctx context.Context, identity loginIdentity) (loginManager, error)
 }
 
 type ImageRepositoryReconcilerOptions struct {
@@ -123,9 +200,12 @@ type ImageRepositoryReconcilerOptions struct {
 
 func (r *ImageRepositoryReconciler) SetupWithManager(mgr ctrl.Manager, opts ImageRepositoryReconcilerOptions) error {
 	r.patchOptions = getPatchOptions(imageRepositoryOwnedConditions, r.ControllerName)
+	r.authCache = newAuthCache()
+	r.scans = newInFlightScans()
+	r.rateLimits = newRateLimitBackoffs()
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&imagev1.ImageRepository{}).
+		For(&imagev1.ImageRepository{}, builder.WithPredicates(r.cancelScanOnDelete())).
 		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{})).
 		WithOptions(controller.Options{
 			RateLimiter: opts.RateLimiter,
@@ -133,6 +213,26 @@ func (r *ImageRepositoryReconciler) SetupWithManager(mgr ctrl.Manager, opts Imag
 		Complete(r)
 }
 
+// cancelScanOnDelete returns a predicate cancelling the scan in progress of an
+// ImageRepository when it's deleted, so that it stops making requests to the
+// registry. It lets all the events through.
+func (r *ImageRepositoryReconciler) cancelScanOnDelete() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectNew != nil && !e.ObjectNew.GetDeletionTimestamp().IsZero() {
+				r.scans.cancel(client.ObjectKeyFromObject(e.ObjectNew).String())
+			}
+			return true
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			if e.Object != nil {
+				r.scans.cancel(client.ObjectKeyFromObject(e.Object).String())
+			}
+			return true
+		},
+	}
+}
+
 func (r *ImageRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, retErr error) {
 	start := time.Now()
 	log := ctrl.LoggerFrom(ctx)
@@ -143,6 +243,17 @@ func (r *ImageRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Default the interval of the object before initializing the patch
+	// helper, so that the default isn't written to the object.
+	if obj.Spec.Interval.Duration == 0 {
+		obj.Spec.Interval.Duration = r.DefaultScanInterval
+	}
+	// Likewise for the variables of the image. An image that fails to be
+	// expanded is reported by the reconciliation.
+	if image, err := registry.ExpandImage(obj.Spec.Image, r.ImageVariables); err == nil {
+		obj.Spec.Image = image
+	}
+
 	// Initialize the patch helper with the current version of the object.
 	serialPatcher := patch.NewSerialPatcher(obj, r.Client)
 
@@ -248,19 +359,45 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 		}
 	}
 
-	// Parse image reference.
-	ref, err := parseImageReference(obj.Spec.Image, obj.Spec.Insecure)
+	if obj.Spec.Insecure && r.NoInsecureRegistries {
+		conditions.MarkStalled(obj, imagev1.InsecureRegistryNotAllowedReason,
+			"insecure registries are not allowed by the controller; remove .spec.insecure")
+		result, retErr = ctrl.Result{}, nil
+		return
+	}
+	if obj.Spec.Scheme == imagev1.SchemeHTTP && !obj.Spec.Insecure {
+		conditions.MarkStalled(obj, imagev1.InvalidSchemeReason,
+			"the 'http' scheme requires .spec.insecure to be set")
+		result, retErr = ctrl.Result{}, nil
+		return
+	}
+
+	// Parse image reference. The image is expanded before the reconciliation,
+	// only the errors are left to report.
+	if _, err := registry.ExpandImage(obj.Spec.Image, r.ImageVariables); err != nil {
+		conditions.MarkStalled(obj, imagev1.ImageURLInvalidReason, r.redact(err.Error()))
+		result, retErr = ctrl.Result{}, nil
+		return
+	}
+	ref, canonicalName, err := registry.Canonicalize(obj.Spec.Image, obj.Spec.Insecure)
 	if err != nil {
-		conditions.MarkStalled(obj, imagev1.ImageURLInvalidReason, err.Error())
+		conditions.MarkStalled(obj, imagev1.ImageURLInvalidReason, r.redact(err.Error()))
 		result, retErr = ctrl.Result{}, nil
 		return
 	}
+	for _, mirror := range obj.Spec.Mirrors {
+		if _, err := mirrorReference(ref, mirror, obj.Spec.Insecure); err != nil {
+			conditions.MarkStalled(obj, imagev1.ImageURLInvalidReason, r.redact(err.Error()))
+			result, retErr = ctrl.Result{}, nil
+			return
+		}
+	}
 	conditions.Delete(obj, meta.StalledCondition)
 
-	opts, err := r.setAuthOptions(ctx, obj, ref)
+	opts, creds, err := r.setAuthOptions(ctx, obj, ref)
 	if err != nil {
 		e := fmt.Errorf("failed to configure authentication options: %w", err)
-		conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.AuthenticationFailedReason, e.Error())
+		conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.AuthenticationFailedReason, r.redact(e.Error()))
 		result, retErr = ctrl.Result{}, e
 		return
 	}
@@ -269,7 +406,7 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 	ok, when, reasonMsg, err := r.shouldScan(*obj, startTime)
 	if err != nil {
 		e := fmt.Errorf("failed to determine if it's scan time: %w", err)
-		conditions.MarkFalse(obj, meta.ReadyCondition, metav1.StatusFailure, e.Error())
+		conditions.MarkFalse(obj, meta.ReadyCondition, metav1.StatusFailure, r.redact(e.Error()))
 		result, retErr = ctrl.Result{}, e
 		return
 	}
@@ -283,14 +420,72 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 			return
 		}
 
-		tags, err := r.scan(ctx, obj, ref, opts)
+		scanCtx, scanDone := r.scans.start(ctx, client.ObjectKeyFromObject(obj).String())
+		scanCtx, summary := withScanSummary(scanCtx)
+		scanStart := time.Now()
+		tags, err := r.scan(scanCtx, obj, ref, opts, creds)
+		scanDone()
+		scanDuration := time.Since(scanStart)
+		if err != nil && errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			// The scan was cancelled as the object is being deleted, which
+			// is handled by the next reconciliation.
+			ctrl.LoggerFrom(ctx).Info("scan cancelled, the object is being deleted")
+			result, retErr = ctrl.Result{}, nil
+			return
+		}
 		if err != nil {
+			scanResult := scanResultFailed
+
+			// Drop any cached credentials rejected by the registry, so that
+			// they're obtained again on the next attempt.
+			if isAuthError(err) {
+				r.authCache.delete(client.ObjectKeyFromObject(obj).String())
+				scanResult = scanResultAuthFailed
+			}
+			if registryStatusCode(err) == http.StatusTooManyRequests {
+				scanResult = scanResultRateLimited
+			}
+			recordScan(obj.GetName(), obj.GetNamespace(), scanResult)
+			summary.log(ctx, canonicalName, tags, scanDuration, scanResult)
+
+			// If the registry rate limited the scan, scan again after the
+			// duration it asked for, if any, or else back off exponentially
+			// from the scan interval, rather than with the generic backoff
+			// of the failures.
+			if scanResult == scanResultRateLimited {
+				e := fmt.Errorf("scan rate limited by the registry: %w", err)
+				conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.RateLimitedReason, r.redact(e.Error()))
+				var backoff time.Duration
+				var rlErr *rateLimitError
+				if errors.As(err, &rlErr) {
+					backoff = rlErr.retryAfter
+				} else {
+					backoff = r.rateLimits.next(client.ObjectKeyFromObject(obj).String(), r.scanInterval(*obj))
+				}
+				result, retErr = ctrl.Result{RequeueAfter: withJitter(backoff)}, nil
+				return
+			}
+			r.rateLimits.reset(client.ObjectKeyFromObject(obj).String())
+			// Report credentials that are known to have expired distinctly
+			// from wrong credentials, as they need to be renewed.
+			if scanResult == scanResultAuthFailed {
+				if cred, ok := expiredCredentials(creds, time.Now()); ok {
+					e := fmt.Errorf("scan failed: %s credentials expired at %s: %w",
+						cred.name, cred.expiresAt.Format(time.RFC3339), err)
+					conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.CredentialsExpiredReason, r.redact(e.Error()))
+					result, retErr = ctrl.Result{}, e
+					return
+				}
+			}
 			e := fmt.Errorf("scan failed: %w", err)
-			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.ReadOperationFailedReason, e.Error())
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.ReadOperationFailedReason, r.redact(e.Error()))
 			result, retErr = ctrl.Result{}, e
 			return
 		}
+		r.rateLimits.reset(client.ObjectKeyFromObject(obj).String())
 		foundTags = tags
+		recordScan(obj.GetName(), obj.GetNamespace(), scanResultSuccess)
+		summary.log(ctx, canonicalName, tags, scanDuration, scanResultSuccess)
 
 		nextScanMsg = fmt.Sprintf("next scan in %s", when.String())
 		// Check if new tags were found.
@@ -309,7 +504,7 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 	}
 
 	// Set the observations on the status.
-	obj.Status.CanonicalImageName = ref.Context().String()
+	obj.Status.CanonicalImageName = canonicalName
 	obj.Status.ObservedExclusionList = obj.GetExclusionList()
 
 	// Remove any stale Ready condition, most likely False, set above. Its value
@@ -323,27 +518,185 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 	return
 }
 
-// setAuthOptions returns authentication options required to scan a repository.
-func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference) ([]remote.Option, error) {
+// credentialSource is a named authentication option used to access a
+// registry.
+type credentialSource struct {
+	name   string
+	option remote.Option
+	// resolve, if set, is called to get the option when the credential
+	// source is attempted, deferring the retrieval of the credentials until
+	// they're needed. A nil option means no credentials are configured and
+	// the source is skipped.
+	resolve func(ctx context.Context) (remote.Option, error)
+	// expiresAt, if not zero, is the time at which the credentials are known
+	// to expire.
+	expiresAt time.Time
+}
+
+// expiredCredentials returns the first of the credential sources known to
+// have expired at the given time.
+func expiredCredentials(credentials []credentialSource, now time.Time) (credentialSource, bool) {
+	for _, cred := range credentials {
+		if !cred.expiresAt.IsZero() && !now.Before(cred.expiresAt) {
+			return cred, true
+		}
+	}
+	return credentialSource{}, false
+}
+
+// githubAuth returns a function minting an installation token of the GitHub
+// App in the given Secret, to access the GitHub Container Registry. The token
+// is cached until shortly before it expires. Other registries are accessed
+// without credentials.
+func (r *ImageRepositoryReconciler) githubAuth(obj *imagev1.ImageRepository, ref name.Reference, appSecret corev1.Secret) func(context.Context) (authn.Authenticator, error) {
+	return func(ctx context.Context) (authn.Authenticator, error) {
+		if ref.Context().RegistryStr() != github.RegistryHost {
+			ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("skipping the github provider for a registry other than "+github.RegistryHost,
+				"registry", ref.Context().RegistryStr())
+			return nil, oci.ErrUnconfiguredProvider
+		}
+
+		key := client.ObjectKeyFromObject(obj).String()
+		if auth, ok := r.authCache.get(key, obj.Spec.Image, oci.ProviderGeneric); ok {
+			return auth, nil
+		}
+		app, err := github.AppFromSecret(appSecret)
+		if err != nil {
+			return nil, err
+		}
+		token, expiresAt, err := app.InstallationToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		auth := &authn.Basic{Username: github.RegistryUsername, Password: token}
+		r.authCache.setUntil(key, obj.Spec.Image, oci.ProviderGeneric, auth, expiresAt.Add(-githubTokenExpiryMargin))
+		return auth, nil
+	}
+}
+
+// gcpAuth returns a function minting an access token of the Google service
+// account bound to the given Kubernetes service account with Workload
+// Identity, to access the Google registries. The token is cached until
+// shortly before it expires. Other registries are accessed without
+// credentials.
+func (r *ImageRepositoryReconciler) gcpAuth(obj *imagev1.ImageRepository, ref name.Reference, serviceAccount *corev1.ServiceAccount, googleServiceAccount string) func(context.Context) (authn.Authenticator, error) {
+	return func(ctx context.Context) (authn.Authenticator, error) {
+		if login.ImageRegistryProvider(obj.Spec.Image, ref) != oci.ProviderGCP {
+			ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("skipping the gcp provider for a registry other than the Google registries",
+				"registry", ref.Context().RegistryStr())
+			return nil, oci.ErrUnconfiguredProvider
+		}
+
+		// The Google service account is part of the cached image, so that a
+		// new binding mints a new token.
+		key := client.ObjectKeyFromObject(obj).String()
+		cachedImage := obj.Spec.Image + "#" + googleServiceAccount
+		if auth, ok := r.authCache.get(key, cachedImage, oci.ProviderGCP); ok {
+			return auth, nil
+		}
+		newIdentity := r.newGCPIdentity
+		if newIdentity == nil {
+			newIdentity = newGCPIdentity
+		}
+		identity, err := newIdentity(googleServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+		subjectToken, err := r.serviceAccountToken(ctx, serviceAccount, identity.Cluster.TokenAudience())
+		if err != nil {
+			return nil, err
+		}
+		token, expiresAt, err := identity.AccessToken(ctx, subjectToken)
+		if err != nil {
+			return nil, err
+		}
+		auth := &authn.Basic{Username: gcp.RegistryUsername, Password: token}
+		r.authCache.setUntil(key, cachedImage, oci.ProviderGCP, auth, expiresAt.Add(-gcpTokenExpiryMargin))
+		return auth, nil
+	}
+}
+
+// newGCPIdentity returns the given Google service account, bound to the
+// Kubernetes service accounts of the cluster the controller runs in.
+func newGCPIdentity(googleServiceAccount string) (*gcp.WorkloadIdentity, error) {
+	cluster, err := gcp.ClusterFromMetadata()
+	if err != nil {
+		return nil, err
+	}
+	return &gcp.WorkloadIdentity{Cluster: *cluster, ServiceAccount: googleServiceAccount}, nil
+}
+
+// serviceAccountToken requests a token of the given service account for the
+// given audience.
+func (r *ImageRepositoryReconciler) serviceAccountToken(ctx context.Context, serviceAccount *corev1.ServiceAccount, audience string) (string, error) {
+	tr := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences: []string{audience},
+		},
+	}
+	if err := r.SubResource("token").Create(ctx, serviceAccount, tr); err != nil {
+		return "", fmt.Errorf("failed to create a token for the service account '%s': %w", serviceAccount.GetName(), err)
+	}
+	return tr.Status.Token, nil
+}
+
+// setAuthOptions returns the options required to scan a repository, and the
+// credential sources to attempt, in order, when authenticating with the
+// registry.
+func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference) ([]remote.Option, []credentialSource, error) {
 	timeout := obj.GetTimeout()
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Configure authentication strategy to access the registry.
 	var options []remote.Option
+	var credentials []credentialSource
 	var authSecret corev1.Secret
-	var auth authn.Authenticator
-	var authErr error
+	var getAuth func(ctx context.Context) (authn.Authenticator, error)
+	var authSource string
+	var authExpiresAt time.Time
+
+	// Lookup the service account, for its image pull secrets and its cloud
+	// provider identity.
+	var serviceAccount *corev1.ServiceAccount
+	if obj.Spec.ServiceAccountName != "" {
+		serviceAccount = &corev1.ServiceAccount{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      obj.Spec.ServiceAccountName,
+		}, serviceAccount); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	if obj.Spec.SecretRef != nil {
 		if err := r.Get(ctx, types.NamespacedName{
 			Namespace: obj.GetNamespace(),
 			Name:      obj.Spec.SecretRef.Name,
 		}, &authSecret); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		getAuth = func(context.Context) (authn.Authenticator, error) {
+			return secret.AuthFromSecret(authSecret, ref)
+		}
+		authSource = "secretRef"
+		if obj.GetProvider() == "github" {
+			getAuth = r.githubAuth(obj, ref, authSecret)
+			authSource = "provider"
+		}
+		if v, ok := authSecret.GetAnnotations()[imagev1.CredentialsExpiresAtAnnotation]; ok {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s annotation in secret '%s': %w",
+					imagev1.CredentialsExpiresAtAnnotation, authSecret.GetName(), err)
+			}
+			authExpiresAt = t
 		}
-		auth, authErr = secret.AuthFromSecret(authSecret, ref)
 	} else {
+		if obj.GetProvider() == "github" {
+			return nil, nil, fmt.Errorf("the github provider requires a secretRef holding the GitHub App credentials")
+		}
+
 		// Build login provider options and use it to attempt registry login.
 		opts := login.ProviderOptions{}
 		switch obj.GetProvider() {
@@ -356,21 +709,97 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 		default:
 			opts = r.DeprecatedLoginOpts
 		}
-		auth, authErr = login.NewManager().Login(ctx, obj.Spec.Image, ref, opts)
-	}
-	if authErr != nil {
-		// If it's not unconfigured provider error, abort reconciliation.
-		// Continue reconciliation if it's unconfigured providers for scanning
-		// public repositories.
-		if !errors.Is(authErr, oci.ErrUnconfiguredProvider) {
-			return nil, authErr
+		getAuth = func(ctx context.Context) (authn.Authenticator, error) {
+			// With the aws provider, the IAM role of the service account is
+			// assumed rather than using the identity of the controller. With
+			// the azure provider, the managed identity of the service account
+			// is used.
+			var identity loginIdentity
+			switch obj.GetProvider() {
+			case "aws":
+				identity.aws = r.awsIdentityFor(ctx, obj.Spec.Image, serviceAccount)
+			case "azure":
+				if serviceAccount != nil {
+					identity.azureClientID = serviceAccount.GetAnnotations()[imagev1.AzureClientIDAnnotation]
+				}
+			}
+
+			// Reuse the authenticator from an earlier login with the
+			// provider until its token is about to expire. The identity is
+			// part of the cached image, so that a new identity logs in
+			// again.
+			key := client.ObjectKeyFromObject(obj).String()
+			cachedImage := obj.Spec.Image + identity.cacheKey()
+			provider := login.ImageRegistryProvider(obj.Spec.Image, ref)
+			// Logging in with the generic provider never yields credentials,
+			// so an entry cached for it was minted by the github provider.
+			if provider != oci.ProviderGeneric {
+				if auth, ok := r.authCache.get(key, cachedImage, provider); ok {
+					return auth, nil
+				}
+			}
+			newManager := r.newLoginManager
+			if newManager == nil {
+				newManager = newLoginManager
+			}
+			manager, err := newManager(ctx, identity)
+			if err != nil {
+				return nil, err
+			}
+			auth, err := manager.Login(ctx, obj.Spec.Image, ref, opts)
+			if err == nil && auth != nil {
+				r.authCache.set(key, cachedImage, provider, auth)
+			}
+			return auth, err
 		}
+		// With the gcp provider, the Google service account bound to the
+		// service account is used rather than the identity of the node. The
+		// service account is only allowed without a binding for its image
+		// pull secrets.
+		if obj.GetProvider() == "gcp" && serviceAccount != nil {
+			if gsa := serviceAccount.GetAnnotations()[gcp.ServiceAccountAnnotation]; gsa != "" {
+				getAuth = r.gcpAuth(obj, ref, serviceAccount, gsa)
+			} else if len(serviceAccount.ImagePullSecrets) == 0 {
+				return nil, nil, fmt.Errorf("the gcp provider requires the service account '%s' to have the '%s' annotation binding it to a Google service account",
+					serviceAccount.GetName(), gcp.ServiceAccountAnnotation)
+			}
+		}
+		authSource = "provider"
 	}
-	if auth != nil {
-		options = append(options, remote.WithAuth(auth))
+
+	if obj.GetAuthPolicy() == imagev1.AuthPolicyPreferAnonymous {
+		// Attempt anonymous access first, and only retrieve the credentials
+		// if the registry rejects it.
+		credentials = append(credentials,
+			credentialSource{name: "anonymous", option: remote.WithAuth(authn.Anonymous)},
+			credentialSource{name: authSource, expiresAt: authExpiresAt, resolve: func(ctx context.Context) (remote.Option, error) {
+				auth, err := getAuth(ctx)
+				if err != nil && !errors.Is(err, oci.ErrUnconfiguredProvider) {
+					return nil, err
+				}
+				if auth == nil {
+					return nil, nil
+				}
+				return remote.WithAuth(auth), nil
+			}},
+		)
+	} else {
+		auth, authErr := getAuth(ctx)
+		if authErr != nil {
+			// If it's not unconfigured provider error, abort reconciliation.
+			// Continue reconciliation if it's unconfigured providers for
+			// scanning public repositories.
+			if !errors.Is(authErr, oci.ErrUnconfiguredProvider) {
+				return nil, nil, authErr
+			}
+		}
+		if auth != nil {
+			credentials = append(credentials, credentialSource{name: authSource, option: remote.WithAuth(auth), expiresAt: authExpiresAt})
+		}
 	}
 
 	// Load any provided certificate.
+	var certTransport *http.Transport
 	if obj.Spec.CertSecretRef != nil {
 		var certSecret corev1.Secret
 		if obj.Spec.SecretRef != nil && obj.Spec.SecretRef.Name == obj.Spec.CertSecretRef.Name {
@@ -380,37 +809,33 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 				Namespace: obj.GetNamespace(),
 				Name:      obj.Spec.CertSecretRef.Name,
 			}, &certSecret); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 
 		tr, err := secret.TransportFromKubeTLSSecret(&certSecret)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if tr.TLSClientConfig == nil {
 			tr, err = secret.TransportFromSecret(&certSecret)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if tr.TLSClientConfig != nil {
 				ctrl.LoggerFrom(ctx).
 					Info("warning: specifying TLS auth data via `certFile`/`keyFile`/`caFile` is deprecated, please use `tls.crt`/`tls.key`/`ca.crt` instead")
 			}
 		}
-		options = append(options, remote.WithTransport(tr))
+		certTransport = tr
 	}
+	transport := r.registryTransport(certTransport)
+	if obj.Spec.Scheme != "" {
+		transport = &schemeTransport{base: transport, scheme: obj.Spec.Scheme, host: ref.Context().RegistryStr()}
+	}
+	options = append(options, remote.WithTransport(transport))
 
-	if obj.Spec.ServiceAccountName != "" {
-		serviceAccount := corev1.ServiceAccount{}
-		// Lookup service account
-		if err := r.Get(ctx, types.NamespacedName{
-			Namespace: obj.GetNamespace(),
-			Name:      obj.Spec.ServiceAccountName,
-		}, &serviceAccount); err != nil {
-			return nil, err
-		}
-
+	if serviceAccount != nil {
 		if len(serviceAccount.ImagePullSecrets) > 0 {
 			imagePullSecrets := make([]corev1.Secret, len(serviceAccount.ImagePullSecrets))
 			for i, ips := range serviceAccount.ImagePullSecrets {
@@ -419,19 +844,36 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 					Namespace: obj.GetNamespace(),
 					Name:      ips.Name,
 				}, &saAuthSecret); err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				imagePullSecrets[i] = saAuthSecret
 			}
 			keychain, err := k8schain.NewFromPullSecrets(ctx, imagePullSecrets)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			options = append(options, remote.WithAuthFromKeychain(keychain))
+			credentials = append(credentials, credentialSource{name: "serviceAccount", option: remote.WithAuthFromKeychain(keychain)})
 		}
 	}
 
-	return options, nil
+	return options, credentials, nil
+}
+
+// registryTransport returns the transport used to connect to the registry.
+// It's the given transport built from the certificate secret reference if
+// set, else the controller wide default transport if set, else the default
+// transport of the registry client. The transport records the Retry-After of
+// the rate limited responses.
+func (r *ImageRepositoryReconciler) registryTransport(certTransport *http.Transport) http.RoundTripper {
+	base := remote.DefaultTransport
+	switch {
+	case certTransport != nil:
+		base = certTransport
+	case r.DefaultTransport != nil:
+		// Use the transport with the controller wide CA certificates.
+		base = r.DefaultTransport
+	}
+	return &rateLimitTransport{base: base}
 }
 
 // shouldScan takes an image repo and the time now, and returns whether
@@ -448,7 +890,7 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 //
 // Else it returns with next scan time.
 func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now time.Time) (bool, time.Duration, string, error) {
-	scanInterval := obj.Spec.Interval.Duration
+	scanInterval := r.scanInterval(obj)
 
 	// Never scanned; do it now.
 	lastScanResult := obj.Status.LastScanResult
@@ -468,11 +910,11 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 
 	// If the canonical image name of the image is different from the last
 	// observed name, scan now.
-	ref, err := parseImageReference(obj.Spec.Image, obj.Spec.Insecure)
+	_, canonicalName, err := registry.Canonicalize(obj.Spec.Image, obj.Spec.Insecure)
 	if err != nil {
 		return false, scanInterval, "", err
 	}
-	if ref.Context().String() != obj.Status.CanonicalImageName {
+	if canonicalName != obj.Status.CanonicalImageName {
 		return true, scanInterval, scanReasonNewImageName, nil
 	}
 
@@ -488,7 +930,7 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 	// FIXME If the repo exists, has been
 	// scanned, and doesn't have any tags, this will mean a scan every
 	// time the resource comes up for reconciliation.
-	tags, err := r.Database.Tags(obj.Status.CanonicalImageName)
+	tags, err := r.Database.TagsWithOptions(obj.Status.CanonicalImageName, database.TagQuery{Limit: 1})
 	if err != nil {
 		return false, scanInterval, "", err
 	}
@@ -503,36 +945,178 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 	return false, when, "", nil
 }
 
+// scanInterval returns the scan interval of the ImageRepository with the
+// jitter added to it. The jitter is the same across reconciliations, so that
+// the scans are evenly spaced.
+func (r *ImageRepositoryReconciler) scanInterval(obj imagev1.ImageRepository) time.Duration {
+	interval := obj.Spec.Interval.Duration
+	if r.ScanJitter <= 0 {
+		return interval
+	}
+	h := fnv.New64a()
+	h.Write([]byte(obj.GetNamespace() + "/" + obj.GetName()))
+	frac := float64(h.Sum64()) / float64(math.MaxUint64)
+	return interval + time.Duration(frac*r.ScanJitter*float64(interval))
+}
+
 // scan performs repository scanning and writes the scanned result in the
 // internal database and populates the status of the ImageRepository.
-func (r *ImageRepositoryReconciler) scan(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference, options []remote.Option) (int, error) {
-	timeout := obj.GetTimeout()
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	options = append(options, remote.WithContext(ctx))
-
-	tags, err := remote.List(ref.Context(), options...)
+// The credential sources are attempted in order, moving on to the next one
+// when the registry rejects the credentials.
+func (r *ImageRepositoryReconciler) scan(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference, options []remote.Option, credentials []credentialSource) (int, error) {
+	ctx, retryAfter := withRetryAfterRecorder(ctx)
+	tags, source, sourceOptions, err := r.listTagsWithMirrors(ctx, obj, ref, options, credentials)
+	obj.Status.LastScanHTTPStatusCode = registryStatusCode(err)
 	if err != nil {
+		if d := retryAfter.get(); d > 0 && obj.Status.LastScanHTTPStatusCode == http.StatusTooManyRequests {
+			return 0, &rateLimitError{err: err, retryAfter: d}
+		}
 		return 0, err
 	}
 
+	// The tags listed inline that the registry doesn't know of are reported
+	// without failing the scan, they are checked again in the next one.
+	if unknown := unknownTags(obj.Spec.Tags, tags); len(unknown) > 0 {
+		eventLogf(ctx, r.EventRecorder, obj, corev1.EventTypeWarning, imagev1.ReadOperationFailedReason,
+			"tags not found in the repository: %s", strings.Join(unknown, ", "))
+	}
+
+	listedTags := len(tags)
 	filteredTags, err := filterOutTags(tags, obj.GetExclusionList())
 	if err != nil {
 		return 0, err
 	}
 
+	// Keep the tags of the given artifact types, looked up from the registry
+	// that served the tags.
+	if len(obj.Spec.ArtifactTypes) > 0 {
+		typesCtx, cancel := context.WithTimeout(ctx, obj.GetScanTimeout())
+		filteredTags, err = filterArtifactTypes(typesCtx, source, filteredTags, sourceOptions, obj.Spec.ArtifactTypes, r.ScanConcurrency)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Keep the tags up to the scan limit, in the order returned by the
+	// registry.
+	var truncated bool
+	if limit := obj.Spec.ScanLimit; limit > 0 && len(filteredTags) > limit {
+		filteredTags = filteredTags[:limit]
+		truncated = true
+	}
+
+	// getLatestTags sorts the tags it is given, which must be stored in the
+	// order returned by the registry.
+	latestTags := getLatestTags(slices.Clone(filteredTags))
+
+	// Compare the tags with the ones of the last scan, to skip the work that
+	// only depends on them when they are unchanged.
+	digest := tagsDigest(filteredTags)
+	lastResult := obj.Status.LastScanResult
+	unchanged := lastResult != nil && lastResult.TagsDigest == digest
+
+	// Look up the artifacts referring to the latest tags from the registry
+	// that served the tags, unless they were all looked up for the same tags
+	// in the last scan. The tags that failed to be looked up don't fail the
+	// scan, they are reported and looked up again in the next one.
+	var referrers []imagev1.TagReferrers
+	var fetchedReferrers, failedTags int
+	var lastTagError string
+	if obj.Spec.ScanReferrers && unchanged && lastResult.Referrers != nil &&
+		len(lastResult.Referrers) == len(lastResult.LatestTags) {
+		referrers = lastResult.Referrers
+	} else if obj.Spec.ScanReferrers {
+		referrersCtx, cancel := context.WithTimeout(ctx, obj.GetScanTimeout())
+		referrers, err = listReferrers(referrersCtx, source, latestTags, sourceOptions, r.ScanConcurrency)
+		cancel()
+		fetchedReferrers = len(referrers)
+		if err != nil {
+			failedTags, lastTagError, err = r.tagLookupErrors(ctx, obj, "referrers", err)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
 	canonicalName := ref.Context().String()
-	if err := r.Database.SetTags(canonicalName, filteredTags); err != nil {
-		return 0, fmt.Errorf("failed to set tags for %q: %w", canonicalName, err)
+	storedTags, err := r.Database.Tags(canonicalName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read tags for %q: %w", canonicalName, err)
+	}
+	// The tag churn counts the tags added and removed since the last scan.
+	added, removed := database.DiffTags(storedTags, filteredTags)
+	churn := len(added) + len(removed)
+	scanSummaryFrom(ctx).setNewTags(len(added))
+
+	// The stored tags are checked rather than the digest of the last scan,
+	// as the database may have been recreated since.
+	if !slices.Equal(storedTags, filteredTags) {
+		if err := r.Database.SetTags(canonicalName, filteredTags); err != nil {
+			return 0, fmt.Errorf("failed to set tags for %q: %w", canonicalName, err)
+		}
+	}
+
+	// Look up the sizes of the images of the tags whose size isn't recorded
+	// yet, from the registry that served the tags. As with the referrers, the
+	// tags that failed to be looked up are looked up again in the next scan.
+	var fetchedSizes int
+	if obj.Spec.ScanSizes {
+		recorded, err := r.Database.TagSizes(canonicalName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read tag sizes for %q: %w", canonicalName, err)
+		}
+		var unsized []string
+		for _, tag := range filteredTags {
+			if _, ok := recorded[tag]; !ok {
+				unsized = append(unsized, tag)
+			}
+		}
+		if len(unsized) > 0 {
+			sizesCtx, cancel := context.WithTimeout(ctx, obj.GetScanTimeout())
+			sizes, err := listTagSizes(sizesCtx, source, unsized, sourceOptions, r.ScanConcurrency)
+			cancel()
+			if err != nil {
+				failed, lastError, err := r.tagLookupErrors(ctx, obj, "sizes", err)
+				if err != nil {
+					return 0, err
+				}
+				failedTags += failed
+				lastTagError = lastError
+			}
+			if len(sizes) > 0 {
+				if err := r.Database.SetTagSizes(canonicalName, sizes); err != nil {
+					return 0, fmt.Errorf("failed to set tag sizes for %q: %w", canonicalName, err)
+				}
+			}
+			fetchedSizes = len(sizes)
+		}
 	}
 
 	scanTime := metav1.Now()
 	obj.Status.LastScanResult = &imagev1.ScanResult{
-		TagCount:   len(filteredTags),
-		ScanTime:   scanTime,
-		LatestTags: getLatestTags(filteredTags),
+		TagCount:        len(filteredTags),
+		ScanTime:        scanTime,
+		LatestTags:      latestTags,
+		Truncated:       truncated,
+		Registry:        source.Context().RegistryStr(),
+		Referrers:       referrers,
+		TagsDigest:      digest,
+		Unchanged:       unchanged,
+		ListedTagCount:  listedTags,
+		FetchedTagCount: fetchedReferrers + fetchedSizes,
+		FailedTagCount:  failedTags,
+		LastTagError:    lastTagError,
+	}
+	recordTagChurn(obj.GetName(), obj.GetNamespace(), churn)
+	recordTagCount(obj.GetName(), obj.GetNamespace(), len(filteredTags))
+
+	storedSize, err := r.Database.RepoSize(canonicalName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the stored size of %q: %w", canonicalName, err)
 	}
+	recordStoredSize(obj.GetName(), obj.GetNamespace(), storedSize)
+	r.checkStoredSize(ctx, obj, storedSize)
 
 	// If the reconcile request annotation was set, consider it
 	// handled (NB it doesn't matter here if it was changed since last
@@ -544,22 +1128,685 @@ func (r *ImageRepositoryReconciler) scan(ctx context.Context, obj *imagev1.Image
 	return len(filteredTags), nil
 }
 
+// checkStoredSize marks the ImageRepository with the StorageThresholdExceeded
+// condition when its records in the database are larger than the threshold,
+// emitting a warning event when the threshold is first exceeded, and removes
+// the condition otherwise.
+func (r *ImageRepositoryReconciler) checkStoredSize(ctx context.Context, obj *imagev1.ImageRepository, size int64) {
+	if r.RepoSizeThreshold <= 0 || size <= r.RepoSizeThreshold {
+		conditions.Delete(obj, imagev1.StorageThresholdExceededCondition)
+		return
+	}
+	msg := fmt.Sprintf("the %d tags of the repository take %d bytes in the database, over the threshold of %d bytes",
+		obj.Status.LastScanResult.TagCount, size, r.RepoSizeThreshold)
+	if !conditions.IsTrue(obj, imagev1.StorageThresholdExceededCondition) {
+		eventLogf(ctx, r.EventRecorder, obj, corev1.EventTypeWarning, imagev1.RepositoryTooLargeReason, "%s", msg)
+	}
+	conditions.MarkTrue(obj, imagev1.StorageThresholdExceededCondition, imagev1.RepositoryTooLargeReason, "%s", msg)
+}
+
+// tagLookupErrors handles the error of looking up the given property, e.g.
+// the referrers, of the tags of the object. Only the errors of individual
+// tags are aggregated: they're reported in a warning event and it returns
+// their number along with the last one, truncated, for the scan result. Any
+// other error is returned as is.
+func (r *ImageRepositoryReconciler) tagLookupErrors(ctx context.Context, obj *imagev1.ImageRepository, property string, err error) (int, string, error) {
+	var agg kerrors.Aggregate
+	if !errors.As(err, &agg) {
+		return 0, "", err
+	}
+	eventLogf(ctx, r.EventRecorder, obj, corev1.EventTypeWarning, imagev1.ReadOperationFailedReason,
+		"failed to look up the %s of some tags: %s", property, r.redact(err.Error()))
+	errs := agg.Errors()
+	return len(errs), truncateMessage(r.redact(errs[len(errs)-1].Error()), maxTagErrorLength), nil
+}
+
+// maxTagErrorLength is the maximum length of the sample error of the tags that
+// failed to be looked up in the scan result, to keep the status small.
+const maxTagErrorLength = 256
+
+// truncateMessage returns the message truncated to the given length, with an
+// ellipsis replacing the end when it's truncated.
+func truncateMessage(msg string, length int) string {
+	if len(msg) <= length {
+		return msg
+	}
+	const ellipsis = "..."
+	cut := length - len(ellipsis)
+	// Don't cut a multi-byte character.
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + ellipsis
+}
+
+// listTagsWithMirrors lists the tags of the repository from its registry. If
+// the registry is unavailable, the tags are listed from each of the mirrors of
+// the object in order, with the authentication options set up for the mirror,
+// until one of them succeeds. It returns the tags along with the reference and
+// the options that served them. The scan timeout applies to each attempt.
+// When the object lists its tags inline, only those tags are checked instead.
+func (r *ImageRepositoryReconciler) listTagsWithMirrors(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference, options []remote.Option, credentials []credentialSource) ([]string, name.Reference, []remote.Option, error) {
+	timeout := obj.GetScanTimeout()
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	list := tagLister(obj)
+	tags, listOptions, err := listTags(listCtx, ref, options, credentials, list)
+	if err == nil || !isUnavailableError(err) {
+		return tags, ref, listOptions, err
+	}
+
+	for _, mirror := range obj.Spec.Mirrors {
+		if ctx.Err() != nil {
+			return nil, nil, nil, err
+		}
+		ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("registry unavailable, trying mirror", "mirror", mirror, "error", r.redact(err.Error()))
+
+		mirrorRef, mErr := mirrorReference(ref, mirror, obj.Spec.Insecure)
+		if mErr != nil {
+			return nil, nil, nil, mErr
+		}
+		mirrorObj := obj.DeepCopy()
+		mirrorObj.Spec.Image = mirrorRef.Context().String()
+		mirrorOptions, mirrorCredentials, mErr := r.setAuthOptions(ctx, mirrorObj, mirrorRef)
+		if mErr != nil {
+			return nil, nil, nil, fmt.Errorf("failed to configure authentication options for mirror %q: %w", mirror, mErr)
+		}
+
+		mirrorCtx, mirrorCancel := context.WithTimeout(ctx, timeout)
+		tags, listOptions, err = listTags(mirrorCtx, mirrorRef, mirrorOptions, mirrorCredentials, list)
+		mirrorCancel()
+		if err == nil {
+			return tags, mirrorRef, listOptions, nil
+		}
+		if !isUnavailableError(err) {
+			return nil, nil, nil, err
+		}
+	}
+	return nil, nil, nil, err
+}
+
+// tagLister returns the function listing the tags of a repository for the
+// object: the tags listed inline in its spec are checked when there are any,
+// otherwise all the tags are listed up to its scan limit.
+func tagLister(obj *imagev1.ImageRepository) func(context.Context, name.Repository, []remote.Option) ([]string, error) {
+	if inline := obj.Spec.Tags; len(inline) > 0 {
+		return func(ctx context.Context, repo name.Repository, options []remote.Option) ([]string, error) {
+			return checkTags(repo, options, inline)
+		}
+	}
+	stop := scanLimitReached(obj)
+	return func(ctx context.Context, repo name.Repository, options []remote.Option) ([]string, error) {
+		return listTagPages(ctx, repo, options, stop)
+	}
+}
+
+// scanLimitReached returns a function reporting whether more tags than the
+// scan limit of the object remain among the given tags once the exclusion
+// list is applied, in which case the following pages of tags don't need to be
+// listed. It returns nil without a scan limit.
+func scanLimitReached(obj *imagev1.ImageRepository) func([]string) bool {
+	limit := obj.Spec.ScanLimit
+	if limit <= 0 {
+		return nil
+	}
+	return func(tags []string) bool {
+		filtered, err := filterOutTags(tags, obj.GetExclusionList())
+		return err == nil && len(filtered) > limit
+	}
+}
+
+// listTags lists the tags of the repository with list and the first of the
+// given credential sources accepted by the registry. It returns the tags along
+// with the options, including the credentials, that were accepted by the
+// registry.
+func listTags(ctx context.Context, ref name.Reference, options []remote.Option, credentials []credentialSource, list func(context.Context, name.Repository, []remote.Option) ([]string, error)) ([]string, []remote.Option, error) {
+	var tags []string
+	credOptions, err := withCredentials(ctx, options, credentials, func(opts []remote.Option) error {
+		var err error
+		tags, err = list(ctx, ref.Context(), opts)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tags, credOptions, nil
+}
+
+// listTagPages lists the tags of the repository, following the pages of the
+// registry response. If stop is not nil, the listing stops after the first
+// page for which it returns true with the tags listed so far.
+func listTagPages(ctx context.Context, repo name.Repository, options []remote.Option, stop func([]string) bool) ([]string, error) {
+	puller, err := remote.NewPuller(options...)
+	if err != nil {
+		return nil, err
+	}
+	lister, err := puller.Lister(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for lister.HasNext() {
+		page, err := lister.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		if stop != nil && stop(tags) {
+			break
+		}
+	}
+	return tags, nil
+}
+
+// checkTags returns the given tags that exist in the repository, in the given
+// order, by getting their manifest descriptors rather than listing the tags of
+// the repository. Tags unknown to the registry are left out, any other error
+// is returned.
+func checkTags(repo name.Repository, options []remote.Option, tags []string) ([]string, error) {
+	found := []string{}
+	for _, tag := range tags {
+		if _, err := remote.Head(repo.Tag(tag), options...); err != nil {
+			if registryStatusCode(err) == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		found = append(found, tag)
+	}
+	return found, nil
+}
+
+// unknownTags returns the tags listed inline that are missing from the tags
+// found in the repository.
+func unknownTags(inline, found []string) []string {
+	var unknown []string
+	for _, tag := range inline {
+		if !slices.Contains(found, tag) {
+			unknown = append(unknown, tag)
+		}
+	}
+	return unknown
+}
+
+// withCredentials calls fn with the given options and each of the given
+// credential sources in order, along with the context. A credential source
+// that is rejected by the registry is skipped in favour of the next one. Any
+// other error is returned immediately. Credential sources with a resolve
+// function are only resolved when they're attempted. With no credential
+// sources, fn is called anonymously. It returns the options, including the
+// credentials, that were accepted by the registry.
+func withCredentials(ctx context.Context, options []remote.Option, credentials []credentialSource, fn func([]remote.Option) error) ([]remote.Option, error) {
+	if len(credentials) == 0 {
+		if err := fn(append(options[:len(options):len(options)], remote.WithContext(ctx))); err != nil {
+			return nil, err
+		}
+		scanSummaryFrom(ctx).setAuthMethod("anonymous")
+		return options, nil
+	}
+
+	var err error
+	for _, cred := range credentials {
+		option := cred.option
+		if cred.resolve != nil {
+			var rErr error
+			option, rErr = cred.resolve(ctx)
+			if rErr != nil {
+				return nil, fmt.Errorf("failed to retrieve %s credentials: %w", cred.name, rErr)
+			}
+			if option == nil {
+				continue
+			}
+		}
+
+		credOptions := append(options[:len(options):len(options)], option)
+		err = fn(append(credOptions, remote.WithContext(ctx)))
+		if err == nil {
+			ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("authenticated with registry", "credentials", cred.name)
+			scanSummaryFrom(ctx).setAuthMethod(cred.name)
+			return credOptions, nil
+		}
+		if !isAuthError(err) {
+			return nil, err
+		}
+		ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("registry rejected credentials", "credentials", cred.name, "error", err.Error())
+	}
+	return nil, err
+}
+
+// forEachTag calls fn with each of the given tags and its index, for up to
+// concurrency tags at the same time, and returns the errors of the calls by
+// the index of their tag. Once a call fails with an error for which stop
+// returns true, or once the context is done, the context given to the calls
+// is cancelled and no more calls are made; the tags left out have no error.
+func forEachTag(ctx context.Context, tags []string, concurrency int, stop func(error) bool, fn func(ctx context.Context, i int, tag string) error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	lookupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(tags))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		select {
+		case sem <- struct{}{}:
+		case <-lookupCtx.Done():
+		}
+		if lookupCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, tag string) {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = fn(lookupCtx, i, tag)
+			if errs[i] != nil && stop(errs[i]) {
+				cancel()
+			}
+		}(i, tag)
+	}
+	wg.Wait()
+	return errs
+}
+
+// stoppedLookupError returns the error that stopped a lookup of tags made
+// with forEachTag: a rate limited request, or the error of a tag or of the
+// context when the context is done. It returns nil if the lookup completed.
+func stoppedLookupError(ctx context.Context, errs []error) error {
+	for _, err := range errs {
+		if isRateLimitedError(err) {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+	return nil
+}
+
+// isRateLimitedError returns true if the error is a rate limited registry
+// response, after which no more requests are made to the registry.
+func isRateLimitedError(err error) bool {
+	return registryStatusCode(err) == http.StatusTooManyRequests
+}
+
+// withContext returns a copy of the options with the given context, leaving
+// the given options unchanged.
+func withContext(ctx context.Context, options []remote.Option) []remote.Option {
+	return append(options[:len(options):len(options)], remote.WithContext(ctx))
+}
+
+// listReferrers returns the artifacts referring to the images of the given
+// tags of the repository, such as signatures and SBOMs. Up to concurrency tags
+// are looked up at the same time. The tags that can't be looked up are left
+// out of the result and their errors are aggregated in the returned error,
+// unless the registry rate limited a request or the context is done, in which
+// case the lookup stops and only the error is returned.
+func listReferrers(ctx context.Context, ref name.Reference, tags []string, options []remote.Option, concurrency int) ([]imagev1.TagReferrers, error) {
+	results := make([]imagev1.TagReferrers, len(tags))
+	errs := forEachTag(ctx, tags, concurrency, isRateLimitedError, func(ctx context.Context, i int, tag string) error {
+		var err error
+		results[i], err = tagReferrers(ref, tag, withContext(ctx, options))
+		return err
+	})
+	if err := stoppedLookupError(ctx, errs); err != nil {
+		return nil, err
+	}
+
+	var result []imagev1.TagReferrers
+	for i := range tags {
+		if errs[i] == nil {
+			result = append(result, results[i])
+		}
+	}
+	return result, kerrors.NewAggregate(errs)
+}
+
+// tagReferrers returns the artifacts referring to the image of the given tag
+// of the repository.
+func tagReferrers(ref name.Reference, tag string, options []remote.Option) (imagev1.TagReferrers, error) {
+	desc, err := remote.Head(ref.Context().Tag(tag), options...)
+	if err != nil {
+		return imagev1.TagReferrers{}, fmt.Errorf("failed to get the digest of tag %q: %w", tag, err)
+	}
+	idx, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()), options...)
+	if err != nil {
+		return imagev1.TagReferrers{}, fmt.Errorf("failed to list the referrers of tag %q: %w", tag, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return imagev1.TagReferrers{}, fmt.Errorf("failed to read the referrers of tag %q: %w", tag, err)
+	}
+
+	var artifactTypes []string
+	for _, m := range manifest.Manifests {
+		if m.ArtifactType != "" && !slices.Contains(artifactTypes, m.ArtifactType) {
+			artifactTypes = append(artifactTypes, m.ArtifactType)
+		}
+	}
+	sort.Strings(artifactTypes)
+
+	return imagev1.TagReferrers{
+		Tag:           tag,
+		Digest:        desc.Digest.String(),
+		Count:         len(manifest.Manifests),
+		ArtifactTypes: artifactTypes,
+	}, nil
+}
+
+// filterArtifactTypes returns the given tags of the repository whose artifact
+// type is one of the given types, in the given order. Up to concurrency tags
+// are looked up at the same time. The tags unknown to the registry are left
+// out, any other error stops the lookup and is returned.
+func filterArtifactTypes(ctx context.Context, ref name.Reference, tags []string, options []remote.Option, artifactTypes []string, concurrency int) ([]string, error) {
+	found := make([]string, len(tags))
+	stop := func(err error) bool {
+		return registryStatusCode(err) != http.StatusNotFound
+	}
+	errs := forEachTag(ctx, tags, concurrency, stop, func(ctx context.Context, i int, tag string) error {
+		var err error
+		found[i], err = tagArtifactType(ref, tag, withContext(ctx, options))
+		return err
+	})
+
+	// Return the error that stopped the lookup rather than the ones caused
+	// by the cancellation.
+	for _, err := range errs {
+		if err != nil && registryStatusCode(err) != http.StatusNotFound && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for i, tag := range tags {
+		if errs[i] == nil && slices.Contains(artifactTypes, found[i]) {
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// tagArtifactType returns the artifact type of the manifest of the given tag
+// of the repository, which defaults to the media type of its config, or of
+// the manifest itself for an image index.
+func tagArtifactType(ref name.Reference, tag string, options []remote.Option) (string, error) {
+	desc, err := remote.Get(ref.Context().Tag(tag), options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the manifest of tag %q: %w", tag, err)
+	}
+	var manifest struct {
+		ArtifactType string `json:"artifactType,omitempty"`
+		Config       *struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config,omitempty"`
+	}
+	if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+		return "", fmt.Errorf("failed to read the manifest of tag %q: %w", tag, err)
+	}
+	switch {
+	case manifest.ArtifactType != "":
+		return manifest.ArtifactType, nil
+	case desc.MediaType.IsIndex() || manifest.Config == nil:
+		return string(desc.MediaType), nil
+	default:
+		return manifest.Config.MediaType, nil
+	}
+}
+
+// listTagSizes returns the total size of the compressed layers of the image of
+// each of the given tags of the repository. Up to concurrency tags are looked
+// up at the same time. The tags that can't be looked up are left out of the
+// result and their errors are aggregated in the returned error, unless the
+// registry rate limited a request or the context is done, in which case the
+// lookup stops and only the error is returned.
+func listTagSizes(ctx context.Context, ref name.Reference, tags []string, options []remote.Option, concurrency int) (map[string]int64, error) {
+	sizes := make([]int64, len(tags))
+	errs := forEachTag(ctx, tags, concurrency, isRateLimitedError, func(ctx context.Context, i int, tag string) error {
+		var err error
+		sizes[i], err = tagSize(ref, tag, withContext(ctx, options))
+		return err
+	})
+	if err := stoppedLookupError(ctx, errs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(tags))
+	for i, tag := range tags {
+		if errs[i] == nil {
+			result[tag] = sizes[i]
+		}
+	}
+	return result, kerrors.NewAggregate(errs)
+}
+
+// tagSize returns the total size of the compressed layers of the image of the
+// given tag of the repository. The size of an image index is the size of its
+// largest image.
+func tagSize(ref name.Reference, tag string, options []remote.Option) (int64, error) {
+	desc, err := remote.Get(ref.Context().Tag(tag), options...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the manifest of tag %q: %w", tag, err)
+	}
+	if !desc.MediaType.IsIndex() {
+		size, err := layersSize(desc.Manifest)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the manifest of tag %q: %w", tag, err)
+		}
+		return size, nil
+	}
+
+	index, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the image index of tag %q: %w", tag, err)
+	}
+	var largest int64
+	for _, m := range index.Manifests {
+		if !m.MediaType.IsImage() {
+			continue
+		}
+		image, err := remote.Get(ref.Context().Digest(m.Digest.String()), options...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the manifest %s of tag %q: %w", m.Digest, tag, err)
+		}
+		size, err := layersSize(image.Manifest)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the manifest %s of tag %q: %w", m.Digest, tag, err)
+		}
+		largest = max(largest, size)
+	}
+	return largest, nil
+}
+
+// layersSize returns the total size of the layers of the given image
+// manifest, as compressed in the registry.
+func layersSize(b []byte) (int64, error) {
+	manifest, err := v1.ParseManifest(bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size, nil
+}
+
+// ResolveDigest returns the digest of the manifest the given tag of the
+// ImageRepository points to, authenticating with the registry the same way as
+// a scan of the ImageRepository. If a platform in the form os/arch[/variant]
+// is given and the tag points to an image index, it returns the digest of the
+// manifest of the platform in the index instead.
+func (r *ImageRepositoryReconciler) ResolveDigest(ctx context.Context, obj *imagev1.ImageRepository, tag, platform string) (string, error) {
+	if obj.Spec.Insecure && r.NoInsecureRegistries {
+		return "", errors.New("insecure registries are not allowed")
+	}
+	ref, _, err := registry.Canonicalize(obj.Spec.Image, obj.Spec.Insecure)
+	if err != nil {
+		return "", err
+	}
+	var wantPlatform *v1.Platform
+	if platform != "" {
+		wantPlatform, err = v1.ParsePlatform(platform)
+		if err != nil {
+			return "", fmt.Errorf("invalid platform %q: %w", platform, err)
+		}
+	}
+	options, credentials, err := r.setAuthOptions(ctx, obj, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to configure authentication options: %w", err)
+	}
+
+	headCtx, cancel := context.WithTimeout(ctx, obj.GetScanTimeout())
+	defer cancel()
+
+	tagRef := ref.Context().Tag(tag)
+	var digest string
+	if err := retryTransient(headCtx, r.DigestRetries, func() error {
+		_, err := withCredentials(headCtx, options, credentials, func(opts []remote.Option) error {
+			desc, err := remote.Head(tagRef, opts...)
+			if err != nil {
+				return err
+			}
+			// The manifest of a single platform image is used as is.
+			if wantPlatform == nil || !desc.MediaType.IsIndex() {
+				digest = desc.Digest.String()
+				return nil
+			}
+
+			idx, err := remote.Index(tagRef, opts...)
+			if err != nil {
+				return err
+			}
+			manifest, err := idx.IndexManifest()
+			if err != nil {
+				return err
+			}
+			for _, m := range manifest.Manifests {
+				if m.Platform != nil && m.Platform.Satisfies(*wantPlatform) {
+					digest = m.Digest.String()
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: %s is not in the image index", errPlatformNotFound, wantPlatform)
+		})
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to get the digest of tag %q: %w", tag, err)
+	}
+	return digest, nil
+}
+
+// digestRetryDelay is the delay before the first retry of a transient
+// failure, doubled on every following retry.
+var digestRetryDelay = time.Second
+
+// retryTransient calls fn until it succeeds, fails with an error that isn't
+// transient or has been retried the given number of times. The retries stop
+// early when the context is done.
+func retryTransient(ctx context.Context, retries int, fn func() error) error {
+	delay := digestRetryDelay
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= retries || !isTransientError(err) {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("retrying after a transient registry failure",
+			"error", err, "retry", i+1, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientError returns true if the error is a rate limited registry
+// response, or if the registry is unavailable. Like for isUnavailableError,
+// context errors and the other errors, e.g. a platform missing from an image
+// index or an invalid manifest, aren't transient.
+func isTransientError(err error) bool {
+	return isRateLimitedError(err) || isUnavailableError(err)
+}
+
+// registryStatusCode returns the HTTP status code of the registry response
+// that caused the error, or zero if the error isn't a registry response.
+func registryStatusCode(err error) int {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return 0
+	}
+	return terr.StatusCode
+}
+
+// isUnavailableError returns true if the error is a registry response with a
+// server error status code, or a failure to connect to the registry. Context
+// errors, and the other errors like invalid credentials or responses, aren't
+// caused by an unavailable registry.
+func isUnavailableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if code := registryStatusCode(err); code != 0 {
+		return code >= http.StatusInternalServerError
+	}
+	return isConnectionError(err)
+}
+
+// isConnectionError returns true if the error is a failure to connect to the
+// registry or to complete a request, e.g. a refused or reset connection.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// isAuthError returns true if the error is a registry response with an
+// unauthorized or forbidden status code.
+func isAuthError(err error) bool {
+	code := registryStatusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
 // reconcileDelete handles the deletion of the object.
 func (r *ImageRepositoryReconciler) reconcileDelete(ctx context.Context, obj *imagev1.ImageRepository) (ctrl.Result, error) {
 	// Remove our finalizer from the list.
 	controllerutil.RemoveFinalizer(obj, imagev1.ImageFinalizer)
 
+	// Remove the metrics, the cached credentials and the rate limit backoff
+	// of the object.
+	deleteImageRepositoryMetrics(obj.GetName(), obj.GetNamespace())
+	r.authCache.delete(client.ObjectKeyFromObject(obj).String())
+	r.rateLimits.reset(client.ObjectKeyFromObject(obj).String())
+
 	// Stop reconciliation as the object is being deleted.
 	return ctrl.Result{}, nil
 }
 
+// redact returns the message with the credentials redacted.
+func (r *ImageRepositoryReconciler) redact(msg string) string {
+	return redactCredentials(msg, r.RedactPatterns...)
+}
+
 // eventLogf records events, and logs at the same time.
 //
 // This log is different from the debug log in the EventRecorder, in the sense
 // that this is a simple log. While the debug log contains complete details
 // about the event.
 func eventLogf(ctx context.Context, r kuberecorder.EventRecorder, obj runtime.Object, eventType string, reason string, messageFmt string, args ...interface{}) {
-	msg := fmt.Sprintf(messageFmt, args...)
+	msg := redactCredentials(fmt.Sprintf(messageFmt, args...))
 	// Log and emit event.
 	if eventType == corev1.EventTypeWarning {
 		ctrl.LoggerFrom(ctx).Error(errors.New(reason), msg)
@@ -569,30 +1816,14 @@ func eventLogf(ctx context.Context, r kuberecorder.EventRecorder, obj runtime.Ob
 	r.Eventf(obj, eventType, reason, msg)
 }
 
-// parseImageReference parses the given URL into a container registry repository
-// reference. If insecure is set to true, then the registry is deemed to be
-// located at an HTTP endpoint.
-func parseImageReference(url string, insecure bool) (name.Reference, error) {
-	if s := strings.Split(url, "://"); len(s) > 1 {
-		return nil, fmt.Errorf(".spec.image value should not start with URL scheme; remove '%s://'", s[0])
-	}
-
-	var opts []name.Option
-	if insecure {
-		opts = append(opts, name.Insecure)
-	}
-
-	ref, err := name.ParseReference(url, opts...)
+// mirrorReference returns the reference with its registry replaced by the
+// given mirror host, keeping the repository path.
+func mirrorReference(ref name.Reference, mirror string, insecure bool) (name.Reference, error) {
+	mirrorRef, _, err := registry.Canonicalize(mirror+"/"+ref.Context().RepositoryStr(), insecure)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid mirror %q: %w", mirror, err)
 	}
-
-	imageName := strings.TrimPrefix(url, ref.Context().RegistryStr())
-	if s := strings.Split(imageName, ":"); len(s) > 1 {
-		return nil, fmt.Errorf(".spec.image value should not contain a tag; remove ':%s'", s[1])
-	}
-
-	return ref, nil
+	return mirrorRef, nil
 }
 
 // filterOutTags filters the given tags through the given regular expression
@@ -640,6 +1871,16 @@ func getLatestTags(tags []string) []string {
 	return result
 }
 
+// tagsDigest returns the SHA-256 digest of the given list of tags, in order.
+func tagsDigest(tags []string) string {
+	h := sha256.New()
+	for _, t := range tags {
+		h.Write([]byte(t))
+		h.Write([]byte{'\n'})
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
 // isEqualSliceContent compares two string slices to check if they have the same
 // content.
 func isEqualSliceContent(a, b []string) bool {
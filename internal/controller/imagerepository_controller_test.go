@@ -18,33 +18,75 @@ package controller
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/oci"
 	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/patch"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+	"github.com/fluxcd/image-reflector-controller/internal/gcp"
+	"github.com/fluxcd/image-reflector-controller/internal/github"
+	imageref "github.com/fluxcd/image-reflector-controller/internal/registry"
 	"github.com/fluxcd/image-reflector-controller/internal/secret"
 	"github.com/fluxcd/image-reflector-controller/internal/test"
 )
 
 // mockDatabase mocks the image repository database.
 type mockDatabase struct {
-	TagData    []string
-	ReadError  error
-	WriteError error
+	TagData       []string
+	FirstSeenData map[string]time.Time
+	SizeData      map[string]int64
+	StoredSize    int64
+	ReadError     error
+	WriteError    error
 }
 
 // SetTags implements the DatabaseWriter interface of the Database.
@@ -56,6 +98,20 @@ func (db *mockDatabase) SetTags(repo string, tags []string) error {
 	return nil
 }
 
+// SetTagSizes implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetTagSizes(repo string, sizes map[string]int64) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	if db.SizeData == nil {
+		db.SizeData = map[string]int64{}
+	}
+	for tag, size := range sizes {
+		db.SizeData[tag] = size
+	}
+	return nil
+}
+
 // Tags implements the DatabaseReader interface of the Database.
 func (db mockDatabase) Tags(repo string) ([]string, error) {
 	if db.ReadError != nil {
@@ -64,6 +120,43 @@ func (db mockDatabase) Tags(repo string) ([]string, error) {
 	return db.TagData, nil
 }
 
+// TagsWithOptions implements the DatabaseReader interface of the Database.
+func (db mockDatabase) TagsWithOptions(repo string, query database.TagQuery) ([]string, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return query.Apply(slices.Clone(db.TagData), db.FirstSeenData)
+}
+
+// TagsFirstSeen implements the DatabaseReader interface of the Database.
+func (db mockDatabase) TagsFirstSeen(repo string) (map[string]time.Time, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.FirstSeenData, nil
+}
+
+// TagSizes implements the DatabaseReader interface of the Database.
+func (db mockDatabase) TagSizes(repo string) (map[string]int64, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.SizeData, nil
+}
+
+// RepoSize implements the DatabaseSizer interface of the Database.
+func (db mockDatabase) RepoSize(repo string) (int64, error) {
+	if db.ReadError != nil {
+		return 0, db.ReadError
+	}
+	return db.StoredSize, nil
+}
+
+// Size implements the DatabaseSizer interface of the Database.
+func (db mockDatabase) Size() int64 {
+	return db.StoredSize
+}
+
 func TestImageRepositoryReconciler_deleteBeforeFinalizer(t *testing.T) {
 	g := NewWithT(t)
 
@@ -166,10 +259,11 @@ func TestImageRepositoryReconciler_setAuthOptions(t *testing.T) {
 	testServiceAccountWithSecret.ImagePullSecrets = []corev1.LocalObjectReference{{Name: testSecretName}}
 
 	tests := []struct {
-		name          string
-		mockObjs      []client.Object
-		imageRepoSpec imagev1.ImageRepositorySpec
-		wantErr       bool
+		name            string
+		mockObjs        []client.Object
+		imageRepoSpec   imagev1.ImageRepositorySpec
+		wantErr         bool
+		wantCredentials []string
 	}{
 		{
 			name: "no auth options",
@@ -186,6 +280,7 @@ func TestImageRepositoryReconciler_setAuthOptions(t *testing.T) {
 					Name: testSecretName,
 				},
 			},
+			wantCredentials: []string{"secretRef"},
 		},
 		{
 			name: "secret ref with non-existing secret",
@@ -281,6 +376,30 @@ func TestImageRepositoryReconciler_setAuthOptions(t *testing.T) {
 				ServiceAccountName: testServiceAccountName,
 			},
 		},
+		{
+			name:     "secret ref and service account with pull secret",
+			mockObjs: []client.Object{testServiceAccountWithSecret, testSecret},
+			imageRepoSpec: imagev1.ImageRepositorySpec{
+				Image: testImg,
+				SecretRef: &meta.LocalObjectReference{
+					Name: testSecretName,
+				},
+				ServiceAccountName: testServiceAccountName,
+			},
+			wantCredentials: []string{"secretRef", "serviceAccount"},
+		},
+		{
+			name:     "secret ref with prefer anonymous auth policy",
+			mockObjs: []client.Object{testSecret},
+			imageRepoSpec: imagev1.ImageRepositorySpec{
+				Image: testImg,
+				SecretRef: &meta.LocalObjectReference{
+					Name: testSecretName,
+				},
+				AuthPolicy: imagev1.AuthPolicyPreferAnonymous,
+			},
+			wantCredentials: []string{"anonymous", "secretRef"},
+		},
 		{
 			name:     "service account with non-existing pull secret",
 			mockObjs: []client.Object{testServiceAccountWithSecret},
@@ -317,12 +436,394 @@ func TestImageRepositoryReconciler_setAuthOptions(t *testing.T) {
 			ref, err := name.ParseReference(obj.Spec.Image)
 			g.Expect(err).ToNot(HaveOccurred())
 
-			_, err = r.setAuthOptions(ctx, obj, ref)
+			_, creds, err := r.setAuthOptions(ctx, obj, ref)
 			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if tt.wantCredentials != nil {
+				var names []string
+				for _, c := range creds {
+					names = append(names, c.name)
+				}
+				g.Expect(names).To(Equal(tt.wantCredentials))
+			}
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_gcpIdentity(t *testing.T) {
+	testImage := "europe-docker.pkg.dev/my-project/repo/image"
+	testGSA := "reader@my-project.iam.gserviceaccount.com"
+	testNamespace := "test-ns"
+	cluster := gcp.Cluster{ProjectID: "my-project", Location: "europe-west1", Name: "my-cluster"}
+
+	// The Google APIs exchange the token of the service account for an
+	// access token of the Google service account.
+	var exchanges int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sts", func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "federated-token"})
+	})
+	mux.HandleFunc("/iam/projects/-/serviceAccounts/"+testGSA+":generateAccessToken", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"accessToken": "access-token", "expireTime": time.Now().Add(time.Hour)})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name            string
+		image           string
+		annotations     map[string]string
+		pullSecrets     []corev1.LocalObjectReference
+		authPolicy      string
+		wantErr         string
+		wantCredentials []string
+		wantAuth        authn.Authenticator
+	}{
+		{
+			name:            "bound Google service account",
+			image:           testImage,
+			annotations:     map[string]string{gcp.ServiceAccountAnnotation: testGSA},
+			wantCredentials: []string{"provider"},
+			wantAuth:        &authn.Basic{Username: gcp.RegistryUsername, Password: "access-token"},
+		},
+		{
+			name:    "no binding",
+			image:   testImage,
+			wantErr: "the gcp provider requires the service account 'reader' to have the 'iam.gke.io/gcp-service-account' annotation",
+		},
+		{
+			// The node identity is only logged in with when the registry
+			// rejects the anonymous access.
+			name:            "no binding with pull secrets",
+			image:           testImage,
+			pullSecrets:     []corev1.LocalObjectReference{{Name: "pull-secret"}},
+			authPolicy:      imagev1.AuthPolicyPreferAnonymous,
+			wantCredentials: []string{"anonymous", "provider", "serviceAccount"},
+		},
+		{
+			name:            "other registry",
+			image:           "example.com/foo/bar",
+			annotations:     map[string]string{gcp.ServiceAccountAnnotation: testGSA},
+			wantCredentials: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			exchanges = 0
+
+			sa := &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "reader",
+					Namespace:   testNamespace,
+					Annotations: tt.annotations,
+				},
+				ImagePullSecrets: tt.pullSecrets,
+			}
+			pullSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: testNamespace},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{".dockerconfigjson": []byte(`{"auths":{}}`)},
+			}
+
+			// The fake client doesn't support creating tokens.
+			c := fake.NewClientBuilder().
+				WithObjects(sa, pullSecret).
+				WithInterceptorFuncs(interceptor.Funcs{
+					SubResourceCreate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, subResourceObj client.Object, opts ...client.SubResourceCreateOption) error {
+						tr := subResourceObj.(*authenticationv1.TokenRequest)
+						g.Expect(tr.Spec.Audiences).To(Equal([]string{cluster.TokenAudience()}))
+						tr.Status.Token = "k8s-token"
+						return nil
+					},
+				}).
+				Build()
+
+			r := &ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Client:        c,
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+				authCache:     newAuthCache(),
+				newGCPIdentity: func(gsa string) (*gcp.WorkloadIdentity, error) {
+					return &gcp.WorkloadIdentity{
+						Cluster:           cluster,
+						ServiceAccount:    gsa,
+						STSURL:            srv.URL + "/sts",
+						IAMCredentialsURL: srv.URL + "/iam",
+					}, nil
+				},
+			}
+
+			obj := &imagev1.ImageRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "repo",
+					Namespace: testNamespace,
+				},
+				Spec: imagev1.ImageRepositorySpec{
+					Image:              tt.image,
+					Provider:           "gcp",
+					ServiceAccountName: sa.Name,
+					AuthPolicy:         tt.authPolicy,
+				},
+			}
+
+			ref, err := name.ParseReference(tt.image)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, creds, err := r.setAuthOptions(context.TODO(), obj, ref)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			var names []string
+			for _, c := range creds {
+				names = append(names, c.name)
+			}
+			g.Expect(names).To(Equal(tt.wantCredentials))
+			if tt.wantAuth == nil {
+				return
+			}
+
+			auth, ok := r.authCache.get(client.ObjectKeyFromObject(obj).String(), tt.image+"#"+testGSA, oci.ProviderGCP)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(auth).To(Equal(tt.wantAuth))
+
+			// The access token is reused until it expires.
+			_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(exchanges).To(Equal(1))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_registryTransport(t *testing.T) {
+	defaultTransport := &http.Transport{}
+	certTransport := &http.Transport{}
+
+	tests := []struct {
+		name             string
+		defaultTransport *http.Transport
+		certTransport    *http.Transport
+		wantBase         http.RoundTripper
+	}{
+		{
+			name:     "without default transport",
+			wantBase: remote.DefaultTransport,
+		},
+		{
+			name:             "with default transport",
+			defaultTransport: defaultTransport,
+			wantBase:         defaultTransport,
+		},
+		{
+			name:             "with default transport and cert transport",
+			defaultTransport: defaultTransport,
+			certTransport:    certTransport,
+			wantBase:         certTransport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &ImageRepositoryReconciler{
+				DefaultTransport: tt.defaultTransport,
+			}
+
+			tr := r.registryTransport(tt.certTransport)
+			g.Expect(tr).To(BeAssignableToTypeOf(&rateLimitTransport{}))
+			g.Expect(tr.(*rateLimitTransport).base).To(BeIdenticalTo(tt.wantBase))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_noInsecureRegistries(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	obj := &imagev1.ImageRepository{}
+	obj.Name = "insecure-repo"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.Image = "registry.local:5000/foo/bar"
+	obj.Spec.Insecure = true
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj).WithStatusSubresource(obj).Build()
+	r := &ImageRepositoryReconciler{
+		Client:               c,
+		EventRecorder:        record.NewFakeRecorder(32),
+		Database:             &mockDatabase{},
+		NoInsecureRegistries: true,
+		patchOptions:         getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.IsStalled(obj)).To(BeTrue())
+	g.Expect(conditions.GetReason(obj, meta.StalledCondition)).To(Equal(imagev1.InsecureRegistryNotAllowedReason))
+}
+
+func TestImageRepositoryReconciler_expiredCredentials(t *testing.T) {
+	username := "robot$ci"
+	registryServer := test.NewAuthenticatedRegistryServer(username, "current")
+	defer registryServer.Close()
+	imgRepo := test.RegistryName(registryServer) + "/convenient"
+
+	tests := []struct {
+		name       string
+		expiresAt  string
+		wantReason string
+	}{
+		{
+			name:       "without expiry",
+			wantReason: imagev1.ReadOperationFailedReason,
+		},
+		{
+			name:       "not expired",
+			expiresAt:  time.Now().Add(time.Hour).Format(time.RFC3339),
+			wantReason: imagev1.ReadOperationFailedReason,
+		},
+		{
+			name:       "expired",
+			expiresAt:  time.Now().Add(-time.Hour).Format(time.RFC3339),
+			wantReason: imagev1.CredentialsExpiredReason,
+		},
+		{
+			name:       "invalid expiry",
+			expiresAt:  "tomorrow",
+			wantReason: imagev1.AuthenticationFailedReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+			authSecret := &corev1.Secret{}
+			authSecret.Name = "robot-creds"
+			authSecret.Namespace = "default"
+			authSecret.Type = corev1.SecretTypeDockerConfigJson
+			authSecret.Data = map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{%q:{"username":%q,"password":"stale"}}}`,
+					test.RegistryName(registryServer), username)),
+			}
+			if tt.expiresAt != "" {
+				authSecret.Annotations = map[string]string{imagev1.CredentialsExpiresAtAnnotation: tt.expiresAt}
+			}
+
+			obj := &imagev1.ImageRepository{}
+			obj.Name = "robot-repo"
+			obj.Namespace = "default"
+			obj.Generation = 1
+			obj.Spec.Image = imgRepo
+			obj.Spec.SecretRef = &meta.LocalObjectReference{Name: authSecret.Name}
+
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj, authSecret).WithStatusSubresource(obj).Build()
+			r := &ImageRepositoryReconciler{
+				Client:        c,
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			sp := patch.NewSerialPatcher(obj, r.Client)
+			_, err := r.reconcile(context.TODO(), sp, obj, time.Now())
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(tt.wantReason))
+			if tt.wantReason == imagev1.CredentialsExpiredReason {
+				g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(ContainSubstring("secretRef credentials expired at " + tt.expiresAt))
+			}
 		})
 	}
 }
 
+func TestImageRepositoryReconciler_githubProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var tokenRequests int
+	githubServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_token","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer githubServer.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	appSecret := &corev1.Secret{}
+	appSecret.Name = "github-app"
+	appSecret.Namespace = "default"
+	appSecret.Data = map[string][]byte{
+		github.AppIDKey:             []byte("123"),
+		github.AppInstallationIDKey: []byte("456"),
+		github.AppPrivateKeyKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}),
+		github.AppBaseURLKey: []byte(githubServer.URL),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(appSecret).Build()
+	r := &ImageRepositoryReconciler{
+		Client:    c,
+		authCache: newAuthCache(),
+	}
+
+	newRepo := func(image string, secretRef *meta.LocalObjectReference) (*imagev1.ImageRepository, name.Reference) {
+		obj := &imagev1.ImageRepository{}
+		obj.Name = "repo"
+		obj.Namespace = "default"
+		obj.Spec.Image = image
+		obj.Spec.Provider = "github"
+		obj.Spec.SecretRef = secretRef
+		ref, err := name.ParseReference(image)
+		g.Expect(err).ToNot(HaveOccurred())
+		return obj, ref
+	}
+	secretRef := &meta.LocalObjectReference{Name: appSecret.Name}
+
+	// The App credentials are required.
+	obj, ref := newRepo("ghcr.io/org/app", nil)
+	_, _, err = r.setAuthOptions(context.TODO(), obj, ref)
+	g.Expect(err).To(MatchError(ContainSubstring("requires a secretRef")))
+
+	// An installation token is minted for ghcr.io, and reused until shortly
+	// before it expires.
+	obj, ref = newRepo("ghcr.io/org/app", secretRef)
+	for i := 0; i < 2; i++ {
+		_, creds, err := r.setAuthOptions(context.TODO(), obj, ref)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(creds).To(HaveLen(1))
+		g.Expect(creds[0].name).To(Equal("provider"))
+	}
+	g.Expect(tokenRequests).To(Equal(1))
+	cached, ok := r.authCache.get("default/repo", obj.Spec.Image, oci.ProviderGeneric)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(cached).To(Equal(&authn.Basic{Username: "x-access-token", Password: "ghs_token"}))
+
+	// Other registries are accessed without credentials.
+	obj, ref = newRepo("docker.io/org/app", secretRef)
+	_, creds, err := r.setAuthOptions(context.TODO(), obj, ref)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds).To(BeEmpty())
+	g.Expect(tokenRequests).To(Equal(1))
+}
+
 func TestImageRepositoryReconciler_shouldScan(t *testing.T) {
 	testImage := "example.com/foo/bar"
 	tests := []struct {
@@ -487,6 +988,33 @@ func TestImageRepositoryReconciler_shouldScan(t *testing.T) {
 	}
 }
 
+func TestImageRepositoryReconciler_scanInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	newRepo := func(name string) imagev1.ImageRepository {
+		obj := imagev1.ImageRepository{}
+		obj.Name = name
+		obj.Namespace = "default"
+		obj.Spec.Interval = metav1.Duration{Duration: time.Hour}
+		return obj
+	}
+
+	r := &ImageRepositoryReconciler{}
+	g.Expect(r.scanInterval(newRepo("foo"))).To(Equal(time.Hour))
+
+	r.ScanJitter = 0.1
+	intervals := map[time.Duration]struct{}{}
+	for _, name := range []string{"foo", "bar", "baz", "qux"} {
+		interval := r.scanInterval(newRepo(name))
+		g.Expect(interval).To(BeNumerically(">=", time.Hour))
+		g.Expect(interval).To(BeNumerically("<=", time.Hour+6*time.Minute))
+		// The jitter is the same across reconciliations.
+		g.Expect(r.scanInterval(newRepo(name))).To(Equal(interval))
+		intervals[interval] = struct{}{}
+	}
+	g.Expect(intervals).To(HaveLen(4))
+}
+
 func TestImageRepositoryReconciler_scan(t *testing.T) {
 	registryServer := test.NewRegistryServer()
 	defer registryServer.Close()
@@ -495,11 +1023,13 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 		name           string
 		tags           []string
 		exclusionList  []string
+		scanLimit      int
 		annotation     string
 		db             *mockDatabase
 		wantErr        bool
 		wantTags       []string
 		wantLatestTags []string
+		wantTruncated  bool
 	}{
 		{
 			name:    "no tags",
@@ -535,6 +1065,24 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 			wantTags:       []string{"b", "d"},
 			wantLatestTags: []string{"d", "b"},
 		},
+		{
+			name:           "with scan limit",
+			tags:           []string{"a", "b", "c", "d"},
+			exclusionList:  []string{"a"},
+			scanLimit:      2,
+			db:             &mockDatabase{},
+			wantTags:       []string{"b", "c"},
+			wantLatestTags: []string{"c", "b"},
+			wantTruncated:  true,
+		},
+		{
+			name:           "with scan limit above tag count",
+			tags:           []string{"a", "b"},
+			scanLimit:      5,
+			db:             &mockDatabase{},
+			wantTags:       []string{"a", "b"},
+			wantLatestTags: []string{"b", "a"},
+		},
 		{
 			name:          "bad exclusion pattern",
 			tags:          []string{"a"}, // Ensure repo isn't empty to prevent 404.
@@ -574,24 +1122,28 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 			repo.Spec = imagev1.ImageRepositorySpec{
 				Image:         imgRepo,
 				ExclusionList: tt.exclusionList,
+				ScanLimit:     tt.scanLimit,
 			}
 
 			if tt.annotation != "" {
 				repo.SetAnnotations(map[string]string{meta.ReconcileRequestAnnotation: tt.annotation})
 			}
 
-			ref, err := parseImageReference(imgRepo, false)
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
 			g.Expect(err).ToNot(HaveOccurred())
 
 			opts := []remote.Option{}
 
-			tagCount, err := r.scan(context.TODO(), repo, ref, opts)
+			tagCount, err := r.scan(context.TODO(), repo, ref, opts, nil)
 			g.Expect(err != nil).To(Equal(tt.wantErr))
 			if err == nil {
 				g.Expect(tagCount).To(Equal(len(tt.wantTags)))
 				g.Expect(r.Database.Tags(imgRepo)).To(Equal(tt.wantTags))
 				g.Expect(repo.Status.LastScanResult.TagCount).To(Equal(len(tt.wantTags)))
 				g.Expect(repo.Status.LastScanResult.ScanTime).ToNot(BeZero())
+				g.Expect(repo.Status.LastScanResult.Truncated).To(Equal(tt.wantTruncated))
+				g.Expect(testutil.ToFloat64(tagChurnGauge.WithLabelValues(repo.Name, repo.Namespace))).To(Equal(float64(len(tt.wantTags))))
+				g.Expect(testutil.ToFloat64(tagCountGauge.WithLabelValues(repo.Name, repo.Namespace))).To(Equal(float64(len(tt.wantTags))))
 				if tt.annotation != "" {
 					g.Expect(repo.Status.LastHandledReconcileAt).To(Equal(tt.annotation))
 				}
@@ -600,96 +1152,984 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 	}
 }
 
-func TestGetLatestTags(t *testing.T) {
+func TestImageRepositoryReconciler_scanWithCredentials(t *testing.T) {
+	username, password := "authuser", "authpass"
+	registryServer := test.NewAuthenticatedRegistryServer(username, password)
+	defer registryServer.Close()
+
+	validAuth := remote.WithAuth(&authn.Basic{Username: username, Password: password})
+	invalidAuth := remote.WithAuth(&authn.Basic{Username: username, Password: "wrong"})
+	anonymous := credentialSource{name: "anonymous", option: remote.WithAuth(authn.Anonymous)}
+
 	tests := []struct {
-		name           string
-		tags           []string
-		wantLatestTags []string
+		name        string
+		credentials []credentialSource
+		wantErr     bool
 	}{
 		{
-			name:           "no tags",
-			wantLatestTags: nil,
-		},
-		{
-			name:           "few semver tags",
-			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1"},
-			wantLatestTags: []string{"3.0.1", "1.2.5", "1.0.1", "1.0.0", "0.0.8"},
+			name:    "no credentials",
+			wantErr: true,
 		},
 		{
-			name:           "10 semver tags",
-			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1", "5.1.1", "4.1.0", "4.5.0", "4.0.3", "2.2.2"},
-			wantLatestTags: []string{"5.1.1", "4.5.0", "4.1.0", "4.0.3", "3.0.1", "2.2.2", "1.2.5", "1.0.1", "1.0.0", "0.0.8"},
+			name: "valid credentials",
+			credentials: []credentialSource{
+				{name: "first", option: validAuth},
+			},
 		},
 		{
-			name:           "10+ semver tags",
-			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1", "5.1.1", "4.1.0", "4.5.0", "4.0.3", "2.2.2", "0.5.1", "0.1.0"},
-			wantLatestTags: []string{"5.1.1", "4.5.0", "4.1.0", "4.0.3", "3.0.1", "2.2.2", "1.2.5", "1.0.1", "1.0.0", "0.5.1"},
+			name: "first credentials fail, second succeed",
+			credentials: []credentialSource{
+				{name: "first", option: invalidAuth},
+				{name: "second", option: validAuth},
+			},
 		},
 		{
-			name:           "few numerical tags",
-			tags:           []string{"-62", "-88", "73", "72", "15"},
-			wantLatestTags: []string{"73", "72", "15", "-88", "-62"},
+			name: "all credentials fail",
+			credentials: []credentialSource{
+				{name: "first", option: invalidAuth},
+				{name: "second", option: invalidAuth},
+			},
+			wantErr: true,
 		},
 		{
-			name:           "few numerical tags",
-			tags:           []string{"-62", "-88", "73", "72", "15", "16", "15", "29", "-33", "-91", "100", "101"},
-			wantLatestTags: []string{"73", "72", "29", "16", "15", "15", "101", "100", "-91", "-88"},
+			name: "anonymous rejected, resolved credentials succeed",
+			credentials: []credentialSource{
+				anonymous,
+				{name: "resolved", resolve: func(context.Context) (remote.Option, error) {
+					return validAuth, nil
+				}},
+			},
 		},
 		{
-			name:           "few word tags",
-			tags:           []string{"aaa", "bbb", "ccc"},
-			wantLatestTags: []string{"ccc", "bbb", "aaa"},
+			name: "anonymous rejected, no resolved credentials",
+			credentials: []credentialSource{
+				anonymous,
+				{name: "resolved", resolve: func(context.Context) (remote.Option, error) {
+					return nil, nil
+				}},
+			},
+			wantErr: true,
 		},
 		{
-			name:           "few word tags",
-			tags:           []string{"aaa", "bbb", "ccc", "ddd", "eee", "fff", "ggg", "hhh", "iii", "jjj", "kkk", "lll"},
-			wantLatestTags: []string{"lll", "kkk", "jjj", "iii", "hhh", "ggg", "fff", "eee", "ddd", "ccc"},
+			name: "anonymous rejected, resolving credentials fails",
+			credentials: []credentialSource{
+				anonymous,
+				{name: "resolved", resolve: func(context.Context) (remote.Option, error) {
+					return nil, errors.New("fail")
+				}},
+			},
+			wantErr: true,
 		},
 	}
 
+	tags := []string{"a", "b"}
+	imgRepo, err := test.LoadImages(registryServer, "test-auth-fallback-"+randStringRunes(5), tags, validAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			g.Expect(getLatestTags(tt.tags)).To(Equal(tt.wantLatestTags))
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image: imgRepo,
+			}
+
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			tagCount, err := r.scan(context.TODO(), repo, ref, nil, tt.credentials)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if err == nil {
+				g.Expect(tagCount).To(Equal(len(tags)))
+				g.Expect(r.Database.Tags(imgRepo)).To(Equal(tags))
+			}
 		})
 	}
 }
 
-func Test_parseImageReference(t *testing.T) {
+func TestImageRepositoryReconciler_scanHTTPStatusCode(t *testing.T) {
 	tests := []struct {
-		name     string
-		url      string
-		insecure bool
-		wantErr  bool
-		wantRef  string
+		name           string
+		statusCode     int
+		wantStatusCode int
+	}{
+		{
+			name:           "unauthorized",
+			statusCode:     http.StatusUnauthorized,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "forbidden",
+			statusCode:     http.StatusForbidden,
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "too many requests",
+			statusCode:     http.StatusTooManyRequests,
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "internal server error",
+			statusCode:     http.StatusInternalServerError,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer srv.Close()
+
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			imgRepo := test.RegistryName(srv) + "/foo"
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image: imgRepo,
+			}
+			// Set a stale status code to ensure it's overwritten.
+			repo.Status.LastScanHTTPStatusCode = http.StatusBadGateway
+
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// Disable the retries of the registry client.
+			opts := []remote.Option{remote.WithRetryBackoff(remote.Backoff{Steps: 1})}
+
+			_, err = r.scan(context.TODO(), repo, ref, opts, nil)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(repo.Status.LastScanHTTPStatusCode).To(Equal(tt.wantStatusCode))
+		})
+	}
+
+	t.Run("successful scan", func(t *testing.T) {
+		g := NewWithT(t)
+
+		registryServer := test.NewRegistryServer()
+		defer registryServer.Close()
+
+		imgRepo, err := test.LoadImages(registryServer, "test-status-code-"+randStringRunes(5), []string{"a"})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		r := ImageRepositoryReconciler{
+			EventRecorder: record.NewFakeRecorder(32),
+			Database:      &mockDatabase{},
+			patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+		}
+
+		repo := &imagev1.ImageRepository{}
+		repo.Spec = imagev1.ImageRepositorySpec{
+			Image: imgRepo,
+		}
+		repo.Status.LastScanHTTPStatusCode = http.StatusUnauthorized
+
+		ref, _, err := imageref.Canonicalize(imgRepo, false)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(repo.Status.LastScanHTTPStatusCode).To(BeZero())
+	})
+}
+
+func TestImageRepositoryReconciler_scanWithMirrors(t *testing.T) {
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not a registry"))
+	}))
+	defer invalid.Close()
+
+	tags := []string{"a", "b"}
+	imgRepo, err := test.LoadImages(registryServer, "test-mirror-"+randStringRunes(5), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoPath := strings.TrimPrefix(imgRepo, test.RegistryName(registryServer))
+
+	tests := []struct {
+		name         string
+		primary      *httptest.Server
+		mirrors      []string
+		cancelled    bool
+		wantErr      bool
+		wantRegistry string
 	}{
 		{
-			name:    "simple valid url",
-			url:     "example.com/foo/bar",
-			wantRef: "example.com/foo/bar",
+			name:         "primary available",
+			primary:      registryServer,
+			mirrors:      []string{test.RegistryName(unavailable)},
+			wantRegistry: test.RegistryName(registryServer),
+		},
+		{
+			name:         "primary unavailable, mirror serves",
+			primary:      unavailable,
+			mirrors:      []string{test.RegistryName(unavailable), test.RegistryName(registryServer)},
+			wantRegistry: test.RegistryName(registryServer),
 		},
 		{
-			name:    "with scheme prefix",
-			url:     "https://example.com/foo/bar",
+			name:    "primary unavailable, no mirrors",
+			primary: unavailable,
 			wantErr: true,
 		},
 		{
-			name:    "with tag",
-			url:     "example.com/foo/bar:baz",
+			name:    "primary rejects credentials",
+			primary: unauthorized,
+			mirrors: []string{test.RegistryName(registryServer)},
 			wantErr: true,
 		},
 		{
-			name:    "with host port",
-			url:     "example.com:9999/foo/bar",
-			wantErr: false,
-			wantRef: "example.com:9999/foo/bar",
+			name:    "primary serves an invalid response",
+			primary: invalid,
+			mirrors: []string{test.RegistryName(registryServer)},
+			wantErr: true,
+		},
+		{
+			name:      "scan cancelled",
+			primary:   unavailable,
+			mirrors:   []string{test.RegistryName(registryServer)},
+			cancelled: true,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			image := test.RegistryName(tt.primary) + repoPath
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image:   image,
+				Mirrors: tt.mirrors,
+			}
+
+			ref, _, err := imageref.Canonicalize(image, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// Disable the retries of the registry client.
+			opts := []remote.Option{remote.WithRetryBackoff(remote.Backoff{Steps: 1})}
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			tagCount, err := r.scan(ctx, repo, ref, opts, nil)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if err == nil {
+				g.Expect(tagCount).To(Equal(len(tags)))
+				// The tags are stored under the name of the image, not the
+				// mirror.
+				g.Expect(r.Database.Tags(image)).To(Equal(tags))
+				g.Expect(repo.Status.LastScanResult.Registry).To(Equal(tt.wantRegistry))
+			}
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanReferrers(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-referrers-"+randStringRunes(5), []string{"a", "b"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Attach a signature to the image of tag b.
+	subject, err := remote.Head(mustParseTag(t, imgRepo+":b"))
+	g.Expect(err).ToNot(HaveOccurred())
+	other, err := remote.Head(mustParseTag(t, imgRepo+":a"))
+	g.Expect(err).ToNot(HaveOccurred())
+	sigType := types.MediaType("application/vnd.example.sig.v1+json")
+	sig := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), sigType)
+	sig = mutate.Subject(sig, *subject).(v1.Image)
+	sigDigest, err := sig.Digest()
+	g.Expect(err).ToNot(HaveOccurred())
+	sigRef, err := name.NewDigest(imgRepo + "@" + sigDigest.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(sigRef, sig)).To(Succeed())
+
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:         imgRepo,
+		ScanReferrers: true,
+		// The test registry lists the manifests pushed by digest as tags.
+		ExclusionList: []string{"^sha256:"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.Referrers).To(Equal([]imagev1.TagReferrers{
+		{Tag: "b", Digest: subject.Digest.String(), Count: 1, ArtifactTypes: []string{string(sigType)}},
+		{Tag: "a", Digest: other.Digest.String(), Count: 0},
+	}))
+
+	// Without the option, no referrers are reported.
+	repo.Spec.ScanReferrers = false
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.Referrers).To(BeNil())
+}
+
+func TestImageRepositoryReconciler_scanSizes(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-sizes-"+randStringRunes(5), []string{"a", "b"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Push an image index, whose size is the size of its largest image.
+	idx, err := random.Index(512, 2, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.WriteIndex(mustParseTag(t, imgRepo+":multi"), idx)).To(Succeed())
+
+	imageSize := func(img v1.Image) int64 {
+		manifest, err := img.Manifest()
+		g.Expect(err).ToNot(HaveOccurred())
+		var size int64
+		for _, layer := range manifest.Layers {
+			size += layer.Size
+		}
+		return size
+	}
+	wantSizes := map[string]int64{}
+	for _, tag := range []string{"a", "b"} {
+		img, err := remote.Image(mustParseTag(t, imgRepo+":"+tag))
+		g.Expect(err).ToNot(HaveOccurred())
+		wantSizes[tag] = imageSize(img)
+	}
+	indexManifest, err := idx.IndexManifest()
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, m := range indexManifest.Manifests {
+		img, err := idx.Image(m.Digest)
+		g.Expect(err).ToNot(HaveOccurred())
+		wantSizes["multi"] = max(wantSizes["multi"], imageSize(img))
+	}
+
+	db := &mockDatabase{}
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:     imgRepo,
+		ScanSizes: true,
+		// The test registry lists the manifests pushed by digest as tags.
+		ExclusionList: []string{"^sha256:"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.SizeData).To(Equal(wantSizes))
+	g.Expect(repo.Status.LastScanResult.FetchedTagCount).To(Equal(3))
+
+	// The recorded sizes aren't looked up again.
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.SizeData).To(Equal(wantSizes))
+	g.Expect(repo.Status.LastScanResult.FetchedTagCount).To(Equal(0))
+
+	// Without the option, no size is looked up.
+	db.SizeData = nil
+	repo.Spec.ScanSizes = false
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.SizeData).To(BeNil())
+}
+
+func TestImageRepositoryReconciler_repoSizeThreshold(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-size-threshold-"+randStringRunes(5), []string{"a", "b"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := &mockDatabase{StoredSize: 2048}
+	recorder := record.NewFakeRecorder(32)
+	r := ImageRepositoryReconciler{
+		EventRecorder:     recorder,
+		Database:          db,
+		RepoSizeThreshold: 1024,
+		patchOptions:      getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "test-repo"
+	repo.Namespace = "default"
+	repo.Spec = imagev1.ImageRepositorySpec{Image: imgRepo}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(testutil.ToFloat64(storedSizeGauge.WithLabelValues(repo.Name, repo.Namespace))).To(Equal(float64(2048)))
+	g.Expect(conditions.IsTrue(repo, imagev1.StorageThresholdExceededCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(repo, imagev1.StorageThresholdExceededCondition)).To(Equal(imagev1.RepositoryTooLargeReason))
+	g.Expect(conditions.GetMessage(repo, imagev1.StorageThresholdExceededCondition)).To(
+		Equal("the 2 tags of the repository take 2048 bytes in the database, over the threshold of 1024 bytes"))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("Warning RepositoryTooLarge")))
+
+	// The event is only emitted when the threshold is first exceeded.
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(recorder.Events).ToNot(Receive())
+
+	// The condition is removed once the records are below the threshold.
+	db.StoredSize = 512
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.Has(repo, imagev1.StorageThresholdExceededCondition)).To(BeFalse())
+
+	// Without a threshold, the condition is never set.
+	db.StoredSize = 2048
+	r.RepoSizeThreshold = 0
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.Has(repo, imagev1.StorageThresholdExceededCondition)).To(BeFalse())
+}
+
+func TestImageRepositoryReconciler_defaultScanInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-default-interval-"+randStringRunes(5), []string{"1.0.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	withInterval := &imagev1.ImageRepository{}
+	withInterval.Name = "with-interval"
+	withInterval.Namespace = "default"
+	withInterval.Spec = imagev1.ImageRepositorySpec{
+		Image:    imgRepo,
+		Interval: metav1.Duration{Duration: 10 * time.Minute},
+	}
+	withoutInterval := &imagev1.ImageRepository{}
+	withoutInterval.Name = "without-interval"
+	withoutInterval.Namespace = "default"
+	withoutInterval.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+	}
+	for _, obj := range []*imagev1.ImageRepository{withInterval, withoutInterval} {
+		controllerutil.AddFinalizer(obj, imagev1.ImageFinalizer)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(withInterval, withoutInterval).
+		WithStatusSubresource(withInterval, withoutInterval).Build()
+	r := &ImageRepositoryReconciler{
+		Client:              c,
+		EventRecorder:       record.NewFakeRecorder(32),
+		Database:            &mockDatabase{},
+		DefaultScanInterval: time.Hour,
+		patchOptions:        getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+		authCache:           newAuthCache(),
+		scans:               newInFlightScans(),
+	}
+
+	// The interval of the object takes precedence over the default.
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(withInterval)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(10 * time.Minute))
+
+	result, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(withoutInterval)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+	// The default isn't written to the object.
+	obj := &imagev1.ImageRepository{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(withoutInterval), obj)).To(Succeed())
+	g.Expect(obj.Spec.Interval.Duration).To(BeZero())
+	g.Expect(conditions.IsReady(obj)).To(BeTrue())
+}
+
+func TestImageRepositoryReconciler_imageVariables(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imageName := "test-variables-" + randStringRunes(5)
+	_, err := test.LoadImages(registryServer, imageName, []string{"1.0.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	defined := &imagev1.ImageRepository{}
+	defined.Name = "defined"
+	defined.Namespace = "default"
+	defined.Spec = imagev1.ImageRepositorySpec{
+		Image:    "${REGISTRY_HOST}/" + imageName,
+		Interval: metav1.Duration{Duration: time.Hour},
+	}
+	undefined := &imagev1.ImageRepository{}
+	undefined.Name = "undefined"
+	undefined.Namespace = "default"
+	undefined.Spec = imagev1.ImageRepositorySpec{
+		Image:    "${REGISTRY_HOTS}/" + imageName,
+		Interval: metav1.Duration{Duration: time.Hour},
+	}
+	for _, obj := range []*imagev1.ImageRepository{defined, undefined} {
+		controllerutil.AddFinalizer(obj, imagev1.ImageFinalizer)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(defined, undefined).
+		WithStatusSubresource(defined, undefined).Build()
+	r := &ImageRepositoryReconciler{
+		Client:         c,
+		EventRecorder:  record.NewFakeRecorder(32),
+		Database:       &mockDatabase{},
+		ImageVariables: map[string]string{"REGISTRY_HOST": test.RegistryName(registryServer)},
+		patchOptions:   getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+		authCache:      newAuthCache(),
+		scans:          newInFlightScans(),
+	}
+
+	_, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(defined)})
+	g.Expect(err).ToNot(HaveOccurred())
+	obj := &imagev1.ImageRepository{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(defined), obj)).To(Succeed())
+	g.Expect(conditions.IsReady(obj)).To(BeTrue())
+	g.Expect(obj.Status.CanonicalImageName).To(Equal(test.RegistryName(registryServer) + "/" + imageName))
+	// The expanded image isn't written to the object.
+	g.Expect(obj.Spec.Image).To(Equal(defined.Spec.Image))
+
+	// An undefined variable stalls the object rather than scanning another
+	// registry.
+	_, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(undefined)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(undefined), obj)).To(Succeed())
+	g.Expect(conditions.IsStalled(obj)).To(BeTrue())
+	g.Expect(conditions.GetReason(obj, meta.StalledCondition)).To(Equal(imagev1.ImageURLInvalidReason))
+	g.Expect(conditions.GetMessage(obj, meta.StalledCondition)).To(ContainSubstring("undefined variables: REGISTRY_HOTS"))
+}
+
+func TestImageRepositoryReconciler_scanArtifactTypes(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	// Push images along with a Helm chart, an artifact and an image index
+	// under tags of the same repository.
+	imgRepo, err := test.LoadImages(registryServer, "test-types-"+randStringRunes(5), []string{"1.0.0", "1.1.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+	chartType := types.MediaType("application/vnd.cncf.helm.config.v1+json")
+	chart := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), chartType)
+	g.Expect(remote.Write(mustParseTag(t, imgRepo+":2.0.0"), chart)).To(Succeed())
+	sbomType := "application/vnd.example.sbom.v1"
+	sbom := rawManifest(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"artifactType":"` + sbomType + `","config":{"mediaType":"application/vnd.oci.empty.v1+json",` +
+		`"digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`)
+	g.Expect(remote.Put(mustParseTag(t, imgRepo+":1.2.0-sbom"), sbom)).To(Succeed())
+	index, err := random.Index(64, 1, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.WriteIndex(mustParseTag(t, imgRepo+":1.3.0"), mutate.IndexMediaType(index, types.OCIImageIndex))).To(Succeed())
+
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+		// The test registry lists the manifests pushed by digest as tags.
+		ExclusionList: []string{"^sha256:"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Without artifact types, all the tags are stored.
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.LatestTags).To(ConsistOf("1.0.0", "1.1.0", "1.2.0-sbom", "1.3.0", "2.0.0"))
+
+	// The random images have a Docker config.
+	repo.Spec.ArtifactTypes = []string{string(types.DockerConfigJSON), string(types.OCIImageIndex)}
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.LatestTags).To(ConsistOf("1.0.0", "1.1.0", "1.3.0"))
+
+	repo.Spec.ArtifactTypes = []string{string(chartType), sbomType}
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.LatestTags).To(ConsistOf("1.2.0-sbom", "2.0.0"))
+}
+
+// rawManifest is a manifest pushed as is to a registry.
+type rawManifest string
+
+// RawManifest implements remote.Taggable.
+func (m rawManifest) RawManifest() ([]byte, error) {
+	return []byte(m), nil
+}
+
+func TestImageRepositoryReconciler_scanFailedTags(t *testing.T) {
+	g := NewWithT(t)
+
+	// The registry fails the requests for the manifest of tag a.
+	regHandler := &test.TagListHandler{
+		RegistryHandler: registry.New(registry.Logger(log.New(io.Discard, "", 0))),
+		Imagetags:       map[string][]string{},
+	}
+	var failing atomic.Bool
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() && strings.HasSuffix(r.URL.Path, "/manifests/a") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-failed-"+randStringRunes(5), []string{"a", "b", "c"})
+	g.Expect(err).ToNot(HaveOccurred())
+	failing.Store(true)
+
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:         imgRepo,
+		ScanReferrers: true,
+		ExclusionList: []string{"^c$"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	result := repo.Status.LastScanResult
+	g.Expect(result.ListedTagCount).To(Equal(3))
+	g.Expect(result.TagCount).To(Equal(2))
+	g.Expect(result.FetchedTagCount).To(Equal(1))
+	g.Expect(result.FailedTagCount).To(Equal(1))
+	g.Expect(result.LastTagError).To(HavePrefix(`failed to get the digest of tag "a"`))
+	g.Expect(len(result.LastTagError)).To(BeNumerically("<=", maxTagErrorLength))
+}
+
+func TestTruncateMessage(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(truncateMessage("short", 10)).To(Equal("short"))
+	g.Expect(truncateMessage("a longer message", 10)).To(Equal("a longe..."))
+	// A multi-byte character isn't cut.
+	g.Expect(truncateMessage("aaaaaaé message", 10)).To(Equal("aaaaaa..."))
+}
+
+func TestImageRepositoryReconciler_scanUnchanged(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgName := "test-unchanged-" + randStringRunes(5)
+	imgRepo, err := test.LoadImages(registryServer, imgName, []string{"a", "b"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := &mockDatabase{}
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:         imgRepo,
+		ScanReferrers: true,
+		// The test registry lists the manifests pushed by digest as tags.
+		ExclusionList: []string{"^sha256:"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeFalse())
+	digest := repo.Status.LastScanResult.TagsDigest
+	g.Expect(digest).To(HavePrefix("sha256:"))
+	referrers := repo.Status.LastScanResult.Referrers
+	g.Expect(referrers).To(HaveLen(2))
+	g.Expect(repo.Status.LastScanResult.FetchedTagCount).To(Equal(2))
+
+	// With the same tags, neither the database nor the referrers are
+	// updated, and the referrers kept from the last scan aren't counted as
+	// fetched.
+	db.WriteError = errors.New("unexpected write")
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeTrue())
+	g.Expect(repo.Status.LastScanResult.TagsDigest).To(Equal(digest))
+	g.Expect(repo.Status.LastScanResult.Referrers).To(Equal(referrers))
+	g.Expect(repo.Status.LastScanResult.FetchedTagCount).To(Equal(0))
+
+	// With a new tag, the scan is complete again.
+	db.WriteError = nil
+	_, err = test.LoadImages(registryServer, imgName, []string{"c"})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeFalse())
+	g.Expect(repo.Status.LastScanResult.TagsDigest).ToNot(Equal(digest))
+	g.Expect(repo.Status.LastScanResult.Referrers).To(HaveLen(3))
+}
+
+func TestImageRepositoryReconciler_scanDeletedTags(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgName := "test-deleted-" + randStringRunes(5)
+	imgRepo, err := test.LoadImages(registryServer, imgName, []string{"1.0.0", "1.1.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := database.NewBadgerDatabase(testBadgerDB)
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+		// The test registry lists the manifests pushed by digest as tags.
+		ExclusionList: []string{"^sha256:"},
+	}
+
+	ref, canonicalName, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.Tags(canonicalName)).To(ConsistOf("1.0.0", "1.1.0"))
+
+	// The tags deleted from the registry are deleted from the database, so
+	// that the policies don't select them.
+	g.Expect(remote.Delete(ref.Context().Tag("1.1.0"))).To(Succeed())
+	_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.Tags(canonicalName)).To(ConsistOf("1.0.0"))
+	firstSeen, err := db.TagsFirstSeen(canonicalName)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(firstSeen).ToNot(HaveKey("1.1.0"))
+}
+
+func TestListReferrers_concurrency(t *testing.T) {
+	g := NewWithT(t)
+
+	// The registry keeps the manifest requests in flight for a while, to
+	// record how many are served at the same time.
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/") {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	tags := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	imgRepo, err := test.LoadImages(registryServer, "test-concurrency-"+randStringRunes(5), tags)
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	mu.Lock()
+	maxInFlight = 0
+	mu.Unlock()
+
+	// A tag that can't be looked up is left out without failing the others.
+	referrers, err := listReferrers(context.TODO(), ref, append(tags, "missing"), nil, 3)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(`failed to get the digest of tag "missing"`))
+	g.Expect(referrers).To(HaveLen(len(tags)))
+	for i, tag := range tags {
+		g.Expect(referrers[i].Tag).To(Equal(tag))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(maxInFlight).To(BeNumerically("<=", 3))
+	g.Expect(maxInFlight).To(BeNumerically(">", 1))
+}
+
+func TestListReferrers_rateLimited(t *testing.T) {
+	g := NewWithT(t)
+
+	// The registry rate limits the requests for one of the tags once the
+	// images are loaded.
+	var limited atomic.Bool
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited.Load() && strings.HasSuffix(r.URL.Path, "/manifests/limited") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-rate-limited-"+randStringRunes(5), []string{"a", "limited"})
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	limited.Store(true)
+
+	// A rate limited request fails the whole lookup.
+	referrers, err := listReferrers(context.TODO(), ref, []string{"a", "limited"}, nil, 2)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(registryStatusCode(err)).To(Equal(http.StatusTooManyRequests))
+	g.Expect(referrers).To(BeNil())
+}
+
+func TestImageRepositoryReconciler_resolveDigestRetries(t *testing.T) {
+	// The registry fails the first manifest requests with the given status
+	// code once the images are loaded.
+	var failures, requests atomic.Int32
+	var statusCode atomic.Int32
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/") {
+			if requests.Add(1) <= failures.Load() {
+				w.WriteHeader(int(statusCode.Load()))
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-retries-"+randStringRunes(5), []string{"1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delay := digestRetryDelay
+	digestRetryDelay = time.Millisecond
+	defer func() { digestRetryDelay = delay }()
+
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int32
+		statusCode   int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{
+			name:         "succeeds after transient failures",
+			retries:      2,
+			failures:     2,
+			statusCode:   http.StatusTooManyRequests,
+			wantRequests: 3,
+		},
+		{
+			name:         "fails once the retries are exhausted",
+			retries:      1,
+			failures:     2,
+			statusCode:   http.StatusTooManyRequests,
+			wantErr:      true,
+			wantRequests: 2,
 		},
 		{
-			name:     "with insecure registry",
-			url:      "example.com/foo/bar",
-			insecure: true,
-			wantRef:  "example.com/foo/bar",
+			name:         "without retries",
+			failures:     1,
+			statusCode:   http.StatusTooManyRequests,
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name:         "doesn't retry permanent failures",
+			retries:      2,
+			failures:     1,
+			statusCode:   http.StatusForbidden,
+			wantErr:      true,
+			wantRequests: 1,
 		},
 	}
 
@@ -697,16 +2137,343 @@ func Test_parseImageReference(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			ref, err := parseImageReference(tt.url, tt.insecure)
+			requests.Store(0)
+			failures.Store(tt.failures)
+			statusCode.Store(int32(tt.statusCode))
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec.Image = imgRepo
+
+			r := &ImageRepositoryReconciler{
+				Client:        fake.NewClientBuilder().Build(),
+				DigestRetries: tt.retries,
+			}
+			digest, err := r.ResolveDigest(context.TODO(), repo, "1.0.0", "")
+			g.Expect(requests.Load()).To(Equal(tt.wantRequests))
 			if tt.wantErr {
 				g.Expect(err).To(HaveOccurred())
-			} else {
-				g.Expect(err).ToNot(HaveOccurred())
-				g.Expect(ref.String()).To(Equal(tt.wantRef))
-				if tt.insecure {
-					g.Expect(ref.Context().Registry.Scheme()).To(Equal("http"))
+				g.Expect(registryStatusCode(err)).To(Equal(tt.statusCode))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(digest).To(HavePrefix("sha256:"))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanPaginated(t *testing.T) {
+	var allTags []string
+	for i := 0; i < 25; i++ {
+		allTags = append(allTags, fmt.Sprintf("v%02d", i))
+	}
+
+	tests := []struct {
+		name          string
+		scanLimit     int
+		exclusionList []string
+		wantTags      int
+		wantTruncated bool
+		wantPages     int
+	}{
+		{
+			name:      "all pages",
+			wantTags:  25,
+			wantPages: 3,
+		},
+		{
+			name:          "stop at the scan limit",
+			scanLimit:     5,
+			wantTags:      5,
+			wantTruncated: true,
+			wantPages:     1,
+		},
+		{
+			name:          "stop at the scan limit with excluded tags",
+			scanLimit:     5,
+			exclusionList: []string{"^v0"},
+			wantTags:      5,
+			wantTruncated: true,
+			wantPages:     2,
+		},
+		{
+			name:      "scan limit above the number of tags",
+			scanLimit: 30,
+			wantTags:  25,
+			wantPages: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			handler := &test.TagListHandler{
+				RegistryHandler: registry.New(registry.Logger(log.New(io.Discard, "", 0))),
+				Imagetags:       map[string][]string{"paginated": allTags},
+				PageSize:        10,
+			}
+			var pages int
+			registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/tags/list") {
+					pages++
 				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer registryServer.Close()
+
+			imgRepo := test.RegistryName(registryServer) + "/paginated"
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image:         imgRepo,
+				ScanLimit:     tt.scanLimit,
+				ExclusionList: tt.exclusionList,
 			}
+
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			tagCount, err := r.scan(context.TODO(), repo, ref, nil, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tagCount).To(Equal(tt.wantTags))
+			g.Expect(repo.Status.LastScanResult.Truncated).To(Equal(tt.wantTruncated))
+			g.Expect(pages).To(Equal(tt.wantPages))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanInlineTags(t *testing.T) {
+	g := NewWithT(t)
+
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var listed bool
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			listed = true
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-inline-tags-"+randStringRunes(5), []string{"a", "b", "c"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	recorder := record.NewFakeRecorder(32)
+	db := &mockDatabase{}
+	r := ImageRepositoryReconciler{
+		EventRecorder: recorder,
+		Database:      db,
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+		Tags:  []string{"c", "missing", "a"},
+	}
+
+	ref, _, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The unknown tag is reported without failing the scan, and the tags
+	// aren't listed.
+	tagCount, err := r.scan(context.TODO(), repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tagCount).To(Equal(2))
+	g.Expect(db.TagData).To(Equal([]string{"c", "a"}))
+	g.Expect(listed).To(BeFalse())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("tags not found in the repository: missing")))
+}
+
+func TestImageRepositoryReconciler_scanTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		scanTimeout *metav1.Duration
+		wantErr     bool
+	}{
+		{
+			name:    "defaults to the timeout",
+			wantErr: true,
+		},
+		{
+			name:        "longer than the timeout",
+			scanTimeout: &metav1.Duration{Duration: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// The registry takes longer than the timeout to list the tags.
+			handler := &test.TagListHandler{
+				RegistryHandler: registry.New(registry.Logger(log.New(io.Discard, "", 0))),
+				Imagetags:       map[string][]string{"slow": {"v1.0.0"}},
+			}
+			registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/tags/list") {
+					time.Sleep(1500 * time.Millisecond)
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer registryServer.Close()
+
+			imgRepo := test.RegistryName(registryServer) + "/slow"
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image:       imgRepo,
+				Timeout:     &metav1.Duration{Duration: time.Second},
+				ScanTimeout: tt.scanTimeout,
+			}
+
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = r.scan(context.TODO(), repo, ref, nil, nil)
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(context.DeadlineExceeded))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_cancelScanOnDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	// The registry doesn't answer the tag list requests until they're
+	// cancelled.
+	requested := make(chan struct{}, 1)
+	handler := &test.TagListHandler{
+		RegistryHandler: registry.New(registry.Logger(log.New(io.Discard, "", 0))),
+		Imagetags:       map[string][]string{"stuck": {"v1.0.0"}},
+	}
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			requested <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer registryServer.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	obj := &imagev1.ImageRepository{}
+	obj.Name = "stuck-repo"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.Image = test.RegistryName(registryServer) + "/stuck"
+	obj.Spec.Interval = metav1.Duration{Duration: time.Hour}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj).WithStatusSubresource(obj).Build()
+	r := &ImageRepositoryReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+		scans:         newInFlightScans(),
+	}
+
+	type reconcileResult struct {
+		result ctrl.Result
+		err    error
+	}
+	done := make(chan reconcileResult, 1)
+	go func() {
+		sp := patch.NewSerialPatcher(obj, r.Client)
+		result, err := r.reconcile(context.TODO(), sp, obj, time.Now())
+		done <- reconcileResult{result, err}
+	}()
+
+	g.Eventually(requested, 5*time.Second).Should(Receive())
+	start := time.Now()
+	deleted := obj.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	g.Expect(r.cancelScanOnDelete().Update(event.UpdateEvent{ObjectOld: obj, ObjectNew: deleted})).To(BeTrue())
+
+	var res reconcileResult
+	g.Eventually(done, 5*time.Second).Should(Receive(&res))
+	g.Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	g.Expect(res.err).ToNot(HaveOccurred())
+	g.Expect(res.result).To(Equal(ctrl.Result{}))
+}
+
+func mustParseTag(t *testing.T, s string) name.Tag {
+	t.Helper()
+	tag, err := name.NewTag(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tag
+}
+
+func TestGetLatestTags(t *testing.T) {
+	tests := []struct {
+		name           string
+		tags           []string
+		wantLatestTags []string
+	}{
+		{
+			name:           "no tags",
+			wantLatestTags: nil,
+		},
+		{
+			name:           "few semver tags",
+			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1"},
+			wantLatestTags: []string{"3.0.1", "1.2.5", "1.0.1", "1.0.0", "0.0.8"},
+		},
+		{
+			name:           "10 semver tags",
+			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1", "5.1.1", "4.1.0", "4.5.0", "4.0.3", "2.2.2"},
+			wantLatestTags: []string{"5.1.1", "4.5.0", "4.1.0", "4.0.3", "3.0.1", "2.2.2", "1.2.5", "1.0.1", "1.0.0", "0.0.8"},
+		},
+		{
+			name:           "10+ semver tags",
+			tags:           []string{"1.0.0", "0.0.8", "1.2.5", "3.0.1", "1.0.1", "5.1.1", "4.1.0", "4.5.0", "4.0.3", "2.2.2", "0.5.1", "0.1.0"},
+			wantLatestTags: []string{"5.1.1", "4.5.0", "4.1.0", "4.0.3", "3.0.1", "2.2.2", "1.2.5", "1.0.1", "1.0.0", "0.5.1"},
+		},
+		{
+			name:           "few numerical tags",
+			tags:           []string{"-62", "-88", "73", "72", "15"},
+			wantLatestTags: []string{"73", "72", "15", "-88", "-62"},
+		},
+		{
+			name:           "few numerical tags",
+			tags:           []string{"-62", "-88", "73", "72", "15", "16", "15", "29", "-33", "-91", "100", "101"},
+			wantLatestTags: []string{"73", "72", "29", "16", "15", "15", "101", "100", "-91", "-88"},
+		},
+		{
+			name:           "few word tags",
+			tags:           []string{"aaa", "bbb", "ccc"},
+			wantLatestTags: []string{"ccc", "bbb", "aaa"},
+		},
+		{
+			name:           "few word tags",
+			tags:           []string{"aaa", "bbb", "ccc", "ddd", "eee", "fff", "ggg", "hhh", "iii", "jjj", "kkk", "lll"},
+			wantLatestTags: []string{"lll", "kkk", "jjj", "iii", "hhh", "ggg", "fff", "eee", "ddd", "ccc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(getLatestTags(tt.tags)).To(Equal(tt.wantLatestTags))
 		})
 	}
 }
@@ -766,6 +2533,57 @@ func TestFilterOutTags(t *testing.T) {
 	}
 }
 
+func TestTagChurn(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldTags []string
+		newTags []string
+		want    int
+	}{
+		{
+			name: "empty",
+			want: 0,
+		},
+		{
+			name:    "first scan",
+			newTags: []string{"a", "b", "c"},
+			want:    3,
+		},
+		{
+			name:    "no change",
+			oldTags: []string{"a", "b", "c"},
+			newTags: []string{"c", "b", "a"},
+			want:    0,
+		},
+		{
+			name:    "added tags",
+			oldTags: []string{"a", "b"},
+			newTags: []string{"a", "b", "c", "d"},
+			want:    2,
+		},
+		{
+			name:    "removed tags",
+			oldTags: []string{"a", "b", "c"},
+			newTags: []string{"a"},
+			want:    2,
+		},
+		{
+			name:    "added and removed tags",
+			oldTags: []string{"a", "b", "c"},
+			newTags: []string{"a", "d", "e"},
+			want:    4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			added, removed := database.DiffTags(tt.oldTags, tt.newTags)
+			g.Expect(len(added) + len(removed)).To(Equal(tt.want))
+		})
+	}
+}
+
 func TestIsEqualSliceContent(t *testing.T) {
 	tests := []struct {
 		name string
@@ -874,3 +2692,154 @@ func TestNotify(t *testing.T) {
 		})
 	}
 }
+
+func TestIsUnavailableError(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "https://example.com/v2/", Err: &net.OpError{
+		Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+	}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "server error",
+			err:  &transport.Error{StatusCode: http.StatusServiceUnavailable},
+			want: true,
+		},
+		{
+			name: "rejected credentials",
+			err:  &transport.Error{StatusCode: http.StatusUnauthorized},
+		},
+		{
+			name: "connection refused",
+			err:  fmt.Errorf("failed to list tags: %w", refused),
+			want: true,
+		},
+		{
+			name: "connection reset",
+			err:  fmt.Errorf("failed to list tags: %w", syscall.ECONNRESET),
+			want: true,
+		},
+		{
+			name: "cancelled context",
+			err:  &url.Error{Op: "Get", URL: "https://example.com/v2/", Err: context.Canceled},
+		},
+		{
+			name: "timed out context",
+			err:  fmt.Errorf("failed to list tags: %w", context.DeadlineExceeded),
+		},
+		{
+			name: "invalid response",
+			err:  errors.New("invalid character 'o' in literal null (expecting 'u')"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isUnavailableError(tt.err)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	delay := digestRetryDelay
+	digestRetryDelay = time.Millisecond
+	defer func() { digestRetryDelay = delay }()
+
+	refused := &url.Error{Op: "Head", URL: "https://example.com/v2/", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{
+			name:      "rate limited",
+			err:       &transport.Error{StatusCode: http.StatusTooManyRequests},
+			wantCalls: 3,
+		},
+		{
+			name:      "connection refused",
+			err:       refused,
+			wantCalls: 3,
+		},
+		{
+			name:      "rejected credentials",
+			err:       &transport.Error{StatusCode: http.StatusUnauthorized},
+			wantCalls: 1,
+		},
+		{
+			name:      "invalid manifest",
+			err:       errors.New("failed to decode the manifest: unexpected end of JSON input"),
+			wantCalls: 1,
+		},
+		{
+			name:      "platform not found",
+			err:       fmt.Errorf("%w: linux/arm64 is not in the image index", errPlatformNotFound),
+			wantCalls: 1,
+		},
+		{
+			name:      "cancelled context",
+			err:       &url.Error{Op: "Head", URL: "https://example.com/v2/", Err: context.Canceled},
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var calls int
+			err := retryTransient(context.TODO(), 2, func() error {
+				calls++
+				return tt.err
+			})
+			g.Expect(err).To(MatchError(tt.err))
+			g.Expect(calls).To(Equal(tt.wantCalls))
+		})
+	}
+}
+
+func TestForEachTag(t *testing.T) {
+	g := NewWithT(t)
+
+	tags := []string{"a", "b", "c", "d", "e"}
+
+	// Up to concurrency tags are looked up at the same time.
+	var inFlight, maxInFlight atomic.Int32
+	errs := forEachTag(context.TODO(), tags, 2, isRateLimitedError, func(ctx context.Context, i int, tag string) error {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if tag == "c" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	g.Expect(maxInFlight.Load()).To(BeNumerically("<=", 2))
+	g.Expect(errs).To(HaveLen(len(tags)))
+	g.Expect(errs[2]).To(MatchError("failed"))
+	g.Expect(kerrors.NewAggregate(errs).Errors()).To(HaveLen(1))
+
+	// An error for which stop returns true stops the lookup.
+	var called []string
+	rateLimited := &transport.Error{StatusCode: http.StatusTooManyRequests}
+	errs = forEachTag(context.TODO(), tags, 1, isRateLimitedError, func(ctx context.Context, i int, tag string) error {
+		called = append(called, tag)
+		if tag == "b" {
+			return rateLimited
+		}
+		return nil
+	})
+	g.Expect(called).To(Equal([]string{"a", "b"}))
+	g.Expect(stoppedLookupError(context.TODO(), errs)).To(Equal(rateLimited))
+}
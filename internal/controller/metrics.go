@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The results of a scan recorded by scanCounter.
+const (
+	scanResultSuccess     = "success"
+	scanResultAuthFailed  = "auth_failed"
+	scanResultRateLimited = "rate_limited"
+	scanResultFailed      = "failed"
+)
+
+// tagChurnGauge records the number of tags added and removed between the last
+// two scans of an ImageRepository.
+var tagChurnGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_repository_tag_churn",
+		Help: "The number of tags added and removed since the previous scan of an image repository.",
+	},
+	[]string{"name", "namespace"},
+)
+
+// tagCountGauge records the number of tags stored by the last successful scan
+// of an ImageRepository.
+var tagCountGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_repository_tags",
+		Help: "The number of tags stored by the last successful scan of an image repository.",
+	},
+	[]string{"name", "namespace"},
+)
+
+// storedSizeGauge records the number of bytes taken by the records of an
+// ImageRepository in the tags database, to spot the repositories dominating
+// it.
+var storedSizeGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_repository_stored_bytes",
+		Help: "The number of bytes taken by the tags of an image repository in the database, as of its last successful scan.",
+	},
+	[]string{"name", "namespace"},
+)
+
+// scanCounter counts the scans of an ImageRepository by result.
+var scanCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "image_repository_scans_total",
+		Help: "The number of scans of an image repository by result.",
+	},
+	[]string{"name", "namespace", "result"},
+)
+
+// applyPolicyHistogram records the duration of the evaluation of the policy
+// of an ImagePolicy.
+var applyPolicyHistogram = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "image_policy_apply_duration_seconds",
+		Help:    "The duration in seconds of the evaluation of an image policy against the tags of its image repository.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+	},
+	[]string{"name", "namespace"},
+)
+
+// latestImageFirstSeenGauge records the time the tag of the latest image of
+// an ImagePolicy was first seen, to alert on policies whose latest image gets
+// old.
+var latestImageFirstSeenGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_policy_latest_image_first_seen_timestamp_seconds",
+		Help: "The time the tag of the latest image of an image policy was first seen by a scan, in seconds since the epoch.",
+	},
+	[]string{"name", "namespace"},
+)
+
+// registryReachableGauge records whether the registries of the
+// ImageRepositories were reachable by the last probe of the RegistryProber.
+var registryReachableGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_registry_reachable",
+		Help: "Whether the /v2/ endpoint of a registry was reachable by the last probe, 1 if it was and 0 otherwise.",
+	},
+	[]string{"registry"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(tagChurnGauge, tagCountGauge, storedSizeGauge, scanCounter, applyPolicyHistogram,
+		latestImageFirstSeenGauge, registryReachableGauge)
+}
+
+// RegisterDatabaseMetrics registers the metric of the total size of the given
+// database, which is read when the metrics are collected.
+func RegisterDatabaseMetrics(db DatabaseSizer) error {
+	return metrics.Registry.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "image_database_size_bytes",
+			Help: "The total number of bytes taken by the tags database.",
+		},
+		func() float64 { return float64(db.Size()) },
+	))
+}
+
+// recordTagChurn records the tag churn of the given ImageRepository.
+func recordTagChurn(name, namespace string, churn int) {
+	tagChurnGauge.WithLabelValues(name, namespace).Set(float64(churn))
+}
+
+// recordTagCount records the number of tags stored for the given
+// ImageRepository.
+func recordTagCount(name, namespace string, count int) {
+	tagCountGauge.WithLabelValues(name, namespace).Set(float64(count))
+}
+
+// recordStoredSize records the number of bytes taken by the records of the
+// given ImageRepository in the database.
+func recordStoredSize(name, namespace string, size int64) {
+	storedSizeGauge.WithLabelValues(name, namespace).Set(float64(size))
+}
+
+// recordScan counts a scan of the given ImageRepository with the given
+// result.
+func recordScan(name, namespace, result string) {
+	scanCounter.WithLabelValues(name, namespace, result).Inc()
+}
+
+// deleteImageRepositoryMetrics removes the metrics of the given
+// ImageRepository.
+func deleteImageRepositoryMetrics(name, namespace string) {
+	tagChurnGauge.DeleteLabelValues(name, namespace)
+	tagCountGauge.DeleteLabelValues(name, namespace)
+	storedSizeGauge.DeleteLabelValues(name, namespace)
+	scanCounter.DeletePartialMatch(prometheus.Labels{"name": name, "namespace": namespace})
+}
+
+// recordApplyPolicy records the duration of the evaluation of the policy of
+// the given ImagePolicy.
+func recordApplyPolicy(name, namespace string, d time.Duration) {
+	applyPolicyHistogram.WithLabelValues(name, namespace).Observe(d.Seconds())
+}
+
+// recordLatestImageFirstSeen records the time the tag of the latest image of
+// the given ImagePolicy was first seen. A nil time removes the metric.
+func recordLatestImageFirstSeen(name, namespace string, firstSeen *metav1.Time) {
+	if firstSeen == nil {
+		latestImageFirstSeenGauge.DeleteLabelValues(name, namespace)
+		return
+	}
+	latestImageFirstSeenGauge.WithLabelValues(name, namespace).Set(float64(firstSeen.Unix()))
+}
+
+// deleteImagePolicyMetrics removes the metrics of the given ImagePolicy.
+func deleteImagePolicyMetrics(name, namespace string) {
+	applyPolicyHistogram.DeleteLabelValues(name, namespace)
+	latestImageFirstSeenGauge.DeleteLabelValues(name, namespace)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageRepositoryMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	name, namespace := "repo-"+randStringRunes(5), "default"
+
+	recordScan(name, namespace, scanResultSuccess)
+	recordScan(name, namespace, scanResultSuccess)
+	recordScan(name, namespace, scanResultRateLimited)
+	recordTagCount(name, namespace, 3)
+	recordStoredSize(name, namespace, 512)
+
+	g.Expect(testutil.ToFloat64(scanCounter.WithLabelValues(name, namespace, scanResultSuccess))).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(scanCounter.WithLabelValues(name, namespace, scanResultRateLimited))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(tagCountGauge.WithLabelValues(name, namespace))).To(Equal(float64(3)))
+	g.Expect(testutil.ToFloat64(storedSizeGauge.WithLabelValues(name, namespace))).To(Equal(float64(512)))
+
+	deleteImageRepositoryMetrics(name, namespace)
+	g.Expect(scanCounter.DeleteLabelValues(name, namespace, scanResultSuccess)).To(BeFalse())
+	g.Expect(scanCounter.DeleteLabelValues(name, namespace, scanResultRateLimited)).To(BeFalse())
+	g.Expect(tagCountGauge.DeleteLabelValues(name, namespace)).To(BeFalse())
+	g.Expect(storedSizeGauge.DeleteLabelValues(name, namespace)).To(BeFalse())
+}
+
+func TestImagePolicyMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	name, namespace := "policy-"+randStringRunes(5), "default"
+
+	recordApplyPolicy(name, namespace, 5*time.Millisecond)
+	g.Expect(testutil.CollectAndCount(applyPolicyHistogram)).To(BeNumerically(">=", 1))
+
+	firstSeen := metav1.NewTime(time.Unix(1700000000, 0))
+	recordLatestImageFirstSeen(name, namespace, &firstSeen)
+	g.Expect(testutil.ToFloat64(latestImageFirstSeenGauge.WithLabelValues(name, namespace))).To(Equal(float64(1700000000)))
+
+	deleteImagePolicyMetrics(name, namespace)
+	g.Expect(applyPolicyHistogram.DeleteLabelValues(name, namespace)).To(BeFalse())
+	g.Expect(latestImageFirstSeenGauge.DeleteLabelValues(name, namespace)).To(BeFalse())
+
+	// Without a first seen time, the metric is removed.
+	recordLatestImageFirstSeen(name, namespace, &firstSeen)
+	recordLatestImageFirstSeen(name, namespace, nil)
+	g.Expect(latestImageFirstSeenGauge.DeleteLabelValues(name, namespace)).To(BeFalse())
+}
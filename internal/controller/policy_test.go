@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -351,6 +352,105 @@ func TestImagePolicyReconciler_filterTags(t *testing.T) {
 	}
 }
 
+func TestImagePolicyReconciler_annotations(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-dry-run-"+randStringRunes(5), []string{"1.0.0", "2.0.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repo := imagev1.ImageRepository{
+		Spec: imagev1.ImageRepositorySpec{
+			Interval: metav1.Duration{Duration: reconciliationInterval},
+			Image:    imgRepo,
+		},
+	}
+	imageObjectName := types.NamespacedName{
+		Name:      "polimage-" + randStringRunes(5),
+		Namespace: "default",
+	}
+	repo.Name = imageObjectName.Name
+	repo.Namespace = imageObjectName.Namespace
+
+	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	defer cancel()
+
+	g.Expect(testEnv.Create(ctx, &repo)).To(Succeed())
+	defer func() {
+		g.Expect(testEnv.Delete(ctx, &repo)).To(Succeed())
+	}()
+
+	polName := types.NamespacedName{
+		Name:      "random-pol-" + randStringRunes(5),
+		Namespace: imageObjectName.Namespace,
+	}
+	pol := imagev1.ImagePolicy{
+		Spec: imagev1.ImagePolicySpec{
+			ImageRepositoryRef: meta.NamespacedObjectReference{
+				Name: imageObjectName.Name,
+			},
+			Policy: imagev1.ImagePolicyChoice{
+				SemVer: &imagev1.SemVerPolicy{
+					Range: "1.x",
+				},
+			},
+		},
+	}
+	pol.Namespace = polName.Namespace
+	pol.Name = polName.Name
+
+	g.Expect(testEnv.Create(ctx, &pol)).To(Succeed())
+	defer func() {
+		g.Expect(testEnv.Delete(ctx, &pol)).To(Succeed())
+	}()
+
+	g.Eventually(func() bool {
+		err := testEnv.Get(ctx, polName, &pol)
+		return err == nil && pol.Status.LatestImage == imgRepo+":1.0.0"
+	}, timeout, interval).Should(BeTrue())
+
+	// In dry-run mode, the new range is only reported in the Ready
+	// condition.
+	patchHelper, err := patch.NewHelper(&pol, testEnv.Client)
+	g.Expect(err).ToNot(HaveOccurred())
+	pol.Annotations = map[string]string{imagev1.DryRunAnnotation: "true"}
+	pol.Spec.Policy.SemVer.Range = "2.x"
+	g.Expect(patchHelper.Patch(ctx, &pol)).To(Succeed())
+
+	g.Eventually(func() bool {
+		err := testEnv.Get(ctx, polName, &pol)
+		return err == nil && pol.Status.ObservedGeneration == pol.Generation &&
+			conditions.IsReady(&pol) &&
+			strings.HasPrefix(conditions.GetMessage(&pol, meta.ReadyCondition), "Dry run:")
+	}, timeout, interval).Should(BeTrue())
+	g.Expect(pol.Status.LatestImage).To(Equal(imgRepo + ":1.0.0"))
+
+	// Removing the annotation, without a new generation, applies the policy.
+	patchHelper, err = patch.NewHelper(&pol, testEnv.Client)
+	g.Expect(err).ToNot(HaveOccurred())
+	pol.Annotations = nil
+	g.Expect(patchHelper.Patch(ctx, &pol)).To(Succeed())
+
+	g.Eventually(func() bool {
+		err := testEnv.Get(ctx, polName, &pol)
+		return err == nil && pol.Status.LatestImage == imgRepo+":2.0.0"
+	}, timeout, interval).Should(BeTrue())
+
+	// Adding the debug annotation reports the candidate tags.
+	patchHelper, err = patch.NewHelper(&pol, testEnv.Client)
+	g.Expect(err).ToNot(HaveOccurred())
+	pol.Annotations = map[string]string{imagev1.DebugAnnotation: "true"}
+	g.Expect(patchHelper.Patch(ctx, &pol)).To(Succeed())
+
+	g.Eventually(func() bool {
+		err := testEnv.Get(ctx, polName, &pol)
+		return err == nil && pol.Status.Debug != nil
+	}, timeout, interval).Should(BeTrue())
+	g.Expect(pol.Status.Debug.CandidateTags).To(Equal([]string{"2.0.0"}))
+}
+
 func TestImagePolicyReconciler_accessImageRepo(t *testing.T) {
 	tests := []struct {
 		name                       string
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// retryAfterJitter is the maximum fraction of the Retry-After duration added
+// to it, to spread the scans of the repositories rate limited at the same
+// time.
+const retryAfterJitter = 0.1
+
+const (
+	// minRateLimitBackoff is the first backoff of the ImageRepositories
+	// without a scan interval rate limited without a Retry-After.
+	minRateLimitBackoff = time.Minute
+	// maxRateLimitBackoff caps the backoff of the ImageRepositories rate
+	// limited without a Retry-After, unless their scan interval is longer.
+	maxRateLimitBackoff = 6 * time.Hour
+)
+
+// retryAfterKey is the context key of the retryAfterRecorder of a scan.
+type retryAfterKey struct{}
+
+// retryAfterRecorder records the Retry-After duration of the last rate
+// limited response received during a scan.
+type retryAfterRecorder struct {
+	mu         sync.Mutex
+	retryAfter time.Duration
+}
+
+func (r *retryAfterRecorder) set(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retryAfter = d
+}
+
+func (r *retryAfterRecorder) get() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.retryAfter
+}
+
+// withRetryAfterRecorder returns a context carrying a new retryAfterRecorder,
+// for the rateLimitTransport to record the Retry-After of the requests made
+// with the context.
+func withRetryAfterRecorder(ctx context.Context) (context.Context, *retryAfterRecorder) {
+	rec := &retryAfterRecorder{}
+	return context.WithValue(ctx, retryAfterKey{}, rec), rec
+}
+
+// rateLimitTransport is a http.RoundTripper that records the Retry-After of
+// the rate limited responses in the retryAfterRecorder of the request
+// context, if any.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	if rec, ok := req.Context().Value(retryAfterKey{}).(*retryAfterRecorder); ok {
+		rec.set(parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+	}
+	return resp, err
+}
+
+// parseRetryAfter returns the duration to wait given by the value of a
+// Retry-After header, which is either a number of seconds or an HTTP date.
+// It returns zero if the value is missing, invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// rateLimitError is returned when a registry rate limits a scan and asks to
+// retry after a given duration.
+type rateLimitError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *rateLimitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the registry.
+func (e *rateLimitError) Unwrap() error {
+	return e.err
+}
+
+// withJitter returns the duration with up to retryAfterJitter of it added at
+// random.
+func withJitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Float64()*retryAfterJitter*float64(d))
+}
+
+// rateLimitBackoffs counts the consecutive scans of each ImageRepository
+// rate limited by the registry without a Retry-After, to back off from them
+// exponentially. A nil rateLimitBackoffs counts every scan as the first one.
+type rateLimitBackoffs struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func newRateLimitBackoffs() *rateLimitBackoffs {
+	return &rateLimitBackoffs{
+		attempts: make(map[string]int),
+	}
+}
+
+// next records a rate limited scan of the ImageRepository with the given key
+// and returns the duration to wait before the next scan, doubling from the
+// scan interval with every consecutive rate limited scan.
+func (b *rateLimitBackoffs) next(key string, interval time.Duration) time.Duration {
+	attempts := 1
+	if b != nil {
+		b.mu.Lock()
+		b.attempts[key]++
+		attempts = b.attempts[key]
+		b.mu.Unlock()
+	}
+	return rateLimitBackoff(interval, attempts)
+}
+
+// reset forgets the rate limited scans of the ImageRepository with the given
+// key.
+func (b *rateLimitBackoffs) reset(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.attempts, key)
+}
+
+// rateLimitBackoff returns the backoff after the given number of consecutive
+// rate limited scans, doubling from the scan interval and capped at
+// maxRateLimitBackoff, or at the interval if it's longer.
+func rateLimitBackoff(interval time.Duration, attempts int) time.Duration {
+	d := interval
+	if d <= 0 {
+		d = minRateLimitBackoff
+	}
+	limit := maxRateLimitBackoff
+	if interval > limit {
+		limit = interval
+	}
+	for i := 1; i < attempts && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/patch"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	imageref "github.com/fluxcd/image-reflector-controller/internal/registry"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "empty", value: "", want: 0},
+		{name: "seconds", value: "120", want: 2 * time.Minute},
+		{name: "zero seconds", value: "0", want: 0},
+		{name: "negative seconds", value: "-5", want: 0},
+		{name: "http date", value: "Mon, 01 Jan 2024 12:05:00 GMT", want: 5 * time.Minute},
+		{name: "http date in the past", value: "Mon, 01 Jan 2024 11:55:00 GMT", want: 0},
+		{name: "invalid", value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(parseRetryAfter(tt.value, now)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	g := NewWithT(t)
+
+	d := time.Minute
+	for i := 0; i < 100; i++ {
+		j := withJitter(d)
+		g.Expect(j).To(BeNumerically(">=", d))
+		g.Expect(j).To(BeNumerically("<=", d+time.Duration(retryAfterJitter*float64(d))))
+	}
+}
+
+func TestImageRepositoryReconciler_scanRateLimited(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     string
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:           "with retry after",
+			retryAfter:     "120",
+			wantRetryAfter: 2 * time.Minute,
+		},
+		{
+			name: "without retry after",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			imgRepo := test.RegistryName(srv) + "/foo"
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image: imgRepo,
+			}
+
+			ref, _, err := imageref.Canonicalize(imgRepo, false)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// Disable the retries of the registry client.
+			opts := []remote.Option{
+				remote.WithTransport(r.registryTransport(nil)),
+				remote.WithRetryBackoff(remote.Backoff{Steps: 1}),
+			}
+
+			_, err = r.scan(context.TODO(), repo, ref, opts, nil)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(registryStatusCode(err)).To(Equal(http.StatusTooManyRequests))
+
+			var rlErr *rateLimitError
+			g.Expect(errors.As(err, &rlErr)).To(Equal(tt.wantRetryAfter > 0))
+			if tt.wantRetryAfter > 0 {
+				g.Expect(rlErr.retryAfter).To(Equal(tt.wantRetryAfter))
+			}
+		})
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "first attempt",
+			interval: 5 * time.Minute,
+			attempts: 1,
+			want:     5 * time.Minute,
+		},
+		{
+			name:     "doubled",
+			interval: 5 * time.Minute,
+			attempts: 3,
+			want:     20 * time.Minute,
+		},
+		{
+			name:     "capped",
+			interval: 5 * time.Minute,
+			attempts: 20,
+			want:     maxRateLimitBackoff,
+		},
+		{
+			name:     "interval longer than the cap",
+			interval: 24 * time.Hour,
+			attempts: 3,
+			want:     24 * time.Hour,
+		},
+		{
+			name:     "no interval",
+			attempts: 2,
+			want:     2 * minRateLimitBackoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(rateLimitBackoff(tt.interval, tt.attempts)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRateLimitBackoffs(t *testing.T) {
+	g := NewWithT(t)
+
+	b := newRateLimitBackoffs()
+	g.Expect(b.next("default/foo", time.Minute)).To(Equal(time.Minute))
+	g.Expect(b.next("default/foo", time.Minute)).To(Equal(2 * time.Minute))
+	g.Expect(b.next("default/bar", time.Minute)).To(Equal(time.Minute))
+	b.reset("default/foo")
+	g.Expect(b.next("default/foo", time.Minute)).To(Equal(time.Minute))
+
+	// A nil rateLimitBackoffs doesn't count the attempts.
+	var nilBackoffs *rateLimitBackoffs
+	g.Expect(nilBackoffs.next("default/foo", time.Minute)).To(Equal(time.Minute))
+	nilBackoffs.reset("default/foo")
+}
+
+func TestImageRepositoryReconciler_rateLimitedWithoutRetryAfter(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	obj := &imagev1.ImageRepository{}
+	obj.Name = "rate-limited-repo"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.Image = test.RegistryName(srv) + "/foo"
+	obj.Spec.Interval = metav1.Duration{Duration: 10 * time.Minute}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj).WithStatusSubresource(obj).Build()
+	r := &ImageRepositoryReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+		rateLimits:    newRateLimitBackoffs(),
+	}
+	// The previous scan was rate limited as well.
+	r.rateLimits.next(client.ObjectKeyFromObject(obj).String(), obj.Spec.Interval.Duration)
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	result, err := r.reconcile(context.TODO(), sp, obj, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(imagev1.RateLimitedReason))
+	g.Expect(obj.Status.LastScanHTTPStatusCode).To(Equal(http.StatusTooManyRequests))
+
+	// The backoff is doubled from the scan interval, with the jitter added.
+	wantBackoff := 20 * time.Minute
+	g.Expect(result.RequeueAfter).To(BeNumerically(">=", wantBackoff))
+	g.Expect(result.RequeueAfter).To(BeNumerically("<=", wantBackoff+time.Duration(retryAfterJitter*float64(wantBackoff))))
+}
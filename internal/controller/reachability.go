@@ -0,0 +1,224 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/registry"
+)
+
+// registryProbeTimeout is the timeout of a probe of a registry.
+const registryProbeTimeout = 10 * time.Second
+
+// RegistryProber periodically probes the `/v2/` endpoint of the distinct
+// registries of the ImageRepositories, including their mirrors, to tell
+// whether the controller can reach them, e.g. to detect a network policy
+// blocking the egress traffic before the ImageRepositories fail to be
+// scanned. Any HTTP response, including an authentication challenge, means
+// the registry is reachable, and so does a certificate that fails to be
+// verified, as the probes don't use the certificates of the ImageRepositories.
+// The results are recorded in the
+// image_registry_reachable metric, and Check reports the unreachable
+// registries as a health check.
+type RegistryProber struct {
+	// Client lists the ImageRepositories.
+	Client client.Reader
+	// Interval is the duration between two probes of the registries.
+	Interval time.Duration
+	// Transport is the transport used to connect to the registries. Defaults
+	// to http.DefaultTransport.
+	Transport http.RoundTripper
+	// ImageVariables are the values of the variables referenced in the
+	// images of the ImageRepositories.
+	ImageVariables map[string]string
+
+	mu          sync.Mutex
+	unreachable map[string]error
+}
+
+// Start probes the registries at the interval until the context is done. It
+// implements manager.Runnable.
+func (p *RegistryProber) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		p.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false, as every replica of the controller
+// reports its own reachability. It implements
+// manager.LeaderElectionRunnable.
+func (p *RegistryProber) NeedLeaderElection() bool {
+	return false
+}
+
+// Check returns an error listing the registries that were unreachable by the
+// last probe. It implements healthz.Checker.
+func (p *RegistryProber) Check(_ *http.Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.unreachable) == 0 {
+		return nil
+	}
+	hosts := make([]string, 0, len(p.unreachable))
+	for host := range p.unreachable {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return fmt.Errorf("unreachable registries: %s", strings.Join(hosts, ", "))
+}
+
+// probeAll probes the registries of the ImageRepositories concurrently and
+// records the results.
+func (p *RegistryProber) probeAll(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx).WithName("registry-prober")
+	registries, err := p.registries(ctx)
+	if err != nil {
+		log.Error(err, "failed to list the registries to probe")
+		return
+	}
+
+	var mu sync.Mutex
+	unreachable := map[string]error{}
+	var wg sync.WaitGroup
+	for host, endpoint := range registries {
+		wg.Add(1)
+		go func(host, endpoint string) {
+			defer wg.Done()
+			if err := p.probe(ctx, endpoint); err != nil {
+				mu.Lock()
+				unreachable[host] = err
+				mu.Unlock()
+			}
+		}(host, endpoint)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	registryReachableGauge.Reset()
+	for host := range registries {
+		reachable := 1.0
+		if err, ok := unreachable[host]; ok {
+			reachable = 0
+			log.Info("registry unreachable", "registry", host, "error", err.Error())
+		}
+		registryReachableGauge.WithLabelValues(host).Set(reachable)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unreachable = unreachable
+}
+
+// registries returns the `/v2/` endpoints of the distinct registries of the
+// ImageRepositories and their mirrors, by host.
+func (p *RegistryProber) registries(ctx context.Context) (map[string]string, error) {
+	var list imagev1.ImageRepositoryList
+	if err := p.Client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	registries := map[string]string{}
+	add := func(reg name.Registry, scheme string) {
+		if scheme == "" {
+			scheme = reg.Scheme()
+		}
+		registries[reg.RegistryStr()] = scheme + "://" + reg.RegistryStr() + "/v2/"
+	}
+	for _, obj := range list.Items {
+		insecure := obj.Spec.Insecure
+		image, err := registry.ExpandImage(obj.Spec.Image, p.ImageVariables)
+		if err != nil {
+			continue
+		}
+		ref, _, err := registry.Canonicalize(image, insecure)
+		if err != nil {
+			continue
+		}
+		add(ref.Context().Registry, obj.Spec.Scheme)
+		for _, mirror := range obj.Spec.Mirrors {
+			var opts []name.Option
+			if insecure {
+				opts = append(opts, name.Insecure)
+			}
+			reg, err := name.NewRegistry(mirror, opts...)
+			if err != nil {
+				continue
+			}
+			add(reg, "")
+		}
+	}
+	return registries, nil
+}
+
+// probe makes a request to the given endpoint, returning an error if no
+// response is received. A certificate that fails to be verified was sent by
+// the registry, so it isn't an error.
+func (p *RegistryProber) probe(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, registryProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		if isCertificateError(err) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// isCertificateError returns true if the error is a failure to verify the
+// certificate of a server, e.g. signed by an unknown authority.
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
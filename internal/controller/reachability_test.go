@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+)
+
+func TestRegistryProber(t *testing.T) {
+	g := NewWithT(t)
+
+	// A registry answering with an authentication challenge is reachable.
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.URL.Path).To(Equal("/v2/"))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer reachable.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	// A registry whose certificate isn't trusted by the probes is reachable.
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+
+	reachableHost := test.RegistryName(reachable)
+	unreachableHost := test.RegistryName(unreachable)
+	untrustedHost := test.RegistryName(untrusted)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	repo := &imagev1.ImageRepository{}
+	repo.Name = "repo"
+	repo.Namespace = "default"
+	repo.Spec.Image = reachableHost + "/app"
+	repo.Spec.Insecure = true
+	other := repo.DeepCopy()
+	other.Name = "other"
+	other.Spec.Image = reachableHost + "/other"
+	other.Spec.Mirrors = []string{unreachableHost}
+	untrustedRepo := repo.DeepCopy()
+	untrustedRepo.Name = "untrusted"
+	untrustedRepo.Spec.Image = untrustedHost + "/app"
+	untrustedRepo.Spec.Insecure = false
+	untrustedRepo.Spec.Scheme = "https"
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo, untrustedRepo).Build()
+	p := &RegistryProber{Client: c}
+
+	p.probeAll(context.TODO())
+	g.Expect(p.Check(nil)).To(Succeed())
+	g.Expect(testutil.ToFloat64(registryReachableGauge.WithLabelValues(reachableHost))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(registryReachableGauge.WithLabelValues(untrustedHost))).To(Equal(float64(1)))
+
+	// The registries of the mirrors are probed as well, once.
+	g.Expect(c.Create(context.TODO(), other)).To(Succeed())
+	p.probeAll(context.TODO())
+	g.Expect(p.Check(nil)).To(MatchError("unreachable registries: " + unreachableHost))
+	g.Expect(testutil.ToFloat64(registryReachableGauge.WithLabelValues(reachableHost))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(registryReachableGauge.WithLabelValues(unreachableHost))).To(Equal(float64(0)))
+	g.Expect(testutil.CollectAndCount(registryReachableGauge)).To(Equal(3))
+
+	// The registries no longer referenced are forgotten.
+	g.Expect(c.Delete(context.TODO(), other)).To(Succeed())
+	p.probeAll(context.TODO())
+	g.Expect(p.Check(nil)).To(Succeed())
+	g.Expect(testutil.CollectAndCount(registryReachableGauge)).To(Equal(2))
+}
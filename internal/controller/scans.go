@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlightScans tracks the scans in progress per ImageRepository, so that
+// they can be cancelled when the ImageRepository is deleted instead of
+// running against the registry until they time out.
+// A nil inFlightScans tracks nothing.
+type inFlightScans struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newInFlightScans() *inFlightScans {
+	return &inFlightScans{
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// start returns a context for the scan of the ImageRepository with the given
+// key, cancelled by cancel, and a function to call when the scan is done.
+func (s *inFlightScans) start(ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	if s == nil {
+		return ctx, cancel
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancels[key] = cancel
+	return ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.cancels, key)
+		cancel()
+	}
+}
+
+// cancel cancels the scan in progress of the ImageRepository with the given
+// key, if any.
+func (s *inFlightScans) cancel(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.cancels[key]; ok {
+		cancel()
+		delete(s.cancels, key)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// scanSummaryKey is the context key of the scanSummary of a scan.
+type scanSummaryKey struct{}
+
+// scanSummary collects the details of a scan that are only known deep in
+// the scan, to log them in a single line once the scan is done.
+type scanSummary struct {
+	mu         sync.Mutex
+	authMethod string
+	newTags    int
+}
+
+// withScanSummary returns a context carrying a new scanSummary, for the scan
+// made with the context to record its details.
+func withScanSummary(ctx context.Context) (context.Context, *scanSummary) {
+	s := &scanSummary{}
+	return context.WithValue(ctx, scanSummaryKey{}, s), s
+}
+
+// scanSummaryFrom returns the scanSummary of the context, or nil.
+func scanSummaryFrom(ctx context.Context) *scanSummary {
+	s, _ := ctx.Value(scanSummaryKey{}).(*scanSummary)
+	return s
+}
+
+// setAuthMethod records the name of the credentials accepted by the
+// registry.
+func (s *scanSummary) setAuthMethod(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authMethod = name
+}
+
+// setNewTags records the number of tags that weren't stored before the scan.
+func (s *scanSummary) setNewTags(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.newTags = n
+}
+
+// log logs the summary of the scan of the given repository with stable keys,
+// for the logs to be aggregated. The result is one of the scan results
+// recorded by scanCounter.
+func (s *scanSummary) log(ctx context.Context, repository string, tagsFound int, duration time.Duration, result string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctrl.LoggerFrom(ctx).Info("scan summary",
+		"repository", repository,
+		"tagsFound", tagsFound,
+		"newTags", s.newTags,
+		"durationSeconds", duration.Seconds(),
+		"authMethod", s.authMethod,
+		"result", result,
+	)
+}
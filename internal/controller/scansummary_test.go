@@ -0,0 +1,88 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/google/go-containerregistry/pkg/registry"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	imageref "github.com/fluxcd/image-reflector-controller/internal/registry"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+)
+
+func TestScanSummary(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-summary-"+randStringRunes(5), []string{"a", "b", "c"})
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, canonicalName, err := imageref.Canonicalize(imgRepo, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// One of the tags was stored by an earlier scan.
+	db := &mockDatabase{TagData: []string{"a"}}
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+	}
+
+	ctx, summary := withScanSummary(context.TODO())
+	tags, err := r.scan(ctx, repo, ref, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(summary.authMethod).To(Equal("anonymous"))
+	g.Expect(summary.newTags).To(Equal(2))
+
+	// The summary is logged in a single line with stable keys.
+	var lines int
+	logger := funcr.NewJSON(func(obj string) {
+		lines++
+		g.Expect(obj).To(ContainSubstring(`"msg":"scan summary"`))
+		g.Expect(obj).To(ContainSubstring(`"repository":"` + canonicalName + `"`))
+		g.Expect(obj).To(ContainSubstring(`"tagsFound":3`))
+		g.Expect(obj).To(ContainSubstring(`"newTags":2`))
+		g.Expect(obj).To(ContainSubstring(`"durationSeconds":1.5`))
+		g.Expect(obj).To(ContainSubstring(`"authMethod":"anonymous"`))
+		g.Expect(obj).To(ContainSubstring(`"result":"success"`))
+	}, funcr.Options{})
+	summary.log(logr.NewContext(context.TODO(), logger), canonicalName, tags, 1500*time.Millisecond, scanResultSuccess)
+	g.Expect(lines).To(Equal(1))
+}
+
+func TestScanSummary_nil(t *testing.T) {
+	// Recording in a context without a summary is a no-op.
+	s := scanSummaryFrom(context.TODO())
+	s.setAuthMethod("anonymous")
+	s.setNewTags(1)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+)
+
+// schemeTransport is a http.RoundTripper that sends the requests to the
+// registry host with the given scheme. The registry client probes the
+// registry over HTTPS, and over HTTP as well for insecure registries, which
+// adds the latency of the failed attempt when the protocol of the registry is
+// known. The requests to other hosts, such as token servers, are unchanged.
+type schemeTransport struct {
+	base   http.RoundTripper
+	scheme string
+	host   string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host || req.URL.Scheme == t.scheme {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.scheme
+	return t.base.RoundTrip(req)
+}
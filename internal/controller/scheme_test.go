@@ -0,0 +1,200 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/patch"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	imageref "github.com/fluxcd/image-reflector-controller/internal/registry"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+)
+
+// roundTripFunc is a http.RoundTripper calling itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSchemeTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  string
+		url     string
+		wantURL string
+	}{
+		{
+			name:    "https to http",
+			scheme:  "http",
+			url:     "https://registry.example.com/v2/",
+			wantURL: "http://registry.example.com/v2/",
+		},
+		{
+			name:    "http to https",
+			scheme:  "https",
+			url:     "http://registry.example.com/v2/",
+			wantURL: "https://registry.example.com/v2/",
+		},
+		{
+			name:    "same scheme",
+			scheme:  "http",
+			url:     "http://registry.example.com/v2/",
+			wantURL: "http://registry.example.com/v2/",
+		},
+		{
+			name:    "other host",
+			scheme:  "http",
+			url:     "https://auth.example.com/token",
+			wantURL: "https://auth.example.com/token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var gotURL string
+			tr := &schemeTransport{
+				base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					gotURL = req.URL.String()
+					return &http.Response{StatusCode: http.StatusOK}, nil
+				}),
+				scheme: tt.scheme,
+				host:   "registry.example.com",
+			}
+
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			_, err = tr.RoundTrip(req)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(gotURL).To(Equal(tt.wantURL))
+			// The original request is left unchanged.
+			g.Expect(req.URL.String()).To(Equal(tt.url))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanScheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  string
+		wantErr bool
+	}{
+		{
+			name: "detected",
+		},
+		{
+			name:   "http",
+			scheme: imagev1.SchemeHTTP,
+		},
+		{
+			name:    "https",
+			scheme:  imagev1.SchemeHTTPS,
+			wantErr: true,
+		},
+	}
+
+	// The registry is served over plain HTTP.
+	registryServer := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-scheme-"+randStringRunes(5), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := ImageRepositoryReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      &mockDatabase{},
+				patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+			}
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image:    imgRepo,
+				Insecure: true,
+				Scheme:   tt.scheme,
+			}
+
+			ref, _, err := imageref.Canonicalize(imgRepo, true)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			opts, creds, err := r.setAuthOptions(context.TODO(), repo, ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			// Disable the retries of the registry client.
+			opts = append(opts, remote.WithRetryBackoff(remote.Backoff{Steps: 1}))
+
+			tagCount, err := r.scan(context.TODO(), repo, ref, opts, creds)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tagCount).To(Equal(2))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_httpSchemeRequiresInsecure(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(imagev1.AddToScheme(scheme)).To(Succeed())
+
+	obj := &imagev1.ImageRepository{}
+	obj.Name = "http-repo"
+	obj.Namespace = "default"
+	obj.Generation = 1
+	obj.Spec.Image = "registry.local:5000/foo/bar"
+	obj.Spec.Scheme = imagev1.SchemeHTTP
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj).WithStatusSubresource(obj).Build()
+	r := &ImageRepositoryReconciler{
+		Client:        c,
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      &mockDatabase{},
+		patchOptions:  getPatchOptions(imageRepositoryOwnedConditions, "irc"),
+	}
+
+	sp := patch.NewSerialPatcher(obj, r.Client)
+	_, err := r.reconcile(context.TODO(), sp, obj, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.IsStalled(obj)).To(BeTrue())
+	g.Expect(conditions.GetReason(obj, meta.StalledCondition)).To(Equal(imagev1.InvalidSchemeReason))
+}
@@ -85,11 +85,12 @@ func TestMain(m *testing.M) {
 		panic(fmt.Sprintf("Failed to create new Badger database: %v", err))
 	}
 
-	if err = (&ImageRepositoryReconciler{
+	imageRepositoryReconciler := &ImageRepositoryReconciler{
 		Client:        testEnv,
 		Database:      database.NewBadgerDatabase(testBadgerDB),
 		EventRecorder: record.NewFakeRecorder(256),
-	}).SetupWithManager(testEnv, ImageRepositoryReconcilerOptions{
+	}
+	if err = imageRepositoryReconciler.SetupWithManager(testEnv, ImageRepositoryReconcilerOptions{
 		RateLimiter: controller.GetDefaultRateLimiter(),
 	}); err != nil {
 		panic(fmt.Sprintf("Failed to start ImageRepositoryReconciler: %v", err))
@@ -99,6 +100,7 @@ func TestMain(m *testing.M) {
 		Client:        testEnv,
 		Database:      database.NewBadgerDatabase(testBadgerDB),
 		EventRecorder: record.NewFakeRecorder(256),
+		ResolveDigest: imageRepositoryReconciler.ResolveDigest,
 	}).SetupWithManager(testEnv, ImagePolicyReconcilerOptions{
 		RateLimiter: controller.GetDefaultRateLimiter(),
 	}); err != nil {
@@ -18,15 +18,36 @@ package database
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
-const tagsPrefix = "tags"
+const (
+	tagsPrefix      = "tags"
+	firstSeenPrefix = "firstseen"
+	sizesPrefix     = "sizes"
+)
+
+// BadgerName is the name the Badger database is registered by.
+const BadgerName = "badger"
+
+func init() {
+	Register(BadgerName, openBadger)
+}
 
 // BadgerDatabase provides implementations of the tags database based on Badger.
+// It is safe for concurrent use, as every read and write is performed in its
+// own Badger transaction.
 type BadgerDatabase struct {
 	db *badger.DB
+
+	// writeMu serializes the writes, which read the existing records before
+	// updating them and would otherwise conflict with each other.
+	writeMu sync.Mutex
 }
 
 // NewBadgerDatabase creates and returns a new database implementation using
@@ -37,34 +58,267 @@ func NewBadgerDatabase(db *badger.DB) *BadgerDatabase {
 	}
 }
 
-// Tags implements the DatabaseReader interface, fetching the tags for the repo.
+// openBadger opens a Badger database in the storage path of the options.
+func openBadger(opts Options) (Database, error) {
+	badgerOpts := badger.DefaultOptions(opts.StoragePath)
+	if opts.ValueLogFileSize > 0 {
+		badgerOpts.ValueLogFileSize = opts.ValueLogFileSize
+	}
+	db, err := badger.Open(badgerOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the Badger database: %w", err)
+	}
+	return NewBadgerDatabase(db), nil
+}
+
+// Close implements the io.Closer interface, closing the Badger database.
+func (a *BadgerDatabase) Close() error {
+	return a.db.Close()
+}
+
+// Tags implements the Reader interface, fetching the tags for the repo.
 //
 // If the repo does not exist, an empty set of tags is returned.
 func (a *BadgerDatabase) Tags(repo string) ([]string, error) {
+	return a.TagsWithOptions(repo, TagQuery{})
+}
+
+// TagsWithOptions implements the Reader interface, fetching the tags for the
+// repo ordered and limited according to the query. The tags are stored as a
+// single value, which is decoded in full before the query is applied. The
+// first seen times of the tags are only read when ordering by them.
+//
+// If the repo does not exist, an empty set of tags is returned.
+func (a *BadgerDatabase) TagsWithOptions(repo string, query TagQuery) ([]string, error) {
 	var tags []string
+	var firstSeen map[string]time.Time
 	err := a.db.View(func(txn *badger.Txn) error {
 		var err error
 		tags, err = getOrEmpty(txn, repo)
+		if err != nil {
+			return err
+		}
+		if query.OrderBy == TagOrderByCreated {
+			firstSeen, err = getFirstSeenOrEmpty(txn, repo)
+		}
 		return err
 	})
-	return tags, err
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(tags, firstSeen)
 }
 
-// SetTags implements the DatabaseWriter interface, recording the tags against
+// SetTags implements the Writer interface, recording the tags against
 // the repo.
 //
-// It overwrites existing tag sets for the provided repo.
+// It overwrites existing tag sets for the provided repo. The time at which each
+// tag was first recorded is kept for the tags that were already present, and
+// set to the current time for the new tags.
 func (a *BadgerDatabase) SetTags(repo string, tags []string) error {
 	b, err := marshal(tags)
 	if err != nil {
 		return err
 	}
+
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
 	return a.db.Update(func(txn *badger.Txn) error {
+		seen, err := getFirstSeenOrEmpty(txn, repo)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		firstSeen := make(map[string]time.Time, len(tags))
+		for _, tag := range tags {
+			if t, ok := seen[tag]; ok {
+				firstSeen[tag] = t
+				continue
+			}
+			firstSeen[tag] = now
+		}
+		fs, err := json.Marshal(firstSeen)
+		if err != nil {
+			return err
+		}
+		if err := txn.SetEntry(badger.NewEntry(keyForRepo(firstSeenPrefix, repo), fs)); err != nil {
+			return err
+		}
+		if err := pruneSizes(txn, repo, tags); err != nil {
+			return err
+		}
 		e := badger.NewEntry(keyForRepo(tagsPrefix, repo), b)
 		return txn.SetEntry(e)
 	})
 }
 
+// SetTagSizes implements the Writer interface, recording the sizes of the
+// images of the given tags of the repo along with the sizes already recorded.
+func (a *BadgerDatabase) SetTagSizes(repo string, sizes map[string]int64) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	return a.db.Update(func(txn *badger.Txn) error {
+		recorded, err := getSizesOrEmpty(txn, repo)
+		if err != nil {
+			return err
+		}
+		for tag, size := range sizes {
+			recorded[tag] = size
+		}
+		return setSizes(txn, repo, recorded)
+	})
+}
+
+// TagsFirstSeen implements the Reader interface, fetching the time at
+// which each tag of the repo was first recorded.
+//
+// If the repo does not exist, an empty map is returned.
+func (a *BadgerDatabase) TagsFirstSeen(repo string) (map[string]time.Time, error) {
+	var firstSeen map[string]time.Time
+	err := a.db.View(func(txn *badger.Txn) error {
+		var err error
+		firstSeen, err = getFirstSeenOrEmpty(txn, repo)
+		return err
+	})
+	return firstSeen, err
+}
+
+// TagSizes implements the Reader interface, fetching the recorded sizes of the
+// images of the tags of the repo.
+//
+// If the repo does not exist, an empty map is returned.
+func (a *BadgerDatabase) TagSizes(repo string) (map[string]int64, error) {
+	var sizes map[string]int64
+	err := a.db.View(func(txn *badger.Txn) error {
+		var err error
+		sizes, err = getSizesOrEmpty(txn, repo)
+		return err
+	})
+	return sizes, err
+}
+
+// RepoSize implements the Sizer interface, adding up the estimated sizes of
+// the keys and values of the records of the repo.
+func (a *BadgerDatabase) RepoSize(repo string) (int64, error) {
+	var size int64
+	err := a.db.View(func(txn *badger.Txn) error {
+		for _, prefix := range []string{tagsPrefix, firstSeenPrefix, sizesPrefix} {
+			item, err := txn.Get(keyForRepo(prefix, repo))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			size += item.EstimatedSize()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Size implements the Sizer interface, returning the size of the LSM tree
+// and value log files of the Badger database. Badger refreshes them
+// periodically, so the size may lag behind the latest writes, and it's zero
+// for an in-memory database.
+func (a *BadgerDatabase) Size() int64 {
+	lsm, vlog := a.db.Size()
+	return lsm + vlog
+}
+
+// Export implements the Dumper interface, writing the records of all the
+// repos from a single Badger transaction.
+func (a *BadgerDatabase) Export(w io.Writer) error {
+	dump := Dump{Version: DumpVersion, Repos: []RepoDump{}}
+	err := a.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(tagsPrefix + ":")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// The keys are iterated in order, which orders the repos by name.
+		for it.Rewind(); it.Valid(); it.Next() {
+			repo := strings.TrimPrefix(string(it.Item().Key()), tagsPrefix+":")
+			tags, err := getOrEmpty(txn, repo)
+			if err != nil {
+				return err
+			}
+			firstSeen, err := getFirstSeenOrEmpty(txn, repo)
+			if err != nil {
+				return err
+			}
+			sizes, err := getSizesOrEmpty(txn, repo)
+			if err != nil {
+				return err
+			}
+			dump.Repos = append(dump.Repos, RepoDump{
+				Name:      repo,
+				Tags:      tags,
+				FirstSeen: firstSeen,
+				Sizes:     sizes,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(dump)
+}
+
+// Import implements the Dumper interface. The dump is validated before any
+// record is written, and the records of each repo are written in their own
+// Badger transaction, so that a failed import leaves every repo with either
+// its previous or its imported records.
+func (a *BadgerDatabase) Import(r io.Reader) error {
+	var dump Dump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDump, err)
+	}
+	if err := dump.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDump, err)
+	}
+
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	for _, repo := range dump.Repos {
+		err := a.db.Update(func(txn *badger.Txn) error {
+			b, err := marshal(repo.Tags)
+			if err != nil {
+				return err
+			}
+			if err := txn.SetEntry(badger.NewEntry(keyForRepo(tagsPrefix, repo.Name), b)); err != nil {
+				return err
+			}
+			if len(repo.FirstSeen) == 0 {
+				if err := txn.Delete(keyForRepo(firstSeenPrefix, repo.Name)); err != nil {
+					return err
+				}
+			} else {
+				fs, err := json.Marshal(repo.FirstSeen)
+				if err != nil {
+					return err
+				}
+				if err := txn.SetEntry(badger.NewEntry(keyForRepo(firstSeenPrefix, repo.Name), fs)); err != nil {
+					return err
+				}
+			}
+			if len(repo.Sizes) == 0 {
+				return txn.Delete(keyForRepo(sizesPrefix, repo.Name))
+			}
+			return setSizes(txn, repo.Name, repo.Sizes)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to import the records of %q: %w", repo.Name, err)
+		}
+	}
+	return nil
+}
+
 func keyForRepo(prefix, repo string) []byte {
 	return []byte(fmt.Sprintf("%s:%s", prefix, repo))
 }
@@ -85,6 +339,63 @@ func getOrEmpty(txn *badger.Txn, repo string) ([]string, error) {
 	return tags, err
 }
 
+func getFirstSeenOrEmpty(txn *badger.Txn, repo string) (map[string]time.Time, error) {
+	firstSeen := map[string]time.Time{}
+	item, err := txn.Get(keyForRepo(firstSeenPrefix, repo))
+	if err == badger.ErrKeyNotFound {
+		return firstSeen, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &firstSeen)
+	})
+	return firstSeen, err
+}
+
+func getSizesOrEmpty(txn *badger.Txn, repo string) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	item, err := txn.Get(keyForRepo(sizesPrefix, repo))
+	if err == badger.ErrKeyNotFound {
+		return sizes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &sizes)
+	})
+	return sizes, err
+}
+
+func setSizes(txn *badger.Txn, repo string, sizes map[string]int64) error {
+	b, err := json.Marshal(sizes)
+	if err != nil {
+		return err
+	}
+	return txn.SetEntry(badger.NewEntry(keyForRepo(sizesPrefix, repo), b))
+}
+
+// pruneSizes drops the recorded sizes of the tags that aren't in the given
+// tags, leaving the repos without recorded sizes untouched.
+func pruneSizes(txn *badger.Txn, repo string, tags []string) error {
+	sizes, err := getSizesOrEmpty(txn, repo)
+	if err != nil || len(sizes) == 0 {
+		return err
+	}
+	kept := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		if size, ok := sizes[tag]; ok {
+			kept[tag] = size
+		}
+	}
+	if len(kept) == len(sizes) {
+		return nil
+	}
+	return setSizes(txn, repo, kept)
+}
+
 func marshal(t []string) ([]byte, error) {
 	return json.Marshal(t)
 }
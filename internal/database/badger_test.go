@@ -16,9 +16,15 @@ limitations under the License.
 package database
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
@@ -79,6 +85,213 @@ func TestGetOnlyFetchesForRepo(t *testing.T) {
 	}
 }
 
+func TestTagsFirstSeen(t *testing.T) {
+	db := createBadgerDatabase(t)
+
+	firstSeen, err := db.TagsFirstSeen(testRepo)
+	fatalIfError(t, err)
+	if len(firstSeen) != 0 {
+		t.Fatalf("TagsFirstSeen() for unknown repo got %#v, want empty", firstSeen)
+	}
+
+	before := time.Now().UTC()
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.1", "v0.0.2"}))
+	firstSeen1, err := db.TagsFirstSeen(testRepo)
+	fatalIfError(t, err)
+	if len(firstSeen1) != 2 {
+		t.Fatalf("TagsFirstSeen() got %#v, want 2 entries", firstSeen1)
+	}
+	for tag, seen := range firstSeen1 {
+		if seen.Before(before.Add(-time.Second)) {
+			t.Errorf("first seen time of %s is %s, expected after %s", tag, seen, before)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.2", "v0.0.3"}))
+	firstSeen2, err := db.TagsFirstSeen(testRepo)
+	fatalIfError(t, err)
+	if _, ok := firstSeen2["v0.0.1"]; ok {
+		t.Errorf("removed tag v0.0.1 should not have a first seen time")
+	}
+	if !firstSeen2["v0.0.2"].Equal(firstSeen1["v0.0.2"]) {
+		t.Errorf("first seen time of v0.0.2 changed from %s to %s", firstSeen1["v0.0.2"], firstSeen2["v0.0.2"])
+	}
+	if !firstSeen2["v0.0.3"].After(firstSeen1["v0.0.2"]) {
+		t.Errorf("first seen time of new tag v0.0.3 (%s) should be after %s", firstSeen2["v0.0.3"], firstSeen1["v0.0.2"])
+	}
+}
+
+func TestTagSizes(t *testing.T) {
+	db := createBadgerDatabase(t)
+
+	sizes, err := db.TagSizes(testRepo)
+	fatalIfError(t, err)
+	if len(sizes) != 0 {
+		t.Fatalf("TagSizes() for unknown repo got %#v, want empty", sizes)
+	}
+
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.1", "v0.0.2", "v0.0.3"}))
+	fatalIfError(t, db.SetTagSizes(testRepo, map[string]int64{"v0.0.1": 100, "v0.0.2": 200}))
+	fatalIfError(t, db.SetTagSizes(testRepo, map[string]int64{"v0.0.3": 300}))
+	sizes, err = db.TagSizes(testRepo)
+	fatalIfError(t, err)
+	want := map[string]int64{"v0.0.1": 100, "v0.0.2": 200, "v0.0.3": 300}
+	if !reflect.DeepEqual(want, sizes) {
+		t.Fatalf("TagSizes() got %#v, want %#v", sizes, want)
+	}
+
+	// The sizes of the removed tags are dropped.
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.2", "v0.0.3", "v0.0.4"}))
+	sizes, err = db.TagSizes(testRepo)
+	fatalIfError(t, err)
+	want = map[string]int64{"v0.0.2": 200, "v0.0.3": 300}
+	if !reflect.DeepEqual(want, sizes) {
+		t.Fatalf("TagSizes() after removing a tag got %#v, want %#v", sizes, want)
+	}
+}
+
+func TestRepoSize(t *testing.T) {
+	db := createBadgerDatabase(t)
+
+	size, err := db.RepoSize(testRepo)
+	fatalIfError(t, err)
+	if size != 0 {
+		t.Fatalf("RepoSize() for unknown repo got %d, want 0", size)
+	}
+
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.1", "v0.0.2"}))
+	small, err := db.RepoSize(testRepo)
+	fatalIfError(t, err)
+	if small == 0 {
+		t.Fatal("RepoSize() got 0 for a repo with tags")
+	}
+
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.1", "v0.0.2", "v0.0.3", "v0.0.4"}))
+	fatalIfError(t, db.SetTagSizes(testRepo, map[string]int64{"v0.0.1": 100}))
+	large, err := db.RepoSize(testRepo)
+	fatalIfError(t, err)
+	if large <= small {
+		t.Fatalf("RepoSize() got %d after adding tags and sizes, want more than %d", large, small)
+	}
+
+	// The records of the other repos are not counted.
+	fatalIfError(t, db.SetTags("other/repo", []string{"v1.0.0"}))
+	size, err = db.RepoSize(testRepo)
+	fatalIfError(t, err)
+	if size != large {
+		t.Fatalf("RepoSize() got %d after setting another repo, want %d", size, large)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	db := createBadgerDatabase(t)
+
+	var empty bytes.Buffer
+	fatalIfError(t, db.Export(&empty))
+	if got := strings.TrimSpace(empty.String()); got != `{"version":1,"repos":[]}` {
+		t.Fatalf("Export() of an empty database got %s", got)
+	}
+
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.2", "v0.0.1"}))
+	fatalIfError(t, db.SetTagSizes(testRepo, map[string]int64{"v0.0.1": 100}))
+	fatalIfError(t, db.SetTags("other/repo", []string{"latest"}))
+
+	var dump bytes.Buffer
+	fatalIfError(t, db.Export(&dump))
+
+	restored := createBadgerDatabase(t)
+	// The records of the repos not in the dump are kept.
+	fatalIfError(t, restored.SetTags("kept/repo", []string{"v1.0.0"}))
+	// The records of the repos in the dump are replaced.
+	fatalIfError(t, restored.SetTags(testRepo, []string{"v0.0.3"}))
+	fatalIfError(t, restored.SetTagSizes(testRepo, map[string]int64{"v0.0.3": 300}))
+	fatalIfError(t, restored.Import(bytes.NewReader(dump.Bytes())))
+
+	for _, repo := range []string{testRepo, "other/repo"} {
+		wantTags, err := db.Tags(repo)
+		fatalIfError(t, err)
+		tags, err := restored.Tags(repo)
+		fatalIfError(t, err)
+		if !reflect.DeepEqual(wantTags, tags) {
+			t.Fatalf("Tags(%q) after Import() got %#v, want %#v", repo, tags, wantTags)
+		}
+
+		wantFirstSeen, err := db.TagsFirstSeen(repo)
+		fatalIfError(t, err)
+		firstSeen, err := restored.TagsFirstSeen(repo)
+		fatalIfError(t, err)
+		if len(firstSeen) != len(wantFirstSeen) {
+			t.Fatalf("TagsFirstSeen(%q) after Import() got %#v, want %#v", repo, firstSeen, wantFirstSeen)
+		}
+		for tag, want := range wantFirstSeen {
+			if !firstSeen[tag].Equal(want) {
+				t.Fatalf("TagsFirstSeen(%q) after Import() got %s for %q, want %s", repo, firstSeen[tag], tag, want)
+			}
+		}
+
+		wantSizes, err := db.TagSizes(repo)
+		fatalIfError(t, err)
+		sizes, err := restored.TagSizes(repo)
+		fatalIfError(t, err)
+		if !reflect.DeepEqual(wantSizes, sizes) {
+			t.Fatalf("TagSizes(%q) after Import() got %#v, want %#v", repo, sizes, wantSizes)
+		}
+	}
+
+	tags, err := restored.Tags("kept/repo")
+	fatalIfError(t, err)
+	if !reflect.DeepEqual([]string{"v1.0.0"}, tags) {
+		t.Fatalf("Tags() of a repo not in the dump got %#v", tags)
+	}
+
+	// An invalid dump is rejected before any record is written.
+	err = restored.Import(strings.NewReader(`{"version":1,"repos":[{"name":"new/repo","tags":["a"]},{"tags":["b"]}]}`))
+	if !errors.Is(err, ErrInvalidDump) {
+		t.Fatalf("Import() of an invalid dump got error %v", err)
+	}
+	tags, err = restored.Tags("new/repo")
+	fatalIfError(t, err)
+	if len(tags) != 0 {
+		t.Fatalf("Tags() after an invalid Import() got %#v, want empty", tags)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	db := createBadgerDatabase(t)
+	tags := []string{"latest", "v0.0.1", "v0.0.2"}
+	fatalIfError(t, db.SetTags(testRepo, tags))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			loaded, err := db.Tags(testRepo)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !reflect.DeepEqual(tags, loaded) {
+				errs <- fmt.Errorf("Tags() got %#v, want %#v", loaded, tags)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := db.SetTags(testRepo, tags); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func createBadgerDatabase(t *testing.T) *BadgerDatabase {
 	t.Helper()
 	dir, err := os.MkdirTemp(os.TempDir(), "badger")
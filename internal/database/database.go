@@ -0,0 +1,288 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Writer implementations record the tags for an image repository.
+type Writer interface {
+	SetTags(repo string, tags []string) error
+	// SetTagSizes records the sizes of the images of the given tags of the
+	// repo, keeping the recorded sizes of the other tags. The sizes of the
+	// tags removed from the repo by SetTags are dropped.
+	SetTagSizes(repo string, sizes map[string]int64) error
+}
+
+// Reader implementations get the stored set of tags for an image repository.
+//
+// If no tags are availble for the repo, then implementations should return an
+// empty set of tags.
+//
+// Implementations must be safe for concurrent use, as the ImagePolicy
+// reconciler may read the tags of the same repository from multiple workers.
+// The returned slice must not be shared between callers.
+type Reader interface {
+	Tags(repo string) ([]string, error)
+	// TagsWithOptions returns the tags of the repo ordered and limited
+	// according to the query. A zero query returns the same tags as Tags.
+	// The stored tags are still read in full, so the query spares the
+	// callers the ordering and truncating of the tags, not the reading.
+	TagsWithOptions(repo string, query TagQuery) ([]string, error)
+	// TagsFirstSeen returns the time at which each of the tags of the repo
+	// was first recorded. Tags recorded before the times were tracked may be
+	// missing from the result.
+	TagsFirstSeen(repo string) (map[string]time.Time, error)
+	// TagSizes returns the sizes of the images of the tags of the repo, as
+	// recorded by SetTagSizes. Tags whose size wasn't recorded are missing
+	// from the result.
+	TagSizes(repo string) (map[string]int64, error)
+}
+
+// TagOrderBy is the key by which the tags returned by a TagQuery are ordered.
+type TagOrderBy string
+
+const (
+	// TagOrderByNone keeps the tags in the order they were recorded in.
+	TagOrderByNone TagOrderBy = ""
+	// TagOrderByName orders the tags by name.
+	TagOrderByName TagOrderBy = "name"
+	// TagOrderByCreated orders the tags by the time they were first
+	// recorded. The tags without a first seen time are ordered first, by
+	// name.
+	TagOrderByCreated TagOrderBy = "created"
+)
+
+// TagQuery specifies the order and the number of the tags returned by
+// Reader.TagsWithOptions.
+type TagQuery struct {
+	// OrderBy is the key the tags are ordered by, in ascending order.
+	OrderBy TagOrderBy
+	// Descending reverses the order of the tags.
+	Descending bool
+	// Limit is the maximum number of tags returned, after ordering them.
+	// Zero means no limit.
+	Limit int
+}
+
+// Apply returns the tags ordered and limited according to the query, given
+// the time at which each tag was first recorded. The given slice is sorted in
+// place.
+func (q TagQuery) Apply(tags []string, firstSeen map[string]time.Time) ([]string, error) {
+	switch q.OrderBy {
+	case TagOrderByNone:
+		if q.Descending {
+			slices.Reverse(tags)
+		}
+	case TagOrderByName:
+		sort.Strings(tags)
+		if q.Descending {
+			slices.Reverse(tags)
+		}
+	case TagOrderByCreated:
+		sort.SliceStable(tags, func(i, j int) bool {
+			ti, tj := firstSeen[tags[i]], firstSeen[tags[j]]
+			if ti.Equal(tj) {
+				return tags[i] < tags[j]
+			}
+			return ti.Before(tj)
+		})
+		if q.Descending {
+			slices.Reverse(tags)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tag order %q", q.OrderBy)
+	}
+
+	if q.Limit > 0 && len(tags) > q.Limit {
+		tags = tags[:q.Limit]
+	}
+	return tags, nil
+}
+
+// DiffTags returns the tags added in the listed tags and removed from them
+// when compared with the stored tags, from which a scan reports its tag churn
+// and its new tags. The added tags are in the order they were listed in, and
+// the removed tags in the order they were stored in. Duplicate tags are
+// reported once.
+func DiffTags(stored, listed []string) (added, removed []string) {
+	storedSet := make(map[string]struct{}, len(stored))
+	for _, t := range stored {
+		storedSet[t] = struct{}{}
+	}
+	listedSet := make(map[string]struct{}, len(listed))
+	for _, t := range listed {
+		if _, ok := listedSet[t]; ok {
+			continue
+		}
+		listedSet[t] = struct{}{}
+		if _, ok := storedSet[t]; !ok {
+			added = append(added, t)
+		}
+	}
+	for _, t := range stored {
+		if _, ok := listedSet[t]; ok {
+			continue
+		}
+		// Mark the tag as listed so that it's only reported once.
+		listedSet[t] = struct{}{}
+		removed = append(removed, t)
+	}
+	return added, removed
+}
+
+// Sizer implementations report the storage used by the tags database, for
+// capacity planning.
+type Sizer interface {
+	// RepoSize returns the number of bytes taken by the records of the
+	// repo, i.e. its tags, their first seen times and their sizes. It's
+	// zero if the repo does not exist.
+	RepoSize(repo string) (int64, error)
+	// Size returns the total number of bytes taken by the database.
+	Size() int64
+}
+
+// Dumper implementations export the records of the tags database and import
+// them back, to inspect what the controller knows and to restore it after the
+// database is wiped.
+type Dumper interface {
+	// Export writes the records of all the repos to w, as a JSON Dump.
+	Export(w io.Writer) error
+	// Import reads a JSON Dump written by Export from r, replacing the
+	// records of the repos it contains. The records of the other repos are
+	// kept.
+	Import(r io.Reader) error
+}
+
+// DumpVersion is the version of the format of the Dump written by Export.
+const DumpVersion = 1
+
+// ErrInvalidDump is returned by Dumper.Import when the dump can't be decoded
+// or doesn't validate, before any record is written.
+var ErrInvalidDump = errors.New("invalid dump")
+
+// Dump is the document written by Dumper.Export and read by Dumper.Import.
+type Dump struct {
+	// Version is the version of the format of the dump, DumpVersion.
+	Version int `json:"version"`
+	// Repos are the records of the repos, ordered by name.
+	Repos []RepoDump `json:"repos"`
+}
+
+// RepoDump holds the records of a repo in a Dump.
+type RepoDump struct {
+	// Name is the canonical name of the repo.
+	Name string `json:"name"`
+	// Tags are the tags of the repo, in the order they were recorded in.
+	Tags []string `json:"tags"`
+	// FirstSeen is the time at which each tag was first recorded.
+	FirstSeen map[string]time.Time `json:"firstSeen,omitempty"`
+	// Sizes are the recorded sizes of the images of the tags.
+	Sizes map[string]int64 `json:"sizes,omitempty"`
+}
+
+// Validate returns an error if the dump has an unknown version, or a repo
+// without a name or recorded more than once.
+func (d Dump) Validate() error {
+	if d.Version != DumpVersion {
+		return fmt.Errorf("unsupported dump version %d, must be %d", d.Version, DumpVersion)
+	}
+	names := make(map[string]struct{}, len(d.Repos))
+	for i, repo := range d.Repos {
+		if repo.Name == "" {
+			return fmt.Errorf("repo %d has no name", i)
+		}
+		if _, ok := names[repo.Name]; ok {
+			return fmt.Errorf("repo %q is recorded more than once", repo.Name)
+		}
+		names[repo.Name] = struct{}{}
+	}
+	return nil
+}
+
+// Database is a tags database opened by a Factory. It's closed when the
+// controller stops.
+type Database interface {
+	Reader
+	Writer
+	Sizer
+	Dumper
+	io.Closer
+}
+
+// Options are the options given to a Factory to open a Database. Each
+// implementation uses the options that apply to it.
+type Options struct {
+	// StoragePath is the path of the directory to store the database in,
+	// for the implementations storing it locally.
+	StoragePath string
+	// ValueLogFileSize is the size in bytes of the memory mapped value log
+	// files of the Badger database.
+	ValueLogFileSize int64
+}
+
+// Factory opens a Database with the given options.
+type Factory func(opts Options) (Database, error)
+
+var (
+	factoriesMu sync.Mutex
+	factories   = map[string]Factory{}
+)
+
+// Register makes a Database implementation available by the given name. It's
+// meant to be called from the init function of the implementation, and panics
+// if the name is already registered.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("database %q is already registered", name))
+	}
+	factories[name] = factory
+}
+
+// Open opens the Database implementation registered by the given name.
+func Open(name string, opts Options) (Database, error) {
+	factoriesMu.Lock()
+	factory, ok := factories[name]
+	factoriesMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q, must be one of %v", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names returns the sorted names of the registered Database implementations.
+func Names() []string {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenBadger(t *testing.T) {
+	db, err := Open(BadgerName, Options{StoragePath: t.TempDir()})
+	fatalIfError(t, err)
+	defer db.Close()
+
+	tags := []string{"latest", "v0.0.1"}
+	fatalIfError(t, db.SetTags(testRepo, tags))
+
+	loaded, err := db.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(tags, loaded) {
+		t.Fatalf("Tags() got %#v, want %#v", loaded, tags)
+	}
+}
+
+func TestOpenUnknown(t *testing.T) {
+	if _, err := Open("unknown", Options{}); err == nil {
+		t.Fatal("Open() of an unknown database succeeded, want error")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test", func(Options) (Database, error) { return nil, nil })
+	defer func() {
+		factoriesMu.Lock()
+		delete(factories, "test")
+		factoriesMu.Unlock()
+	}()
+
+	if names := Names(); !reflect.DeepEqual(names, []string{BadgerName, "test"}) {
+		t.Fatalf("Names() got %#v, want %#v", names, []string{BadgerName, "test"})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register() of a registered name didn't panic")
+		}
+	}()
+	Register(BadgerName, openBadger)
+}
+
+func TestTagQueryApply(t *testing.T) {
+	now := time.Now()
+	firstSeen := map[string]time.Time{
+		"b": now.Add(-2 * time.Hour),
+		"c": now.Add(-time.Hour),
+		"a": now,
+	}
+
+	tests := []struct {
+		name    string
+		query   TagQuery
+		want    []string
+		wantErr bool
+	}{
+		{name: "zero query", query: TagQuery{}, want: []string{"c", "a", "d", "b"}},
+		{name: "descending", query: TagQuery{Descending: true}, want: []string{"b", "d", "a", "c"}},
+		{name: "limit", query: TagQuery{Limit: 2}, want: []string{"c", "a"}},
+		{name: "limit above count", query: TagQuery{Limit: 10}, want: []string{"c", "a", "d", "b"}},
+		{name: "by name", query: TagQuery{OrderBy: TagOrderByName}, want: []string{"a", "b", "c", "d"}},
+		{name: "by name descending with limit", query: TagQuery{OrderBy: TagOrderByName, Descending: true, Limit: 3}, want: []string{"d", "c", "b"}},
+		// d has no first seen time and is ordered first.
+		{name: "by created", query: TagQuery{OrderBy: TagOrderByCreated}, want: []string{"d", "b", "c", "a"}},
+		{name: "by created descending with limit", query: TagQuery{OrderBy: TagOrderByCreated, Descending: true, Limit: 1}, want: []string{"a"}},
+		{name: "unknown order", query: TagQuery{OrderBy: "size"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Apply([]string{"c", "a", "d", "b"}, firstSeen)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Apply() got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      []string
+		listed      []string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{name: "identical", stored: []string{"a", "b"}, listed: []string{"b", "a"}},
+		{name: "overlapping", stored: []string{"a", "b", "c"}, listed: []string{"d", "b", "c", "e"}, wantAdded: []string{"d", "e"}, wantRemoved: []string{"a"}},
+		{name: "disjoint", stored: []string{"a", "b"}, listed: []string{"c", "d"}, wantAdded: []string{"c", "d"}, wantRemoved: []string{"a", "b"}},
+		{name: "nothing stored", listed: []string{"a", "b"}, wantAdded: []string{"a", "b"}},
+		{name: "nothing listed", stored: []string{"a", "b"}, wantRemoved: []string{"a", "b"}},
+		{name: "duplicates", stored: []string{"a", "a", "b"}, listed: []string{"c", "c", "b"}, wantAdded: []string{"c"}, wantRemoved: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffTags(tt.stored, tt.listed)
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Fatalf("DiffTags() added %#v, want %#v", added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Fatalf("DiffTags() removed %#v, want %#v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestTagsWithOptions(t *testing.T) {
+	db := createBadgerDatabase(t)
+
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.2", "v0.0.1"}))
+	fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.2", "v0.0.1", "v0.0.3"}))
+
+	got, err := db.TagsWithOptions(testRepo, TagQuery{OrderBy: TagOrderByCreated, Descending: true, Limit: 1})
+	fatalIfError(t, err)
+	if want := []string{"v0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("TagsWithOptions() got %#v, want %#v", got, want)
+	}
+
+	got, err = db.TagsWithOptions(testRepo, TagQuery{OrderBy: TagOrderByName})
+	fatalIfError(t, err)
+	if want := []string{"v0.0.1", "v0.0.2", "v0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("TagsWithOptions() got %#v, want %#v", got, want)
+	}
+
+	// Tags returns the tags in the order they were recorded in.
+	got, err = db.Tags(testRepo)
+	fatalIfError(t, err)
+	if want := []string{"v0.0.2", "v0.0.1", "v0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tags() got %#v, want %#v", got, want)
+	}
+}
+
+func TestDumpValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    Dump
+		wantErr string
+	}{
+		{name: "valid", dump: Dump{Version: DumpVersion, Repos: []RepoDump{{Name: "a"}, {Name: "b"}}}},
+		{name: "empty", dump: Dump{Version: DumpVersion}},
+		{name: "unknown version", dump: Dump{Version: 2}, wantErr: "unsupported dump version 2"},
+		{name: "repo without a name", dump: Dump{Version: DumpVersion, Repos: []RepoDump{{Name: "a"}, {}}}, wantErr: "repo 1 has no name"},
+		{name: "duplicate repos", dump: Dump{Version: DumpVersion, Repos: []RepoDump{{Name: "a"}, {Name: "a"}}}, wantErr: `repo "a" is recorded more than once`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dump.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() returned unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gcp mints access tokens of the Google service accounts bound to
+// Kubernetes service accounts with GKE Workload Identity, used to access
+// Artifact Registry and Container Registry.
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+const (
+	// ServiceAccountAnnotation is the annotation of a Kubernetes service
+	// account holding the email of the Google service account bound to it.
+	ServiceAccountAnnotation = "iam.gke.io/gcp-service-account"
+
+	// DefaultSTSURL is the URL of the Security Token Service endpoint
+	// exchanging Kubernetes service account tokens for federated tokens.
+	DefaultSTSURL = "https://sts.googleapis.com/v1/token"
+	// DefaultIAMCredentialsURL is the base URL of the IAM Service Account
+	// Credentials API.
+	DefaultIAMCredentialsURL = "https://iamcredentials.googleapis.com/v1"
+	// RegistryUsername is the username used along with an access token to
+	// authenticate with the Google registries.
+	RegistryUsername = "oauth2accesstoken"
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// Cluster is the GKE cluster whose workload identity pool the Kubernetes
+// service accounts belong to.
+type Cluster struct {
+	ProjectID string
+	Location  string
+	Name      string
+}
+
+// ClusterFromMetadata reads the cluster the controller runs in from the GKE
+// metadata server.
+func ClusterFromMetadata() (*Cluster, error) {
+	projectID, err := metadata.ProjectID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the project ID from the metadata server: %w", err)
+	}
+	location, err := metadata.InstanceAttributeValue("cluster-location")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cluster location from the metadata server: %w", err)
+	}
+	name, err := metadata.InstanceAttributeValue("cluster-name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cluster name from the metadata server: %w", err)
+	}
+	return &Cluster{ProjectID: projectID, Location: location, Name: name}, nil
+}
+
+// TokenAudience returns the audience of the Kubernetes service account
+// tokens exchanged for federated tokens, the workload identity pool of the
+// cluster.
+func (c Cluster) TokenAudience() string {
+	return c.ProjectID + ".svc.id.goog"
+}
+
+// identityProvider returns the workload identity provider of the cluster.
+func (c Cluster) identityProvider() string {
+	return fmt.Sprintf("identitynamespace:%s:https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s",
+		c.TokenAudience(), c.ProjectID, c.Location, c.Name)
+}
+
+// WorkloadIdentity is a Google service account bound to Kubernetes service
+// accounts of a cluster.
+type WorkloadIdentity struct {
+	Cluster        Cluster
+	ServiceAccount string
+	// STSURL and IAMCredentialsURL default to DefaultSTSURL and
+	// DefaultIAMCredentialsURL when empty.
+	STSURL            string
+	IAMCredentialsURL string
+	// HTTPClient is used to call the Google APIs. The default client is used
+	// when nil.
+	HTTPClient *http.Client
+}
+
+// AccessToken exchanges the given Kubernetes service account token, with
+// the audience of the cluster, for an access token of the Google service
+// account. It returns the token along with the time it expires at.
+func (w *WorkloadIdentity) AccessToken(ctx context.Context, subjectToken string) (string, time.Time, error) {
+	federatedToken, err := w.federatedToken(ctx, subjectToken)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	baseURL := w.IAMCredentialsURL
+	if baseURL == "" {
+		baseURL = DefaultIAMCredentialsURL
+	}
+	u := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:generateAccessToken", baseURL, url.PathEscape(w.ServiceAccount))
+	body, err := json.Marshal(map[string][]string{"scope": {cloudPlatformScope}})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+federatedToken)
+
+	var result struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := w.do(req, &result); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to request an access token for the Google service account '%s': %w", w.ServiceAccount, err)
+	}
+	return result.AccessToken, result.ExpireTime, nil
+}
+
+// federatedToken exchanges the Kubernetes service account token for a
+// federated token of the workload identity pool of the cluster.
+func (w *WorkloadIdentity) federatedToken(ctx context.Context, subjectToken string) (string, error) {
+	u := w.STSURL
+	if u == "" {
+		u = DefaultSTSURL
+	}
+	body, err := json.Marshal(map[string]string{
+		"grantType":          "urn:ietf:params:oauth:grant-type:token-exchange",
+		"audience":           w.Cluster.identityProvider(),
+		"scope":              cloudPlatformScope,
+		"requestedTokenType": "urn:ietf:params:oauth:token-type:access_token",
+		"subjectToken":       subjectToken,
+		"subjectTokenType":   "urn:ietf:params:oauth:token-type:jwt",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := w.do(req, &result); err != nil {
+		return "", fmt.Errorf("failed to exchange the Kubernetes service account token: %w", err)
+	}
+	return result.AccessToken, nil
+}
+
+// do sends the request and decodes the JSON response into v.
+func (w *WorkloadIdentity) do(req *http.Request, v any) error {
+	client := w.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
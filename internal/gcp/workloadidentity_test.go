@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCluster_TokenAudience(t *testing.T) {
+	g := NewWithT(t)
+
+	c := Cluster{ProjectID: "my-project", Location: "europe-west1", Name: "my-cluster"}
+	g.Expect(c.TokenAudience()).To(Equal("my-project.svc.id.goog"))
+	g.Expect(c.identityProvider()).To(Equal("identitynamespace:my-project.svc.id.goog:https://container.googleapis.com/v1/projects/my-project/locations/europe-west1/clusters/my-cluster"))
+}
+
+func TestWorkloadIdentity_AccessToken(t *testing.T) {
+	expireTime := time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)
+	cluster := Cluster{ProjectID: "my-project", Location: "europe-west1", Name: "my-cluster"}
+
+	tests := []struct {
+		name       string
+		stsStatus  int
+		iamStatus  int
+		wantErr    string
+		wantToken  string
+		wantExpiry time.Time
+	}{
+		{
+			name:       "access token",
+			stsStatus:  http.StatusOK,
+			iamStatus:  http.StatusOK,
+			wantToken:  "access-token",
+			wantExpiry: expireTime,
+		},
+		{
+			name:      "token exchange denied",
+			stsStatus: http.StatusForbidden,
+			wantErr:   "failed to exchange the Kubernetes service account token: unexpected status 403 Forbidden",
+		},
+		{
+			name:      "impersonation denied",
+			stsStatus: http.StatusOK,
+			iamStatus: http.StatusForbidden,
+			wantErr:   "failed to request an access token for the Google service account 'reader@my-project.iam.gserviceaccount.com': unexpected status 403 Forbidden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/sts", func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]string
+				g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+				g.Expect(req["subjectToken"]).To(Equal("k8s-token"))
+				g.Expect(req["audience"]).To(Equal(cluster.identityProvider()))
+				if tt.stsStatus != http.StatusOK {
+					w.WriteHeader(tt.stsStatus)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]string{"access_token": "federated-token"})
+			})
+			mux.HandleFunc("/iam/projects/-/serviceAccounts/reader@my-project.iam.gserviceaccount.com:generateAccessToken", func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.Header.Get("Authorization")).To(Equal("Bearer federated-token"))
+				if tt.iamStatus != http.StatusOK {
+					w.WriteHeader(tt.iamStatus)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"accessToken": "access-token", "expireTime": expireTime})
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			w := &WorkloadIdentity{
+				Cluster:           cluster,
+				ServiceAccount:    "reader@my-project.iam.gserviceaccount.com",
+				STSURL:            srv.URL + "/sts",
+				IAMCredentialsURL: srv.URL + "/iam",
+			}
+			token, expiresAt, err := w.AccessToken(context.TODO(), "k8s-token")
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(token).To(Equal(tt.wantToken))
+			g.Expect(expiresAt).To(BeTemporally("==", tt.wantExpiry))
+		})
+	}
+}
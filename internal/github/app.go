@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package github mints GitHub App installation tokens, used to access the
+// GitHub Container Registry.
+package github
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AppIDKey is the key of the Secret holding the ID of the GitHub App.
+	AppIDKey = "githubAppID"
+	// AppInstallationIDKey is the key of the Secret holding the ID of the
+	// installation of the GitHub App.
+	AppInstallationIDKey = "githubAppInstallationID"
+	// AppPrivateKeyKey is the key of the Secret holding the PEM-encoded
+	// private key of the GitHub App.
+	AppPrivateKeyKey = "githubAppPrivateKey"
+	// AppBaseURLKey is the optional key of the Secret holding the base URL
+	// of the GitHub API, for GitHub Enterprise Server.
+	AppBaseURLKey = "githubAppBaseURL"
+
+	// DefaultBaseURL is the base URL of the GitHub API.
+	DefaultBaseURL = "https://api.github.com"
+	// RegistryHost is the host of the GitHub Container Registry.
+	RegistryHost = "ghcr.io"
+	// RegistryUsername is the username used along with an installation
+	// token to authenticate with the GitHub Container Registry.
+	RegistryUsername = "x-access-token"
+)
+
+// App is a GitHub App installation.
+type App struct {
+	ID             int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+	BaseURL        string
+	// HTTPClient is used to call the GitHub API. The default client is used
+	// when nil.
+	HTTPClient *http.Client
+}
+
+// AppFromSecret reads the GitHub App installation from the given Secret.
+func AppFromSecret(secret corev1.Secret) (*App, error) {
+	id, err := intFromSecret(secret, AppIDKey)
+	if err != nil {
+		return nil, err
+	}
+	installationID, err := intFromSecret(secret, AppInstallationIDKey)
+	if err != nil {
+		return nil, err
+	}
+	pem, ok := secret.Data[AppPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("secret '%s' is missing the '%s' key", secret.Name, AppPrivateKeyKey)
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' in secret '%s': %w", AppPrivateKeyKey, secret.Name, err)
+	}
+
+	baseURL := DefaultBaseURL
+	if v, ok := secret.Data[AppBaseURLKey]; ok {
+		baseURL = strings.TrimSuffix(string(v), "/")
+	}
+	return &App{
+		ID:             id,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+		BaseURL:        baseURL,
+	}, nil
+}
+
+func intFromSecret(secret corev1.Secret, key string) (int64, error) {
+	v, ok := secret.Data[key]
+	if !ok {
+		return 0, fmt.Errorf("secret '%s' is missing the '%s' key", secret.Name, key)
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid '%s' in secret '%s': %w", key, secret.Name, err)
+	}
+	return i, nil
+}
+
+// InstallationToken mints an installation access token of the App. It
+// returns the token along with the time it expires at.
+func (a *App) InstallationToken(ctx context.Context) (string, time.Time, error) {
+	// The JWT authenticating as the App is backdated to allow for clock
+	// drift, and can't be valid for more than 10 minutes.
+	now := time.Now()
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(a.ID, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}).SignedString(a.PrivateKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign the GitHub App token: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.BaseURL, a.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+appToken)
+
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to request a GitHub App installation token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("failed to request a GitHub App installation token: unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read the GitHub App installation token: %w", err)
+	}
+	return result.Token, result.ExpiresAt, nil
+}
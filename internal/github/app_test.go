@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newAppSecret(t *testing.T, key *rsa.PrivateKey, baseURL string) corev1.Secret {
+	t.Helper()
+	s := corev1.Secret{}
+	s.Name = "github-app"
+	s.Data = map[string][]byte{
+		AppIDKey:             []byte("123"),
+		AppInstallationIDKey: []byte("456"),
+		AppPrivateKeyKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}),
+	}
+	if baseURL != "" {
+		s.Data[AppBaseURLKey] = []byte(baseURL)
+	}
+	return s
+}
+
+func TestAppFromSecret(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(s *corev1.Secret)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "missing app ID",
+			modify:  func(s *corev1.Secret) { delete(s.Data, AppIDKey) },
+			wantErr: "missing the 'githubAppID' key",
+		},
+		{
+			name:    "invalid installation ID",
+			modify:  func(s *corev1.Secret) { s.Data[AppInstallationIDKey] = []byte("abc") },
+			wantErr: "invalid 'githubAppInstallationID'",
+		},
+		{
+			name:    "invalid private key",
+			modify:  func(s *corev1.Secret) { s.Data[AppPrivateKeyKey] = []byte("not a key") },
+			wantErr: "invalid 'githubAppPrivateKey'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			s := newAppSecret(t, key, "")
+			if tt.modify != nil {
+				tt.modify(&s)
+			}
+			app, err := AppFromSecret(s)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(app.ID).To(Equal(int64(123)))
+			g.Expect(app.InstallationID).To(Equal(int64(456)))
+			g.Expect(app.BaseURL).To(Equal(DefaultBaseURL))
+		})
+	}
+}
+
+func TestApp_InstallationToken(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/456/access_tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), claims,
+			func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+		if err != nil || claims.Issuer != "123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_token","expires_at":%q}`, expiresAt.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	app, err := AppFromSecret(newAppSecret(t, key, srv.URL+"/api/v3/"))
+	g.Expect(err).ToNot(HaveOccurred())
+	token, gotExpiresAt, err := app.InstallationToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_token"))
+	g.Expect(gotExpiresAt).To(BeTemporally("==", expiresAt))
+
+	// A token signed with another key is rejected.
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	app.PrivateKey = otherKey
+	_, _, err = app.InstallationToken(context.TODO())
+	g.Expect(err).To(MatchError(ContainSubstring("401 Unauthorized")))
+}
@@ -19,6 +19,7 @@ package policy
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 const (
@@ -31,6 +32,13 @@ const (
 // Alphabetical representes a alphabetical ordering policy
 type Alphabetical struct {
 	Order string
+	// Natural compares the runs of digits in the tags by their numerical
+	// value, so that v2 is ordered before v10.
+	Natural bool
+	// CreatedAsTiebreak makes Evaluate keep the tag first seen the most
+	// recently among the ones extracted to the same value by the tag filter,
+	// as distinct versions are never ordered equally.
+	CreatedAsTiebreak bool
 }
 
 // NewAlphabetical constructs a Alphabetical object validating the provided
@@ -53,14 +61,85 @@ func NewAlphabetical(order string) (*Alphabetical, error) {
 // Latest returns latest version from a provided list of strings
 func (p *Alphabetical) Latest(versions []string) (string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return "", ErrNoTags
 	}
+	return p.sorted(versions)[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest
+func (p *Alphabetical) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	sorted := p.sorted(versions)
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n], nil
+}
+
+// sorted returns a copy of the versions ordered from the latest. A copy is
+// sorted to avoid mutating the caller's slice, which may be shared between
+// concurrent policy evaluations.
+func (p *Alphabetical) sorted(versions []string) []string {
+	less := func(a, b string) bool { return a < b }
+	if p.Natural {
+		less = naturalLess
+	}
+
+	sorted := make([]string, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if p.Order == AlphabeticalOrderDesc {
+			return less(sorted[i], sorted[j])
+		}
+		return less(sorted[j], sorted[i])
+	})
+	return sorted
+}
+
+// naturalLess reports whether a is ordered before b, comparing the runs of
+// digits by their numerical value and the rest of the strings lexically.
+// Strings that only differ by the leading zeros of their numbers are ordered
+// lexically.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
 
-	var sorted sort.StringSlice = versions
-	if p.Order == AlphabeticalOrderDesc {
-		sort.Sort(sorted)
-	} else {
-		sort.Sort(sort.Reverse(sorted))
+		// Compare the runs of digits by their value, i.e. by their length
+		// without the leading zeros, then digit by digit.
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		na := strings.TrimLeft(a[si:i], "0")
+		nb := strings.TrimLeft(b[sj:j], "0")
+		if len(na) != len(nb) {
+			return len(na) < len(nb)
+		}
+		if na != nb {
+			return na < nb
+		}
 	}
-	return sorted[0], nil
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
 }
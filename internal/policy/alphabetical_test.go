@@ -17,6 +17,7 @@ limitations under the License.
 package policy
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -142,3 +143,163 @@ func TestAlphabetical_Latest(t *testing.T) {
 		})
 	}
 }
+
+func TestAlphabetical_LatestDoesNotMutateInput(t *testing.T) {
+	versions := []string{"b", "c", "a"}
+	policy, err := NewAlphabetical(AlphabeticalOrderAsc)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if _, err := policy.Latest(versions); err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(versions, []string{"b", "c", "a"}) {
+		t.Errorf("input versions were mutated: %v", versions)
+	}
+}
+
+func TestAlphabetical_LatestN(t *testing.T) {
+	cases := []struct {
+		label            string
+		order            string
+		versions         []string
+		n                int
+		expectedVersions []string
+		expectErr        bool
+	}{
+		{
+			label:            "With ascending order",
+			versions:         []string{"b", "d", "a", "c"},
+			n:                2,
+			expectedVersions: []string{"d", "c"},
+		},
+		{
+			label:            "With descending order",
+			versions:         []string{"b", "d", "a", "c"},
+			order:            AlphabeticalOrderDesc,
+			n:                3,
+			expectedVersions: []string{"a", "b", "c"},
+		},
+		{
+			label:            "With n larger than the list",
+			versions:         []string{"b", "a"},
+			n:                5,
+			expectedVersions: []string{"b", "a"},
+		},
+		{
+			label:     "With zero n",
+			versions:  []string{"a"},
+			n:         0,
+			expectErr: true,
+		},
+		{
+			label:     "With negative n",
+			versions:  []string{"a"},
+			n:         -1,
+			expectErr: true,
+		},
+		{
+			label:     "Empty version list",
+			versions:  []string{},
+			n:         1,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewAlphabetical(tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.LatestN(tt.versions, tt.n)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
+
+func TestAlphabetical_Natural(t *testing.T) {
+	versions := []string{"v10", "v1", "v100", "v2"}
+
+	cases := []struct {
+		label            string
+		order            string
+		natural          bool
+		expectedVersions []string
+	}{
+		{
+			label:            "With lexical ascending order",
+			expectedVersions: []string{"v2", "v100", "v10", "v1"},
+		},
+		{
+			label:            "With natural ascending order",
+			natural:          true,
+			expectedVersions: []string{"v100", "v10", "v2", "v1"},
+		},
+		{
+			label:            "With natural descending order",
+			order:            AlphabeticalOrderDesc,
+			natural:          true,
+			expectedVersions: []string{"v1", "v2", "v10", "v100"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewAlphabetical(tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.Natural = tt.natural
+
+			latest, err := policy.LatestN(versions, len(versions))
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, tt.expectedVersions)
+			}
+			first, err := policy.Latest(versions)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if first != tt.expectedVersions[0] {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", first, tt.expectedVersions[0])
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "v2", b: "v10", want: true},
+		{a: "v10", b: "v2", want: false},
+		{a: "v1.9.0", b: "v1.10.0", want: true},
+		{a: "build-9-amd64", b: "build-10-amd64", want: true},
+		{a: "v1", b: "v1-rc", want: true},
+		{a: "alpha", b: "beta", want: true},
+		{a: "1a", b: "a", want: true},
+		// Numbers only differing by their leading zeros are ordered lexically.
+		{a: "v01", b: "v1", want: true},
+		{a: "v1", b: "v01", want: false},
+		{a: "v1", b: "v1", want: false},
+	}
+
+	for _, tt := range cases {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, expected %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CalVerOrderAsc ascending order, selects the most recent version
+	CalVerOrderAsc = "ASC"
+	// CalVerOrderDesc descending order, selects the oldest version
+	CalVerOrderDesc = "DESC"
+)
+
+// calVerSegments are the patterns of the segments of a calendar versioning
+// layout, as named by https://calver.org. The longer names are listed first,
+// as the layout is matched against them in order.
+var calVerSegments = []struct {
+	name    string
+	pattern string
+}{
+	{name: "YYYY", pattern: `[0-9]{4}`},
+	{name: "MAJOR", pattern: `0|[1-9][0-9]*`},
+	{name: "MINOR", pattern: `0|[1-9][0-9]*`},
+	{name: "MICRO", pattern: `0|[1-9][0-9]*`},
+	{name: "YY", pattern: `0|[1-9][0-9]{0,2}`},
+	{name: "0Y", pattern: `[0-9]{2,3}`},
+	{name: "MM", pattern: `[1-9]|1[0-2]`},
+	{name: "0M", pattern: `0[1-9]|1[0-2]`},
+	{name: "WW", pattern: `[1-9]|[1-4][0-9]|5[0-3]`},
+	{name: "0W", pattern: `0[1-9]|[1-4][0-9]|5[0-3]`},
+	{name: "DD", pattern: `[1-9]|[12][0-9]|3[01]`},
+	{name: "0D", pattern: `0[1-9]|[12][0-9]|3[01]`},
+}
+
+// CalVer represents an ordering policy based on calendar versions, whose
+// segments are parsed from the tags with a layout, e.g. `YYYY.0M.0D`, and
+// compared numerically one by one.
+type CalVer struct {
+	Layout string
+	Order  string
+
+	pattern  *regexp.Regexp
+	segments int
+}
+
+// NewCalVer constructs a CalVer object validating the provided layout and
+// order argument
+func NewCalVer(layout string, order string) (*CalVer, error) {
+	if layout == "" {
+		return nil, fmt.Errorf("layout argument cannot be empty")
+	}
+
+	switch order {
+	case "":
+		order = CalVerOrderAsc
+	case CalVerOrderAsc, CalVerOrderDesc:
+		break
+	default:
+		return nil, fmt.Errorf("invalid order argument provided: '%s', must be one of: %s, %s", order, CalVerOrderAsc, CalVerOrderDesc)
+	}
+
+	pattern, segments := compileCalVerLayout(layout)
+	if segments == 0 {
+		names := make([]string, 0, len(calVerSegments))
+		for _, s := range calVerSegments {
+			names = append(names, s.name)
+		}
+		return nil, fmt.Errorf("invalid layout '%s': it must contain at least one of the segments %s", layout, strings.Join(names, ", "))
+	}
+
+	return &CalVer{
+		Layout:   layout,
+		Order:    order,
+		pattern:  pattern,
+		segments: segments,
+	}, nil
+}
+
+// compileCalVerLayout returns the regular expression matching the versions
+// of the layout, with a group per segment, and the number of segments. The
+// characters of the layout that aren't part of a segment are matched
+// literally.
+func compileCalVerLayout(layout string) (*regexp.Regexp, int) {
+	var b strings.Builder
+	var segments int
+	b.WriteString("^")
+	for rest := layout; rest != ""; {
+		matched := false
+		for _, s := range calVerSegments {
+			if strings.HasPrefix(rest, s.name) {
+				b.WriteString("(" + s.pattern + ")")
+				rest = rest[len(s.name):]
+				segments++
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), segments
+}
+
+// parse returns the numerical values of the segments of the version, or
+// false if it doesn't match the layout.
+func (p *CalVer) parse(version string) ([]uint64, bool) {
+	m := p.pattern.FindStringSubmatch(version)
+	if m == nil {
+		return nil, false
+	}
+	values := make([]uint64, p.segments)
+	for i := range values {
+		v, err := strconv.ParseUint(m[i+1], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// Latest returns latest version from a provided list of strings. The versions
+// that don't match the layout are ignored.
+func (p *CalVer) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest. The versions that don't match the layout are ignored.
+func (p *CalVer) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	type parsedVersion struct {
+		original string
+		values   []uint64
+	}
+	var parsed []parsedVersion
+	for _, version := range versions {
+		values, ok := p.parse(version)
+		if !ok {
+			continue
+		}
+		parsed = append(parsed, parsedVersion{original: version, values: values})
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: none of the %d provided versions match the layout '%s', e.g. '%s'",
+			ErrNoMatchingTag, len(versions), p.Layout, versions[0])
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		c := compareCalVer(parsed[i].values, parsed[j].values)
+		if p.Order == CalVerOrderDesc {
+			return c < 0
+		}
+		return c > 0
+	})
+	if n > len(parsed) {
+		n = len(parsed)
+	}
+	result := make([]string, n)
+	for i := range result {
+		result[i] = parsed[i].original
+	}
+	return result, nil
+}
+
+// compareCalVer compares the values of the segments of two versions of the
+// same layout one by one, returning -1, 0 or 1.
+func compareCalVer(a, b []uint64) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewCalVer(t *testing.T) {
+	cases := []struct {
+		label     string
+		layout    string
+		order     string
+		expectErr string
+	}{
+		{
+			label:  "With valid empty order",
+			layout: "YYYY.MM",
+		},
+		{
+			label:  "With valid asc order",
+			layout: "YYYY.0M.0D",
+			order:  CalVerOrderAsc,
+		},
+		{
+			label:  "With valid desc order",
+			layout: "YY.MINOR.MICRO",
+			order:  CalVerOrderDesc,
+		},
+		{
+			label:     "With invalid order",
+			layout:    "YYYY.MM",
+			order:     "invalid",
+			expectErr: "invalid order argument provided",
+		},
+		{
+			label:     "With empty layout",
+			expectErr: "layout argument cannot be empty",
+		},
+		{
+			label:     "With a layout without segments",
+			layout:    "2006.01",
+			expectErr: "invalid layout '2006.01': it must contain at least one of the segments YYYY, MAJOR",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewCalVer(tt.layout, tt.order)
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Fatalf("expecting error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestCalVer_Latest(t *testing.T) {
+	cases := []struct {
+		label           string
+		layout          string
+		order           string
+		versions        []string
+		expectedVersion string
+		expectErr       error
+	}{
+		{
+			label:           "With months compared numerically",
+			layout:          "YYYY.MM",
+			versions:        shuffle([]string{"2024.9", "2024.10", "2023.12", "2024.1"}),
+			expectedVersion: "2024.10",
+		},
+		{
+			label:           "With months compared numerically descending",
+			layout:          "YYYY.MM",
+			order:           CalVerOrderDesc,
+			versions:        shuffle([]string{"2024.9", "2024.10", "2023.12", "2024.1"}),
+			expectedVersion: "2023.12",
+		},
+		{
+			label:           "With zero-padded segments",
+			layout:          "YYYY.0M.0D",
+			versions:        shuffle([]string{"2024.09.30", "2024.10.01", "2024.10.02", "2024.01.31"}),
+			expectedVersion: "2024.10.02",
+		},
+		{
+			label:           "With short years and micro",
+			layout:          "YY.MM.MICRO",
+			versions:        shuffle([]string{"24.10.9", "24.10.10", "24.9.11", "23.12.0"}),
+			expectedVersion: "24.10.10",
+		},
+		{
+			label:           "With literal characters",
+			layout:          "vYYYY-0W",
+			versions:        shuffle([]string{"v2024-09", "v2024-41", "v2024-40", "2024-52"}),
+			expectedVersion: "v2024-41",
+		},
+		{
+			label:           "With segments without separator",
+			layout:          "YYYY0M0D",
+			versions:        shuffle([]string{"20240930", "20241001", "20231231"}),
+			expectedVersion: "20241001",
+		},
+		{
+			label:           "With versions not matching the layout dropped",
+			layout:          "YYYY.MM",
+			versions:        shuffle([]string{"latest", "2024.9", "2024.09", "2024.13", "2024.10-rc.1", "v2024.11"}),
+			expectedVersion: "2024.9",
+		},
+		{
+			label:     "With no version matching the layout",
+			layout:    "YYYY.MM",
+			versions:  []string{"latest", "2024.09"},
+			expectErr: ErrNoMatchingTag,
+		},
+		{
+			label:     "Empty version list",
+			layout:    "YYYY.MM",
+			versions:  []string{},
+			expectErr: ErrNoTags,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewCalVer(tt.layout, tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.Latest(tt.versions)
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expecting error %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestCalVer_LatestN(t *testing.T) {
+	policy, err := NewCalVer("YYYY.MM", "")
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	latest, err := policy.LatestN([]string{"2024.9", "latest", "2024.10", "2024.1", "2024.11"}, 5)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	expected := []string{"2024.11", "2024.10", "2024.9", "2024.1"}
+	if !reflect.DeepEqual(latest, expected) {
+		t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, expected)
+	}
+
+	_, err = policy.LatestN([]string{"latest", "main"}, 1)
+	if want := "none of the 2 provided versions match the layout 'YYYY.MM', e.g. 'latest'"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expecting error %q, got %v", want, err)
+	}
+	if _, err := policy.LatestN([]string{"2024.9"}, 0); err == nil {
+		t.Fatalf("expecting error, got nil")
+	}
+}
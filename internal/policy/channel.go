@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ChannelStable selects the highest release version
+	ChannelStable = "stable"
+	// ChannelPrerelease selects the highest version, including prereleases
+	ChannelPrerelease = "prerelease"
+)
+
+// Channel represents a preset release channel policy. It selects the
+// latest version with a SemVer policy, among the tags starting with the
+// prefix.
+type Channel struct {
+	Name   string
+	Prefix string
+	Range  string
+
+	semver *SemVer
+}
+
+// NewChannel constructs a Channel object validating the provided channel
+// name and semver range. An empty range matches all versions.
+func NewChannel(name, prefix, r string) (*Channel, error) {
+	var includePrerelease bool
+	switch name {
+	case ChannelStable:
+		break
+	case ChannelPrerelease:
+		includePrerelease = true
+	default:
+		return nil, fmt.Errorf("invalid channel name provided: '%s', must be one of: %s, %s", name, ChannelStable, ChannelPrerelease)
+	}
+
+	if r == "" {
+		r = "*"
+	}
+	semver, err := NewSemVer(r, SemVerOrderDesc, includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Channel{
+		Name:   name,
+		Prefix: prefix,
+		Range:  r,
+		semver: semver,
+	}, nil
+}
+
+// Latest returns latest version from a provided list of strings
+func (p *Channel) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest
+func (p *Channel) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	var trimmed []string
+	for _, tag := range versions {
+		if v, ok := strings.CutPrefix(tag, p.Prefix); ok {
+			trimmed = append(trimmed, v)
+		}
+	}
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("%w: unable to determine latest version from provided list", ErrNoMatchingTag)
+	}
+
+	latest, err := p.semver.LatestN(trimmed, n)
+	if err != nil {
+		return nil, err
+	}
+	for i := range latest {
+		latest[i] = p.Prefix + latest[i]
+	}
+	return latest, nil
+}
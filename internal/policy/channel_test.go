@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewChannel(t *testing.T) {
+	cases := []struct {
+		label     string
+		name      string
+		semver    string
+		expectErr bool
+	}{
+		{
+			label: "With stable channel",
+			name:  ChannelStable,
+		},
+		{
+			label:  "With prerelease channel and range",
+			name:   ChannelPrerelease,
+			semver: ">=1.0.0",
+		},
+		{
+			label:     "With invalid name",
+			name:      "beta",
+			expectErr: true,
+		},
+		{
+			label:     "With invalid range",
+			name:      ChannelStable,
+			semver:    "*-*",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewChannel(tt.name, "", tt.semver)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestChannel_LatestN(t *testing.T) {
+	versions := []string{"1.0.0", "v1.1.0", "1.2.0-rc.1", "app-2.0.0", "app-2.1.0-rc.1", "app-latest", "latest"}
+
+	cases := []struct {
+		label            string
+		name             string
+		prefix           string
+		semver           string
+		n                int
+		expectedVersions []string
+		expectErr        bool
+	}{
+		{
+			label:            "With stable channel",
+			name:             ChannelStable,
+			n:                3,
+			expectedVersions: []string{"v1.1.0", "1.0.0"},
+		},
+		{
+			label:            "With prerelease channel",
+			name:             ChannelPrerelease,
+			n:                1,
+			expectedVersions: []string{"1.2.0-rc.1"},
+		},
+		{
+			label:            "With stable channel and prefix",
+			name:             ChannelStable,
+			prefix:           "app-",
+			n:                2,
+			expectedVersions: []string{"app-2.0.0"},
+		},
+		{
+			label:            "With prerelease channel and prefix",
+			name:             ChannelPrerelease,
+			prefix:           "app-",
+			n:                2,
+			expectedVersions: []string{"app-2.1.0-rc.1", "app-2.0.0"},
+		},
+		{
+			label:            "With stable channel and range",
+			name:             ChannelStable,
+			semver:           "<1.1.0",
+			n:                1,
+			expectedVersions: []string{"1.0.0"},
+		},
+		{
+			label:     "With no tag matching the prefix",
+			name:      ChannelStable,
+			prefix:    "other-",
+			n:         1,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewChannel(tt.name, tt.prefix, tt.semver)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.LatestN(versions, tt.n)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got %v, expected %v", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
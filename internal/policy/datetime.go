@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DateTimeOrderAsc ascending order, selects the most recent date and time
+	DateTimeOrderAsc = "ASC"
+	// DateTimeOrderDesc descending order, selects the oldest date and time
+	DateTimeOrderDesc = "DESC"
+)
+
+// DateTime represents an ordering policy based on a date and time parsed
+// from the tags with a Go time layout
+type DateTime struct {
+	Layout string
+	Order  string
+}
+
+// NewDateTime constructs a DateTime object validating the provided layout and
+// order argument
+func NewDateTime(layout string, order string) (*DateTime, error) {
+	if layout == "" {
+		return nil, fmt.Errorf("layout argument cannot be empty")
+	}
+
+	switch order {
+	case "":
+		order = DateTimeOrderAsc
+	case DateTimeOrderAsc, DateTimeOrderDesc:
+		break
+	default:
+		return nil, fmt.Errorf("invalid order argument provided: '%s', must be one of: %s, %s", order, DateTimeOrderAsc, DateTimeOrderDesc)
+	}
+
+	return &DateTime{
+		Layout: layout,
+		Order:  order,
+	}, nil
+}
+
+// Latest returns latest version from a provided list of strings. The versions
+// that can't be parsed with the layout are ignored.
+func (p *DateTime) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest. The versions that can't be parsed with the layout are
+// ignored.
+func (p *DateTime) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	type parsedVersion struct {
+		original string
+		time     time.Time
+	}
+	var parsed []parsedVersion
+	for _, version := range versions {
+		t, err := time.Parse(p.Layout, version)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, parsedVersion{original: version, time: t})
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: unable to parse any version from provided list with layout '%s'", ErrNoMatchingTag, p.Layout)
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		if p.Order == DateTimeOrderDesc {
+			return parsed[i].time.Before(parsed[j].time)
+		}
+		return parsed[i].time.After(parsed[j].time)
+	})
+	if n > len(parsed) {
+		n = len(parsed)
+	}
+	result := make([]string, n)
+	for i := range result {
+		result[i] = parsed[i].original
+	}
+	return result, nil
+}
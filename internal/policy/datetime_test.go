@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewDateTime(t *testing.T) {
+	cases := []struct {
+		label     string
+		layout    string
+		order     string
+		expectErr bool
+	}{
+		{
+			label:  "With valid empty order",
+			layout: "20060102",
+		},
+		{
+			label:  "With valid asc order",
+			layout: "20060102",
+			order:  DateTimeOrderAsc,
+		},
+		{
+			label:  "With valid desc order",
+			layout: "20060102",
+			order:  DateTimeOrderDesc,
+		},
+		{
+			label:     "With invalid order",
+			layout:    "20060102",
+			order:     "invalid",
+			expectErr: true,
+		},
+		{
+			label:     "With empty layout",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewDateTime(tt.layout, tt.order)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestDateTime_Latest(t *testing.T) {
+	cases := []struct {
+		label           string
+		layout          string
+		order           string
+		versions        []string
+		expectedVersion string
+		expectErr       bool
+	}{
+		{
+			label:           "With dates ascending",
+			layout:          "20060102",
+			versions:        shuffle([]string{"20240115", "20231231", "20240201", "20240114"}),
+			expectedVersion: "20240201",
+		},
+		{
+			label:           "With dates descending",
+			layout:          "20060102",
+			order:           DateTimeOrderDesc,
+			versions:        shuffle([]string{"20240115", "20231231", "20240201", "20240114"}),
+			expectedVersion: "20231231",
+		},
+		{
+			label:           "With date and time",
+			layout:          "2006-01-02T15-04-05",
+			versions:        shuffle([]string{"2024-01-15T10-00-00", "2024-01-15T09-59-59", "2024-01-15T10-00-01"}),
+			expectedVersion: "2024-01-15T10-00-01",
+		},
+		{
+			label:           "With unparsable versions",
+			layout:          "20060102",
+			versions:        shuffle([]string{"latest", "20240115", "2024-02-01", "main"}),
+			expectedVersion: "20240115",
+		},
+		{
+			label:     "With no parsable version",
+			layout:    "20060102",
+			versions:  []string{"latest", "main"},
+			expectErr: true,
+		},
+		{
+			label:     "Empty version list",
+			layout:    "20060102",
+			versions:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewDateTime(tt.layout, tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.Latest(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestDateTime_LatestN(t *testing.T) {
+	policy, err := NewDateTime("20060102", "")
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	latest, err := policy.LatestN([]string{"20240115", "latest", "20231231", "20240201"}, 5)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	expected := []string{"20240201", "20240115", "20231231"}
+	if !reflect.DeepEqual(latest, expected) {
+		t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, expected)
+	}
+	if _, err := policy.LatestN([]string{"20240115"}, 0); err == nil {
+		t.Fatalf("expecting error, got nil")
+	}
+}
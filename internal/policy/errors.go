@@ -0,0 +1,42 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import "errors"
+
+var (
+	// ErrInvalidPolicy is returned by Evaluate when the policy or the tag
+	// filter of an ImagePolicySpec can't be used.
+	ErrInvalidPolicy = errors.New("invalid policy")
+
+	// ErrInvalidRange is returned when a semver range can't be parsed. It
+	// is wrapped in ErrInvalidPolicy by Evaluate.
+	ErrInvalidRange = errors.New("invalid semver range")
+
+	// ErrFilterMatchedNothing is returned by Evaluate when the tag filter of
+	// an ImagePolicySpec matches none of the tags.
+	ErrFilterMatchedNothing = errors.New("tag filter matched nothing")
+
+	// ErrNoTags is returned by the Policers when they are given an empty
+	// list of tags.
+	ErrNoTags = errors.New("version list argument cannot be empty")
+
+	// ErrNoMatchingTag is returned by the Policers when none of the tags
+	// they are given can be selected, e.g. because no tag is within the
+	// semver range or can be parsed with the layout.
+	ErrNoMatchingTag = errors.New("no tag matches the policy")
+)
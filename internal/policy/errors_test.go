@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicerErrors(t *testing.T) {
+	semver, err := NewSemVer("1.x", SemVerOrderDesc, false)
+	if err != nil {
+		t.Fatalf("failed to create semver policer: %s", err)
+	}
+	alphabetical, err := NewAlphabetical(AlphabeticalOrderAsc)
+	if err != nil {
+		t.Fatalf("failed to create alphabetical policer: %s", err)
+	}
+	numerical, err := NewNumerical(NumericalOrderAsc, 10)
+	if err != nil {
+		t.Fatalf("failed to create numerical policer: %s", err)
+	}
+	datetime, err := NewDateTime("2006-01-02", DateTimeOrderAsc)
+	if err != nil {
+		t.Fatalf("failed to create datetime policer: %s", err)
+	}
+
+	cases := []struct {
+		label    string
+		policer  Policer
+		versions []string
+		wantErr  error
+	}{
+		{label: "semver without tags", policer: semver, versions: nil, wantErr: ErrNoTags},
+		{label: "alphabetical without tags", policer: alphabetical, versions: []string{}, wantErr: ErrNoTags},
+		{label: "numerical without tags", policer: numerical, versions: nil, wantErr: ErrNoTags},
+		{label: "semver outside of the range", policer: semver, versions: []string{"2.0.0", "foo"}, wantErr: ErrNoMatchingTag},
+		{label: "datetime without parsable tag", policer: datetime, versions: []string{"foo", "bar"}, wantErr: ErrNoMatchingTag},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := tt.policer.Latest(tt.versions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewSemVer_ErrInvalidRange(t *testing.T) {
+	_, err := NewSemVer("not-a-range", SemVerOrderDesc, false)
+	if !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("expected error %q, got %v", ErrInvalidRange, err)
+	}
+}
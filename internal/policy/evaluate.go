@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+// Evaluate applies the tag filter and the policy of the given spec to the
+// tags, and returns the selected tag as it appears in the list, before any
+// extraction by the filter, along with the number of tags that were passed to
+// the policy after filtering. It does not take the repository or the minimum
+// tag age into account. The current tag is the tag selected before, if any,
+// which anchors the policies depending on it, e.g. a SemVer policy locking the
+// major version. The created times are the times at which the tags were first
+// seen, breaking the ties of the policies configured to do so. The sizes are
+// the sizes of the images of the tags, ordering them for the size policy.
+func Evaluate(spec imagev1.ImagePolicySpec, tags []string, current string, created map[string]time.Time, sizes map[string]int64) (latest string, candidates int, err error) {
+	ordered, candidates, err := EvaluateN(spec, tags, current, created, sizes, 1)
+	if err != nil {
+		return "", candidates, err
+	}
+	return ordered[0], candidates, nil
+}
+
+// EvaluateN is like Evaluate, but returns up to n tags ordered from the
+// latest by the policy, as they appear in the list.
+func EvaluateN(spec imagev1.ImagePolicySpec, tags []string, current string, created map[string]time.Time, sizes map[string]int64, n int) (ordered []string, candidates int, err error) {
+	policer, err := PolicerFromSpec(spec.Policy)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	if !CreatedAsTiebreak(spec.Policy) {
+		created = nil
+	}
+
+	if spec.FilterTags == nil {
+		anchor(policer, current)
+		setCreated(policer, created)
+		setSizes(policer, sizes)
+		ordered, err = policer.LatestN(tags, n)
+		return ordered, len(tags), err
+	}
+
+	filter, err := RegexFilterFromSpec(*spec.FilterTags)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: failed to filter tags: %w", ErrInvalidPolicy, err)
+	}
+	// The current tag is anchored as the policy sees it, after extraction.
+	if current != "" {
+		filter.Apply([]string{current})
+		current = ""
+		if items := filter.Items(); len(items) > 0 {
+			current = items[0]
+		}
+	}
+	anchor(policer, current)
+	filter.Created = created
+	filter.Apply(tags)
+	items := filter.Items()
+	if len(items) == 0 {
+		pattern := spec.FilterTags.Pattern
+		if spec.FilterTags.Glob != "" {
+			pattern = spec.FilterTags.Glob
+		}
+		return nil, 0, fmt.Errorf("%w: none of the %d tags matched the pattern '%s'", ErrFilterMatchedNothing, len(tags), pattern)
+	}
+	// The times and sizes are looked up by the tags as the policy sees them.
+	if created != nil {
+		extracted := make(map[string]time.Time, len(items))
+		for _, item := range items {
+			if t, ok := created[filter.GetOriginalTag(item)]; ok {
+				extracted[item] = t
+			}
+		}
+		setCreated(policer, extracted)
+	}
+	if sizes != nil {
+		extracted := make(map[string]int64, len(items))
+		for _, item := range items {
+			if size, ok := sizes[filter.GetOriginalTag(item)]; ok {
+				extracted[item] = size
+			}
+		}
+		setSizes(policer, extracted)
+	}
+	ordered, err = policer.LatestN(items, n)
+	if err != nil {
+		return nil, len(items), err
+	}
+	for i := range ordered {
+		ordered[i] = filter.GetOriginalTag(ordered[i])
+	}
+	return ordered, len(items), nil
+}
+
+// anchor anchors the policies depending on the current tag to it.
+func anchor(policer Policer, current string) {
+	if t, ok := policer.(*Tiered); ok {
+		policer = t.Policer
+	}
+	if p, ok := policer.(*SemVer); ok {
+		p.Anchor(current)
+	}
+}
+
+// setCreated sets the created times of the tags on the policies breaking their
+// ties with them.
+func setCreated(policer Policer, created map[string]time.Time) {
+	if t, ok := policer.(*Tiered); ok {
+		policer = t.Policer
+	}
+	switch p := policer.(type) {
+	case *Numerical:
+		if p.CreatedAsTiebreak {
+			p.Created = created
+		}
+	case *Size:
+		p.Created = created
+	}
+}
+
+// setSizes sets the sizes of the images of the tags on the size policy.
+func setSizes(policer Policer, sizes map[string]int64) {
+	if t, ok := policer.(*Tiered); ok {
+		policer = t.Policer
+	}
+	if p, ok := policer.(*Size); ok {
+		p.Sizes = sizes
+	}
+}
+
+// CreatedAsTiebreak reports whether the policy of the choice breaks the ties
+// between tags ordered equally with the times they were first seen.
+func CreatedAsTiebreak(choice imagev1.ImagePolicyChoice) bool {
+	switch {
+	case choice.Numerical != nil:
+		return choice.Numerical.CreatedAsTiebreak
+	case choice.Alphabetical != nil:
+		return choice.Alphabetical.CreatedAsTiebreak
+	case choice.Size != nil:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		label                    string
+		spec                     imagev1.ImagePolicySpec
+		tags                     []string
+		expected                 string
+		wantCandidates           int
+		wantErr                  bool
+		wantInvalidPolicy        bool
+		wantFilterMatchedNothing bool
+	}{
+		{
+			label: "semver without filter",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			},
+			tags:           []string{"1.0.0", "1.2.0", "2.0.0"},
+			expected:       "1.2.0",
+			wantCandidates: 3,
+		},
+		{
+			label: "filter with extract",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+				FilterTags: &imagev1.TagFilter{
+					Pattern: `^main-[a-f0-9]+-(?P<ts>[0-9]+)`,
+					Extract: `$ts`,
+				},
+			},
+			tags:           []string{"main-abc123-100", "main-def456-200", "dev-fff000-300"},
+			expected:       "main-def456-200",
+			wantCandidates: 2,
+		},
+		{
+			label: "tiers with extract",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{
+					SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0-0"},
+					Tiers:  []imagev1.TagTier{{}, {Globs: []string{"*-rc.*"}}},
+				},
+				FilterTags: &imagev1.TagFilter{
+					Pattern: `^app-(?P<version>.*)$`,
+					Extract: `$version`,
+				},
+			},
+			tags:           []string{"app-1.0.0", "app-1.1.0-rc.1", "app-1.0.1"},
+			expected:       "app-1.0.1",
+			wantCandidates: 3,
+		},
+		{
+			label: "datetime with extract",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{Layout: "20060102"}},
+				FilterTags: &imagev1.TagFilter{
+					Pattern: `^app-(?P<date>[0-9]+)-`,
+					Extract: `$date`,
+				},
+			},
+			tags:           []string{"app-20240115-abcdef", "app-20240201-012345", "app-2024-fedcba", "latest"},
+			expected:       "app-20240201-012345",
+			wantCandidates: 3,
+		},
+		{
+			label: "calver with extract",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.MM"}},
+				FilterTags: &imagev1.TagFilter{
+					Pattern: `^release-(?P<version>.*)$`,
+					Extract: `$version`,
+				},
+			},
+			tags:           []string{"release-2024.9", "release-2024.10", "release-2024.10-rc.1", "2024.11"},
+			expected:       "release-2024.10",
+			wantCandidates: 3,
+		},
+		{
+			label: "alphabetical with composite extract and padding",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{
+					Pattern:        `^2\.(?P<minor>[0-9]+)-build\.(?P<build>[0-9]+)$`,
+					Extract:        `$minor.$build`,
+					ExtractPadding: 5,
+				},
+			},
+			tags:           []string{"2.1-build.45", "2.1-build.9", "2.9-build.3", "2.10-build.1"},
+			expected:       "2.10-build.1",
+			wantCandidates: 4,
+		},
+		{
+			label: "filter without match",
+			spec: imagev1.ImagePolicySpec{
+				Policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{Pattern: "^release-"},
+			},
+			tags:                     []string{"a", "b"},
+			wantErr:                  true,
+			wantFilterMatchedNothing: true,
+		},
+		{
+			label: "invalid range",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "*-*"}},
+			},
+			tags:              []string{"1.0.0"},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
+		{
+			label: "invalid pattern",
+			spec: imagev1.ImagePolicySpec{
+				Policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{Pattern: "[="},
+			},
+			tags:              []string{"a"},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
+		{
+			label: "invalid exclude pattern",
+			spec: imagev1.ImagePolicySpec{
+				Policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{Pattern: ".*", Exclude: "[="},
+			},
+			tags:              []string{"a"},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
+		{
+			label:             "no policy",
+			tags:              []string{"a"},
+			wantErr:           true,
+			wantInvalidPolicy: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, candidates, err := Evaluate(tt.spec, tt.tags, "", nil, nil)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(errors.Is(err, ErrInvalidPolicy)).To(Equal(tt.wantInvalidPolicy))
+			g.Expect(errors.Is(err, ErrFilterMatchedNothing)).To(Equal(tt.wantFilterMatchedNothing))
+			g.Expect(result).To(Equal(tt.expected))
+			g.Expect(candidates).To(Equal(tt.wantCandidates))
+		})
+	}
+}
+
+func TestEvaluateN(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := imagev1.ImagePolicySpec{
+		Policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+		FilterTags: &imagev1.TagFilter{
+			Pattern: `^main-[a-f0-9]+-(?P<ts>[0-9]+)`,
+			Extract: `$ts`,
+		},
+	}
+	tags := []string{"main-abc123-100", "main-def456-200", "main-fed321-300", "dev-fff000-400"}
+
+	// The tags are ordered from the latest, as they appear in the list.
+	ordered, candidates, err := EvaluateN(spec, tags, "", nil, nil, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordered).To(Equal([]string{"main-fed321-300", "main-def456-200"}))
+	g.Expect(candidates).To(Equal(3))
+
+	ordered, _, err = EvaluateN(spec, tags, "", nil, nil, 10)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordered).To(HaveLen(3))
+}
+
+func TestEvaluate_lockMajor(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := imagev1.ImagePolicySpec{
+		Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0", LockMajor: true}},
+		FilterTags: &imagev1.TagFilter{
+			Pattern: `^app-(?P<version>.*)$`,
+			Extract: `$version`,
+		},
+	}
+	tags := []string{"app-1.0.0", "app-1.2.0", "app-2.0.0"}
+
+	// The current tag is anchored after extraction.
+	latest, _, err := Evaluate(spec, tags, "app-1.0.0", nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(latest).To(Equal("app-1.2.0"))
+
+	latest, _, err = Evaluate(spec, tags, "", nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(latest).To(Equal("app-2.0.0"))
+}
+
+func TestEvaluate_createdAsTiebreak(t *testing.T) {
+	g := NewWithT(t)
+
+	// Rebuilds of the same build number extract to the same value.
+	now := time.Now()
+	tags := []string{"build-100-a", "build-100-b", "build-99-c"}
+	created := map[string]time.Time{
+		"build-100-a": now,
+		"build-100-b": now.Add(-time.Hour),
+		"build-99-c":  now.Add(time.Hour),
+	}
+	filter := &imagev1.TagFilter{
+		Pattern: `^build-(?P<n>[0-9]+)-`,
+		Extract: `$n`,
+	}
+
+	for _, tt := range []struct {
+		choice imagev1.ImagePolicyChoice
+		want   string
+	}{
+		{choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{CreatedAsTiebreak: true}}, want: "build-100-a"},
+		{choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Natural: true, CreatedAsTiebreak: true}}, want: "build-100-a"},
+		// The times are ignored unless the policy breaks its ties with
+		// them, the last of the tags in the list being kept.
+		{choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}}, want: "build-100-b"},
+		{choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Natural: true}}, want: "build-100-b"},
+	} {
+		spec := imagev1.ImagePolicySpec{Policy: tt.choice, FilterTags: filter}
+		latest, candidates, err := Evaluate(spec, tags, "", created, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(latest).To(Equal(tt.want))
+		g.Expect(candidates).To(Equal(2))
+	}
+
+	// The tags with the same value without extraction are ordered by their
+	// times too.
+	spec := imagev1.ImagePolicySpec{Policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{CreatedAsTiebreak: true}}}
+	ordered, _, err := EvaluateN(spec, []string{"1", "1.0", "0"}, "", map[string]time.Time{"1": now, "1.0": now.Add(-time.Hour)}, nil, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordered).To(Equal([]string{"1", "1.0", "0"}))
+	ordered, _, err = EvaluateN(spec, []string{"1", "1.0", "0"}, "", map[string]time.Time{"1": now.Add(-time.Hour), "1.0": now}, nil, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordered).To(Equal([]string{"1.0", "1", "0"}))
+}
+
+func TestEvaluate_size(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	tags := []string{"app-1.0.0", "app-1.0.0-alpine", "app-1.1.0-alpine", "other-0.1.0"}
+	sizes := map[string]int64{
+		"app-1.0.0":        300,
+		"app-1.0.0-alpine": 100,
+		"app-1.1.0-alpine": 100,
+		"other-0.1.0":      10,
+	}
+	created := map[string]time.Time{
+		"app-1.0.0-alpine": now.Add(-time.Hour),
+		"app-1.1.0-alpine": now,
+	}
+
+	// The sizes and the times are looked up by the tags before extraction.
+	spec := imagev1.ImagePolicySpec{
+		Policy: imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}},
+		FilterTags: &imagev1.TagFilter{
+			Pattern: `^app-(?P<version>.*)$`,
+			Extract: `$version`,
+		},
+	}
+	ordered, candidates, err := EvaluateN(spec, tags, "", created, sizes, 3)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ordered).To(Equal([]string{"app-1.1.0-alpine", "app-1.0.0-alpine", "app-1.0.0"}))
+	g.Expect(candidates).To(Equal(3))
+
+	// Without recorded sizes, no tag is selected.
+	_, _, err = Evaluate(spec, tags, "", created, nil)
+	g.Expect(errors.Is(err, ErrNoMatchingTag)).To(BeTrue())
+}
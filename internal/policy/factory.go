@@ -23,23 +23,229 @@ import (
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 )
 
-// PolicerFromSpec constructs a new policy object based on
+// PolicerFromSpec constructs a new policy object based on the given
+// ImagePolicyChoice. It returns an error if the choice does not set exactly
+// one policy.
 func PolicerFromSpec(choice imagev1.ImagePolicyChoice) (Policer, error) {
+	if set := policyChoiceFields(choice); len(set) > 1 {
+		return nil, fmt.Errorf("only one policy may be set, got %s", strings.Join(set, " and "))
+	}
+
 	var p Policer
 	var err error
 	switch {
 	case choice.SemVer != nil:
-		p, err = NewSemVer(choice.SemVer.Range)
+		var ranges []string
+		if choice.SemVer.Range != "" {
+			ranges = append(ranges, choice.SemVer.Range)
+		}
+		ranges = append(ranges, choice.SemVer.Ranges...)
+		var s *SemVer
+		s, err = NewSemVerRanges(ranges, strings.ToUpper(choice.SemVer.Order), choice.SemVer.IncludePrerelease)
+		if err == nil {
+			s.LockMajor = choice.SemVer.LockMajor
+			s.BuildMetadataAsTiebreak = choice.SemVer.BuildMetadataAsTiebreak
+		}
+		p = s
 	case choice.Alphabetical != nil:
-		p, err = NewAlphabetical(strings.ToUpper(choice.Alphabetical.Order))
+		var a *Alphabetical
+		a, err = NewAlphabetical(strings.ToUpper(choice.Alphabetical.Order))
+		if err == nil {
+			a.Natural = choice.Alphabetical.Natural
+			a.CreatedAsTiebreak = choice.Alphabetical.CreatedAsTiebreak
+		}
+		p = a
 	case choice.Numerical != nil:
-		p, err = NewNumerical(strings.ToUpper(choice.Numerical.Order))
+		var n *Numerical
+		n, err = NewNumerical(strings.ToUpper(choice.Numerical.Order), choice.Numerical.Base)
+		if err == nil {
+			n.TrimPrefix = choice.Numerical.TrimPrefix
+			n.TrimSuffix = choice.Numerical.TrimSuffix
+			n.CreatedAsTiebreak = choice.Numerical.CreatedAsTiebreak
+		}
+		p = n
+	case choice.DateTime != nil:
+		p, err = NewDateTime(choice.DateTime.Layout, strings.ToUpper(choice.DateTime.Order))
+	case choice.CalVer != nil:
+		p, err = NewCalVer(choice.CalVer.Layout, strings.ToUpper(choice.CalVer.Order))
+	case choice.Channel != nil:
+		p, err = NewChannel(string(choice.Channel.Name), choice.Channel.Prefix, choice.Channel.Range)
+	case choice.Tag != nil:
+		p, err = NewTag(choice.Tag.Name)
+	case choice.Size != nil:
+		p = NewSize()
 	default:
-		return nil, fmt.Errorf("given ImagePolicyChoice object is invalid")
+		return nil, fmt.Errorf("given ImagePolicyChoice object is invalid: one of semver, alphabetical, numerical, dateTime, calver, channel, tag and size must be set")
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	if len(choice.Tiers) > 0 {
+		tiers := make([][]string, 0, len(choice.Tiers))
+		for _, tier := range choice.Tiers {
+			tiers = append(tiers, tier.Globs)
+		}
+		return NewTiered(p, tiers)
+	}
 	return p, nil
 }
+
+// policyChoiceFields returns the names of the policies set in the choice.
+func policyChoiceFields(choice imagev1.ImagePolicyChoice) []string {
+	var set []string
+	if choice.SemVer != nil {
+		set = append(set, "semver")
+	}
+	if choice.Alphabetical != nil {
+		set = append(set, "alphabetical")
+	}
+	if choice.Numerical != nil {
+		set = append(set, "numerical")
+	}
+	if choice.DateTime != nil {
+		set = append(set, "dateTime")
+	}
+	if choice.CalVer != nil {
+		set = append(set, "calver")
+	}
+	if choice.Channel != nil {
+		set = append(set, "channel")
+	}
+	if choice.Tag != nil {
+		set = append(set, "tag")
+	}
+	if choice.Size != nil {
+		set = append(set, "size")
+	}
+	return set
+}
+
+// EffectivePolicyChoice returns the ImagePolicyChoice that corresponds to the
+// configuration of the given Policer, with the defaults applied.
+func EffectivePolicyChoice(p Policer) (*imagev1.ImagePolicyChoice, error) {
+	switch p := p.(type) {
+	case *Tiered:
+		choice, err := EffectivePolicyChoice(p.Policer)
+		if err != nil {
+			return nil, err
+		}
+		for _, globs := range p.Tiers {
+			choice.Tiers = append(choice.Tiers, imagev1.TagTier{Globs: globs})
+		}
+		return choice, nil
+	case *SemVer:
+		return &imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{
+			Ranges:                  p.Ranges,
+			Order:                   strings.ToLower(p.Order),
+			IncludePrerelease:       p.IncludePrerelease,
+			LockMajor:               p.LockMajor,
+			BuildMetadataAsTiebreak: p.BuildMetadataAsTiebreak,
+		}}, nil
+	case *Alphabetical:
+		return &imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{
+			Order:             strings.ToLower(p.Order),
+			Natural:           p.Natural,
+			CreatedAsTiebreak: p.CreatedAsTiebreak,
+		}}, nil
+	case *Numerical:
+		return &imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{
+			Order:             strings.ToLower(p.Order),
+			Base:              p.Base,
+			TrimPrefix:        p.TrimPrefix,
+			TrimSuffix:        p.TrimSuffix,
+			CreatedAsTiebreak: p.CreatedAsTiebreak,
+		}}, nil
+	case *DateTime:
+		return &imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{
+			Layout: p.Layout,
+			Order:  strings.ToLower(p.Order),
+		}}, nil
+	case *CalVer:
+		return &imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{
+			Layout: p.Layout,
+			Order:  strings.ToLower(p.Order),
+		}}, nil
+	case *Channel:
+		return &imagev1.ImagePolicyChoice{Channel: &imagev1.ChannelPolicy{
+			Name:   imagev1.ChannelName(p.Name),
+			Prefix: p.Prefix,
+			Range:  p.Range,
+		}}, nil
+	case *Tag:
+		return &imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{
+			Name: p.Name,
+		}}, nil
+	case *Size:
+		return &imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported policy type %T", p)
+	}
+}
+
+// Describe returns a human-readable description of the configuration of the
+// given Policer, with the defaults applied, e.g. "semver range 1.x, order
+// desc". It's meant for explaining how the latest tag was selected.
+func Describe(p Policer) string {
+	switch p := p.(type) {
+	case *Tiered:
+		tiers := make([]string, 0, len(p.Tiers))
+		for _, globs := range p.Tiers {
+			if len(globs) == 0 {
+				tiers = append(tiers, "rest")
+				continue
+			}
+			tiers = append(tiers, strings.Join(globs, " "))
+		}
+		return fmt.Sprintf("%s, tiers %s", Describe(p.Policer), strings.Join(tiers, " > "))
+	case *SemVer:
+		desc := fmt.Sprintf("semver range %s, order %s", strings.Join(p.Ranges, " || "), strings.ToLower(p.Order))
+		if p.IncludePrerelease {
+			desc += ", including prereleases"
+		}
+		if p.LockMajor {
+			desc += ", major version locked"
+		}
+		if p.BuildMetadataAsTiebreak {
+			desc += ", build metadata as tiebreak"
+		}
+		return desc
+	case *Alphabetical:
+		desc := fmt.Sprintf("alphabetical, order %s", strings.ToLower(p.Order))
+		if p.Natural {
+			desc += ", natural"
+		}
+		if p.CreatedAsTiebreak {
+			desc += ", created as tiebreak"
+		}
+		return desc
+	case *Numerical:
+		desc := fmt.Sprintf("numerical base %d, order %s", p.Base, strings.ToLower(p.Order))
+		if p.TrimPrefix != "" {
+			desc += fmt.Sprintf(", trim prefix '%s'", p.TrimPrefix)
+		}
+		if p.TrimSuffix != "" {
+			desc += fmt.Sprintf(", trim suffix '%s'", p.TrimSuffix)
+		}
+		if p.CreatedAsTiebreak {
+			desc += ", created as tiebreak"
+		}
+		return desc
+	case *DateTime:
+		return fmt.Sprintf("dateTime layout %s, order %s", p.Layout, strings.ToLower(p.Order))
+	case *CalVer:
+		return fmt.Sprintf("calver layout %s, order %s", p.Layout, strings.ToLower(p.Order))
+	case *Channel:
+		desc := fmt.Sprintf("channel %s, range %s", p.Name, p.Range)
+		if p.Prefix != "" {
+			desc += fmt.Sprintf(", prefix '%s'", p.Prefix)
+		}
+		return desc
+	case *Tag:
+		return fmt.Sprintf("tag %s", p.Name)
+	case *Size:
+		return "size, smallest first"
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package policy
 
 import (
+	"reflect"
 	"testing"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
@@ -29,20 +30,116 @@ func TestFactory_PolicerFromSpec(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 
+	// With more than one policy
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{
+		SemVer:    &imagev1.SemVerPolicy{Range: "1.0.x"},
+		Numerical: &imagev1.NumericalPolicy{},
+	})
+	if err == nil || err.Error() != "only one policy may be set, got semver and numerical" {
+		t.Errorf("expected conflicting policies error, got %v", err)
+	}
+
 	// With SemVerPolicy
 	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}})
 	if err != nil {
 		t.Error("should not return error")
 	}
 
+	// With ascending SemVerPolicy
+	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x", Order: "asc"}})
+	if err != nil {
+		t.Error("should not return error")
+	}
+	if p.(*SemVer).Order != SemVerOrderAsc {
+		t.Errorf("expected order %s, got %s", SemVerOrderAsc, p.(*SemVer).Order)
+	}
+
+	// With SemVerPolicy ranges
+	p, err = PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x", Ranges: []string{"2.0.x"}}})
+	if err != nil {
+		t.Error("should not return error")
+	}
+	if ranges := p.(*SemVer).Ranges; len(ranges) != 2 {
+		t.Errorf("expected 2 ranges, got %v", ranges)
+	}
+
+	// With SemVerPolicy without any range
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{}})
+	if err == nil {
+		t.Error("should return error")
+	}
+
 	// With AlphabeticalPolicy
 	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}})
 	if err != nil {
 		t.Error("should not return error")
 	}
 
+	// With DateTimePolicy
+	p, err = PolicerFromSpec(imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{Layout: "20060102", Order: "desc"}})
+	if err != nil {
+		t.Error("should not return error")
+	}
+	if p.(*DateTime).Order != DateTimeOrderDesc {
+		t.Errorf("expected order %s, got %s", DateTimeOrderDesc, p.(*DateTime).Order)
+	}
+
+	// With DateTimePolicy without layout
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{}})
+	if err == nil {
+		t.Error("should return error")
+	}
+
+	// With CalVerPolicy
+	p, err = PolicerFromSpec(imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.MM", Order: "desc"}})
+	if err != nil {
+		t.Error("should not return error")
+	}
+	if p.(*CalVer).Order != CalVerOrderDesc {
+		t.Errorf("expected order %s, got %s", CalVerOrderDesc, p.(*CalVer).Order)
+	}
+
+	// With CalVerPolicy without segments in the layout
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "2006.01"}})
+	if err == nil {
+		t.Error("should return error")
+	}
+
+	// With SizePolicy along with another policy
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{Name: "main"}, Size: &imagev1.SizePolicy{}})
+	if err == nil || err.Error() != "only one policy may be set, got tag and size" {
+		t.Errorf("expected conflicting policies error, got %v", err)
+	}
+
+	// With TagPolicy without name
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{}})
+	if err == nil {
+		t.Error("should return error")
+	}
+
+	// With tiers
+	p, err = PolicerFromSpec(imagev1.ImagePolicyChoice{
+		SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0-0"},
+		Tiers:  []imagev1.TagTier{{}, {Globs: []string{"*-rc.*"}}},
+	})
+	if err != nil {
+		t.Error("should not return error")
+	}
+	if _, ok := p.(*Tiered); !ok {
+		t.Errorf("expected a tiered policy, got %T", p)
+	}
+
+	// With several tiers without globs
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{
+		SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0-0"},
+		Tiers:  []imagev1.TagTier{{}, {}},
+	})
+	if err == nil {
+		t.Error("should return error")
+	}
+
 	// A nil checkable Policer for invalid policy.
-	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "*-*"}})
+	p, err = PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "*-*"}})
 	if err == nil {
 		t.Error("should return error")
 	}
@@ -50,3 +147,234 @@ func TestFactory_PolicerFromSpec(t *testing.T) {
 		t.Error("should be nil")
 	}
 }
+
+func TestFactory_PolicerFromSpec_orderEnum(t *testing.T) {
+	// The order is given with the values of the CRD enum, in lowercase.
+	for _, order := range []string{"asc", "desc"} {
+		choices := map[string]imagev1.ImagePolicyChoice{
+			"semver":       {SemVer: &imagev1.SemVerPolicy{Range: "1.0.x", Order: order}},
+			"alphabetical": {Alphabetical: &imagev1.AlphabeticalPolicy{Order: order}},
+			"numerical":    {Numerical: &imagev1.NumericalPolicy{Order: order}},
+			"dateTime":     {DateTime: &imagev1.DateTimePolicy{Layout: "20060102", Order: order}},
+			"calver":       {CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.MM", Order: order}},
+		}
+		for name, choice := range choices {
+			p, err := PolicerFromSpec(choice)
+			if err != nil {
+				t.Fatalf("PolicerFromSpec() of %s with order %q error = %v", name, order, err)
+			}
+			effective, err := EffectivePolicyChoice(p)
+			if err != nil {
+				t.Fatalf("EffectivePolicyChoice() of %s error = %v", name, err)
+			}
+			if got := orderOf(effective); got != order {
+				t.Fatalf("effective order of %s got %q, want %q", name, got, order)
+			}
+		}
+	}
+}
+
+func orderOf(choice *imagev1.ImagePolicyChoice) string {
+	switch {
+	case choice.SemVer != nil:
+		return choice.SemVer.Order
+	case choice.Alphabetical != nil:
+		return choice.Alphabetical.Order
+	case choice.Numerical != nil:
+		return choice.Numerical.Order
+	case choice.DateTime != nil:
+		return choice.DateTime.Order
+	case choice.CalVer != nil:
+		return choice.CalVer.Order
+	}
+	return ""
+}
+
+func TestFactory_EffectivePolicyChoice(t *testing.T) {
+	cases := []struct {
+		label  string
+		choice imagev1.ImagePolicyChoice
+		want   imagev1.ImagePolicyChoice
+	}{
+		{
+			label:  "SemVer with defaults",
+			choice: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			want:   imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Ranges: []string{"1.0.x"}, Order: "desc"}},
+		},
+		{
+			label: "SemVer with range, ranges and prereleases",
+			choice: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{
+				Range: "1.0.x", Ranges: []string{"2.0.x"}, Order: "ASC", IncludePrerelease: true,
+			}},
+			want: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{
+				Ranges: []string{"1.0.x", "2.0.x"}, Order: "asc", IncludePrerelease: true,
+			}},
+		},
+		{
+			label:  "Alphabetical with defaults",
+			choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+			want:   imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Order: "asc"}},
+		},
+		{
+			label:  "Alphabetical natural",
+			choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Order: "desc", Natural: true}},
+			want:   imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Order: "desc", Natural: true}},
+		},
+		{
+			label:  "Numerical",
+			choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{Order: "desc"}},
+			want:   imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{Order: "desc", Base: 10}},
+		},
+		{
+			label:  "Numerical with trimmed prefix and suffix",
+			choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{TrimPrefix: "build-", TrimSuffix: "-amd64"}},
+			want:   imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{Order: "asc", Base: 10, TrimPrefix: "build-", TrimSuffix: "-amd64"}},
+		},
+		{
+			label:  "Numerical with created as tiebreak",
+			choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{CreatedAsTiebreak: true}},
+			want:   imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{Order: "asc", Base: 10, CreatedAsTiebreak: true}},
+		},
+		{
+			label:  "DateTime with defaults",
+			choice: imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{Layout: "20060102"}},
+			want:   imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{Layout: "20060102", Order: "asc"}},
+		},
+		{
+			label:  "CalVer with defaults",
+			choice: imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.0M.0D"}},
+			want:   imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.0M.0D", Order: "asc"}},
+		},
+		{
+			label:  "Channel with defaults",
+			choice: imagev1.ImagePolicyChoice{Channel: &imagev1.ChannelPolicy{Name: imagev1.ChannelStable, Prefix: "app-"}},
+			want:   imagev1.ImagePolicyChoice{Channel: &imagev1.ChannelPolicy{Name: imagev1.ChannelStable, Prefix: "app-", Range: "*"}},
+		},
+		{
+			label:  "Tag",
+			choice: imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{Name: "main"}},
+			want:   imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{Name: "main"}},
+		},
+		{
+			label:  "Size",
+			choice: imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}},
+			want:   imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}},
+		},
+		{
+			label: "SemVer with tiers",
+			choice: imagev1.ImagePolicyChoice{
+				SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0-0"},
+				Tiers:  []imagev1.TagTier{{}, {Globs: []string{"*-rc.*"}}},
+			},
+			want: imagev1.ImagePolicyChoice{
+				SemVer: &imagev1.SemVerPolicy{Ranges: []string{">=1.0.0-0"}, Order: "desc"},
+				Tiers:  []imagev1.TagTier{{}, {Globs: []string{"*-rc.*"}}},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			p, err := PolicerFromSpec(tt.choice)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			got, err := EffectivePolicyChoice(p)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("incorrect effective policy, got %#v, expected %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFactory_Describe(t *testing.T) {
+	cases := []struct {
+		label  string
+		choice imagev1.ImagePolicyChoice
+		want   string
+	}{
+		{
+			label:  "SemVer",
+			choice: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			want:   "semver range 1.x, order desc",
+		},
+		{
+			label:  "SemVer with ranges and prereleases",
+			choice: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Ranges: []string{"1.x", "2.x"}, Order: "asc", IncludePrerelease: true}},
+			want:   "semver range 1.x || 2.x, order asc, including prereleases",
+		},
+		{
+			label:  "SemVer with major version locked",
+			choice: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0", LockMajor: true}},
+			want:   "semver range >=1.0.0, order desc, major version locked",
+		},
+		{
+			label:  "Alphabetical",
+			choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Order: "desc"}},
+			want:   "alphabetical, order desc",
+		},
+		{
+			label:  "Alphabetical natural",
+			choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Natural: true}},
+			want:   "alphabetical, order asc, natural",
+		},
+		{
+			label:  "Alphabetical with created as tiebreak",
+			choice: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{CreatedAsTiebreak: true}},
+			want:   "alphabetical, order asc, created as tiebreak",
+		},
+		{
+			label:  "Numerical",
+			choice: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{TrimPrefix: "build-"}},
+			want:   "numerical base 10, order asc, trim prefix 'build-'",
+		},
+		{
+			label:  "DateTime",
+			choice: imagev1.ImagePolicyChoice{DateTime: &imagev1.DateTimePolicy{Layout: "20060102"}},
+			want:   "dateTime layout 20060102, order asc",
+		},
+		{
+			label:  "CalVer",
+			choice: imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Layout: "YYYY.MM", Order: "desc"}},
+			want:   "calver layout YYYY.MM, order desc",
+		},
+		{
+			label:  "Channel",
+			choice: imagev1.ImagePolicyChoice{Channel: &imagev1.ChannelPolicy{Name: imagev1.ChannelPrerelease, Prefix: "app-"}},
+			want:   "channel prerelease, range *, prefix 'app-'",
+		},
+		{
+			label:  "Tag",
+			choice: imagev1.ImagePolicyChoice{Tag: &imagev1.TagPolicy{Name: "main"}},
+			want:   "tag main",
+		},
+		{
+			label:  "Size",
+			choice: imagev1.ImagePolicyChoice{Size: &imagev1.SizePolicy{}},
+			want:   "size, smallest first",
+		},
+		{
+			label: "SemVer with tiers",
+			choice: imagev1.ImagePolicyChoice{
+				SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0-0"},
+				Tiers:  []imagev1.TagTier{{}, {Globs: []string{"*-rc.*", "*-beta.*"}}},
+			},
+			want: "semver range >=1.0.0-0, order desc, tiers rest > *-rc.* *-beta.*",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			p, err := PolicerFromSpec(tt.choice)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if got := Describe(p); got != tt.want {
+				t.Errorf("incorrect description, got %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,8 +17,13 @@ limitations under the License.
 package policy
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 )
 
 // RegexFilter represents a regular expression filter
@@ -27,6 +32,14 @@ type RegexFilter struct {
 
 	Regexp  *regexp.Regexp
 	Replace string
+	Exclude *regexp.Regexp
+	// Padding is the width to which the numbers in the values built by
+	// Replace are left-padded with zeros. Zero disables the padding.
+	Padding int
+	// Created, if set, is the time at which each tag was first seen. Among
+	// the tags extracted to the same value, the one first seen the most
+	// recently is kept rather than the last one in the list.
+	Created map[string]time.Time
 }
 
 // NewRegexFilter constructs new RegexFilter object
@@ -41,25 +54,93 @@ func NewRegexFilter(pattern string, replace string) (*RegexFilter, error) {
 	}, nil
 }
 
+// NewRegexFilterWithExclude constructs new RegexFilter object which also drops
+// the tags matching the exclude pattern
+func NewRegexFilterWithExclude(pattern string, replace string, exclude string) (*RegexFilter, error) {
+	f, err := NewRegexFilter(pattern, replace)
+	if err != nil {
+		return nil, err
+	}
+	if exclude != "" {
+		m, err := regexp.Compile(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression exclude pattern '%s': %w", exclude, err)
+		}
+		f.Exclude = m
+	}
+	return f, nil
+}
+
+// RegexFilterFromSpec constructs new RegexFilter object based on the given
+// TagFilter. A glob is translated to the equivalent regular expression.
+func RegexFilterFromSpec(spec imagev1.TagFilter) (*RegexFilter, error) {
+	pattern, exclude := spec.Pattern, spec.Exclude
+	if spec.Glob != "" {
+		if spec.Pattern != "" {
+			return nil, errors.New("only one of pattern and glob can be set")
+		}
+		if spec.Extract != "" {
+			return nil, errors.New("extract can't be used with a glob, as it has no capture groups")
+		}
+		var err error
+		pattern, err = globToRegexp(spec.Glob)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if spec.ExtractPadding < 0 {
+		return nil, fmt.Errorf("invalid extract padding %d, must not be negative", spec.ExtractPadding)
+	}
+	if spec.ExtractPadding > 0 && spec.Extract == "" {
+		return nil, errors.New("extract padding can only be used with extract")
+	}
+	if spec.CaseInsensitive {
+		pattern = "(?i)" + pattern
+		if exclude != "" {
+			exclude = "(?i)" + exclude
+		}
+	}
+	f, err := NewRegexFilterWithExclude(pattern, spec.Extract, exclude)
+	if err != nil {
+		return nil, err
+	}
+	f.Padding = spec.ExtractPadding
+	return f, nil
+}
+
 // Apply will construct the filtered list of tags based on the provided list of tags
 func (f *RegexFilter) Apply(list []string) {
-	f.filtered = map[string]string{}
+	f.filtered = make(map[string]string, len(list))
+	var result []byte
 	for _, item := range list {
+		if f.Exclude != nil && f.Exclude.MatchString(item) {
+			continue
+		}
+		// Without a replacement, there's no need for the submatches, and
+		// matching alone is much cheaper.
+		if f.Replace == "" {
+			if f.Regexp.MatchString(item) {
+				f.filtered[item] = item
+			}
+			continue
+		}
 		if submatches := f.Regexp.FindStringSubmatchIndex(item); len(submatches) > 0 {
-			tag := item
-			if f.Replace != "" {
-				result := []byte{}
-				result = f.Regexp.ExpandString(result, f.Replace, item, submatches)
-				tag = string(result)
+			result = f.Regexp.ExpandString(result[:0], f.Replace, item, submatches)
+			key := padNumbers(string(result), f.Padding)
+			if prev, ok := f.filtered[key]; ok && f.Created != nil && f.Created[prev].After(f.Created[item]) {
+				continue
 			}
-			f.filtered[tag] = item
+			f.filtered[key] = item
 		}
 	}
 }
 
 // Items returns the list of filtered tags
 func (f *RegexFilter) Items() []string {
-	var filtered []string
+	if len(f.filtered) == 0 {
+		return nil
+	}
+	filtered := make([]string, 0, len(f.filtered))
 	for k := range f.filtered {
 		filtered = append(filtered, k)
 	}
@@ -70,3 +151,75 @@ func (f *RegexFilter) Items() []string {
 func (f *RegexFilter) GetOriginalTag(tag string) string {
 	return f.filtered[tag]
 }
+
+// padNumbers returns s with each run of digits left-padded with zeros to the
+// given width, so that the numbers are ordered by their value when compared
+// alphabetically.
+func padNumbers(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if !isDigit(s[i]) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if n := i - start; n < width {
+			b.WriteString(strings.Repeat("0", width-n))
+		}
+		b.WriteString(s[start:i])
+	}
+	return b.String()
+}
+
+// globToRegexp translates a glob to a regular expression matching the whole
+// tag. `*` matches any sequence of characters, `?` matches any single
+// character, `[...]` matches a character class, negated by a leading `!` or
+// `^`, and `\` escapes the next character.
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			i++
+			if i == len(glob) {
+				return "", fmt.Errorf("invalid glob '%s': trailing escape", glob)
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("invalid glob '%s': unterminated character class", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			b.WriteString("[")
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			if class == "" {
+				return "", fmt.Errorf("invalid glob '%s': empty character class", glob)
+			}
+			// Keep the ranges, and escape anything else the regular
+			// expression syntax would interpret.
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `[`, `\[`, `^`, `\^`).Replace(class))
+			b.WriteString("]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
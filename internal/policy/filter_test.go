@@ -17,10 +17,14 @@ limitations under the License.
 package policy
 
 import (
+	"fmt"
+	"regexp"
 	"sort"
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 )
 
 func TestRegexFilter(t *testing.T) {
@@ -29,6 +33,7 @@ func TestRegexFilter(t *testing.T) {
 		tags     []string
 		pattern  string
 		extract  string
+		exclude  string
 		expected []string
 	}{
 		{
@@ -93,13 +98,27 @@ func TestRegexFilter(t *testing.T) {
 				"123-123.123.abcd456",
 			},
 		},
+		{
+			label:    "valid pattern with exclude",
+			tags:     []string{"1.2.3", "1.2.3-debug", "1.2.4", "latest", "nightly"},
+			exclude:  `^(latest|nightly)$|-debug$`,
+			expected: []string{"1.2.3", "1.2.4"},
+		},
+		{
+			label:    "exclude applied to the original tags",
+			tags:     []string{"ver1-rc", "ver2", "ver3-rc", "rel1"},
+			pattern:  `^ver(\d+)`,
+			extract:  `$1`,
+			exclude:  `^ver3`,
+			expected: []string{"1", "2"},
+		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.label, func(t *testing.T) {
 			g := NewWithT(t)
 
-			f, err := NewRegexFilter(tt.pattern, tt.extract)
+			f, err := NewRegexFilterWithExclude(tt.pattern, tt.extract, tt.exclude)
 			g.Expect(err).ToNot(HaveOccurred())
 
 			f.Apply(tt.tags)
@@ -110,3 +129,215 @@ func TestRegexFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRegexFilterWithExclude(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NewRegexFilterWithExclude("^ver", "", "[=")
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewRegexFilterWithExclude("[=", "", "^latest$")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRegexFilterFromSpec(t *testing.T) {
+	cases := []struct {
+		label    string
+		tags     []string
+		spec     imagev1.TagFilter
+		expected map[string]string
+	}{
+		{
+			label: "case sensitive",
+			tags:  []string{"1.0.0-RC1", "1.0.0-rc2", "1.0.0"},
+			spec: imagev1.TagFilter{
+				Pattern: `-rc(?P<rc>[0-9]+)$`,
+				Extract: `$rc`,
+			},
+			expected: map[string]string{"2": "1.0.0-rc2"},
+		},
+		{
+			label: "case insensitive with extract",
+			tags:  []string{"1.0.0-RC1", "1.0.0-rc2", "1.0.0-Rc3", "1.0.0"},
+			spec: imagev1.TagFilter{
+				Pattern:         `-rc(?P<rc>[0-9]+)$`,
+				Extract:         `$rc`,
+				CaseInsensitive: true,
+			},
+			expected: map[string]string{"1": "1.0.0-RC1", "2": "1.0.0-rc2", "3": "1.0.0-Rc3"},
+		},
+		{
+			label: "case insensitive with exclude",
+			tags:  []string{"1.0.0-RC1", "1.0.0-rc2-DEBUG", "1.0.0-Rc3"},
+			spec: imagev1.TagFilter{
+				Pattern:         `-rc[0-9]+`,
+				Exclude:         `-debug$`,
+				CaseInsensitive: true,
+			},
+			expected: map[string]string{"1.0.0-RC1": "1.0.0-RC1", "1.0.0-Rc3": "1.0.0-Rc3"},
+		},
+		{
+			label: "extract with padding",
+			tags:  []string{"2.1-build.45", "2.1-build.9", "2.10-build.3", "latest"},
+			spec: imagev1.TagFilter{
+				Pattern:        `^(?P<major>[0-9]+)\.(?P<minor>[0-9]+)-build\.(?P<build>[0-9]+)$`,
+				Extract:        `$minor.$build`,
+				ExtractPadding: 4,
+			},
+			expected: map[string]string{"0001.0045": "2.1-build.45", "0001.0009": "2.1-build.9", "0010.0003": "2.10-build.3"},
+		},
+		{
+			label: "glob",
+			tags:  []string{"v1.0.0", "v1.1.0", "release-1", "1.0.0"},
+			spec: imagev1.TagFilter{
+				Glob: `v*`,
+			},
+			expected: map[string]string{"v1.0.0": "v1.0.0", "v1.1.0": "v1.1.0"},
+		},
+		{
+			label: "case insensitive glob with exclude",
+			tags:  []string{"Release-1", "release-2-debug", "release-3", "v1"},
+			spec: imagev1.TagFilter{
+				Glob:            `release-*`,
+				Exclude:         `-debug$`,
+				CaseInsensitive: true,
+			},
+			expected: map[string]string{"Release-1": "Release-1", "release-3": "release-3"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			g := NewWithT(t)
+
+			f, err := RegexFilterFromSpec(tt.spec)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			f.Apply(tt.tags)
+			r := f.Items()
+			g.Expect(r).To(HaveLen(len(tt.expected)))
+			for _, item := range r {
+				g.Expect(f.GetOriginalTag(item)).To(Equal(tt.expected[item]))
+			}
+		})
+	}
+}
+
+func TestRegexFilterFromSpecInvalidGlob(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := RegexFilterFromSpec(imagev1.TagFilter{Glob: "v*", Pattern: "^v"})
+	g.Expect(err).To(MatchError(ContainSubstring("only one of pattern and glob")))
+
+	_, err = RegexFilterFromSpec(imagev1.TagFilter{Glob: "v*", Extract: "$1"})
+	g.Expect(err).To(MatchError(ContainSubstring("extract can't be used with a glob")))
+
+	_, err = RegexFilterFromSpec(imagev1.TagFilter{Glob: "v[0-9"})
+	g.Expect(err).To(MatchError(ContainSubstring("unterminated character class")))
+
+	_, err = RegexFilterFromSpec(imagev1.TagFilter{Pattern: "^v", ExtractPadding: 4})
+	g.Expect(err).To(MatchError(ContainSubstring("extract padding can only be used with extract")))
+
+	_, err = RegexFilterFromSpec(imagev1.TagFilter{Pattern: "^v(.*)", Extract: "$1", ExtractPadding: -1})
+	g.Expect(err).To(MatchError(ContainSubstring("must not be negative")))
+}
+
+func TestPadNumbers(t *testing.T) {
+	cases := []struct {
+		s        string
+		width    int
+		expected string
+	}{
+		{s: "1.45", width: 0, expected: "1.45"},
+		{s: "1.45", width: 3, expected: "001.045"},
+		{s: "build-12345", width: 3, expected: "build-12345"},
+		{s: "rc", width: 3, expected: "rc"},
+		{s: "v2-3", width: 2, expected: "v02-03"},
+	}
+
+	for _, tt := range cases {
+		if got := padNumbers(tt.s, tt.width); got != tt.expected {
+			t.Errorf("padNumbers(%q, %d) = %q, expected %q", tt.s, tt.width, got, tt.expected)
+		}
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		glob     string
+		matches  []string
+		excludes []string
+	}{
+		{glob: "v*", matches: []string{"v", "v1.0.0", "v1-rc.1"}, excludes: []string{"1.0.0", "av1"}},
+		{glob: "release-*-amd64", matches: []string{"release-1-amd64"}, excludes: []string{"release-1-arm64", "release-1-amd64-debug"}},
+		{glob: "1.?.0", matches: []string{"1.2.0"}, excludes: []string{"1.12.0", "1x2x0"}},
+		{glob: "v[0-9].*", matches: []string{"v1.0", "v2.x"}, excludes: []string{"va.0", "v10.0"}},
+		{glob: "v[!0-9]*", matches: []string{"vx"}, excludes: []string{"v1"}},
+		{glob: "v[^0-9]*", matches: []string{"vx"}, excludes: []string{"v1"}},
+		{glob: `v\*`, matches: []string{"v*"}, excludes: []string{"v1"}},
+		{glob: "a+b(c)", matches: []string{"a+b(c)"}, excludes: []string{"aab(c)", "abc"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.glob, func(t *testing.T) {
+			g := NewWithT(t)
+
+			pattern, err := globToRegexp(tt.glob)
+			g.Expect(err).ToNot(HaveOccurred())
+			re, err := regexp.Compile(pattern)
+			g.Expect(err).ToNot(HaveOccurred())
+			for _, tag := range tt.matches {
+				g.Expect(re.MatchString(tag)).To(BeTrue(), "expected %q to match %q", tt.glob, tag)
+			}
+			for _, tag := range tt.excludes {
+				g.Expect(re.MatchString(tag)).To(BeFalse(), "expected %q not to match %q", tt.glob, tag)
+			}
+		})
+	}
+
+	for _, glob := range []string{"v[0-9", "v[]", "v[!]", `v\`} {
+		if _, err := globToRegexp(glob); err == nil {
+			t.Errorf("expected an error for glob %q", glob)
+		}
+	}
+}
+
+// benchmarkTags returns n tags, half of them matching `^v\d+\.\d+\.\d+$`.
+func benchmarkTags(n int) []string {
+	tags := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			tags = append(tags, fmt.Sprintf("v%d.%d.%d", i/1000, i/100%10, i%100))
+		} else {
+			tags = append(tags, fmt.Sprintf("main-%07x-%d", i, i))
+		}
+	}
+	return tags
+}
+
+func BenchmarkRegexFilter(b *testing.B) {
+	tags := benchmarkTags(50000)
+	for _, bb := range []struct {
+		name    string
+		pattern string
+		replace string
+	}{
+		{name: "match", pattern: `^v\d+\.\d+\.\d+$`},
+		{name: "extract", pattern: `^v(?P<version>\d+\.\d+\.\d+)$`, replace: "$version"},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			f, err := NewRegexFilter(bb.pattern, bb.replace)
+			if err != nil {
+				b.Fatalf("failed to create filter: %s", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f.Apply(tags)
+				if items := f.Items(); len(items) != len(tags)/2 {
+					b.Fatalf("expected %d items, got %d", len(tags)/2, len(items))
+				}
+			}
+		})
+	}
+}
@@ -18,7 +18,10 @@ package policy
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -28,14 +31,36 @@ const (
 	NumericalOrderDesc = "DESC"
 )
 
+const (
+	// NumericalBaseDecimal parses the tags as decimal numbers, including
+	// floating point numbers
+	NumericalBaseDecimal = 10
+	// NumericalBaseHexadecimal parses the tags as hexadecimal integers
+	NumericalBaseHexadecimal = 16
+)
+
 // Numerical representes a Numerical ordering policy
 type Numerical struct {
 	Order string
+	Base  int
+
+	// TrimPrefix and TrimSuffix are removed from each version before it is
+	// parsed. Versions without them are parsed as-is, and the versions are
+	// always returned untrimmed.
+	TrimPrefix string
+	TrimSuffix string
+
+	// CreatedAsTiebreak orders the versions with the same value by their
+	// Created time, the most recent first, when the times are set.
+	CreatedAsTiebreak bool
+	// Created is the time at which each version was first seen. Versions
+	// without a time are ordered as the oldest.
+	Created map[string]time.Time
 }
 
 // NewNumerical constructs a Numerical object validating the provided
-// order argument
-func NewNumerical(order string) (*Numerical, error) {
+// order and base arguments
+func NewNumerical(order string, base int) (*Numerical, error) {
 	switch order {
 	case "":
 		order = NumericalOrderAsc
@@ -45,23 +70,50 @@ func NewNumerical(order string) (*Numerical, error) {
 		return nil, fmt.Errorf("invalid order argument provided: '%s', must be one of: %s, %s", order, NumericalOrderAsc, NumericalOrderDesc)
 	}
 
+	switch base {
+	case 0:
+		base = NumericalBaseDecimal
+	case NumericalBaseDecimal, NumericalBaseHexadecimal:
+		break
+	default:
+		return nil, fmt.Errorf("invalid base argument provided: '%d', must be one of: %d, %d", base, NumericalBaseDecimal, NumericalBaseHexadecimal)
+	}
+
 	return &Numerical{
 		Order: order,
+		Base:  base,
 	}, nil
 }
 
+// parse returns the numerical value of the version in the base of the policy.
+func (p *Numerical) parse(version string) (float64, error) {
+	version = strings.TrimSuffix(strings.TrimPrefix(version, p.TrimPrefix), p.TrimSuffix)
+	if p.Base == NumericalBaseHexadecimal {
+		v, err := strconv.ParseInt(version, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse invalid hexadecimal value '%s'", version)
+		}
+		return float64(v), nil
+	}
+	v, err := strconv.ParseFloat(version, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse invalid numeric value '%s'", version)
+	}
+	return v, nil
+}
+
 // Latest returns latest version from a provided list of strings
 func (p *Numerical) Latest(versions []string) (string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return "", ErrNoTags
 	}
 
 	var latest string
 	var pv float64
 	for i, version := range versions {
-		cv, err := strconv.ParseFloat(version, 64)
+		cv, err := p.parse(version)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse invalid numeric value '%s'", version)
+			return "", err
 		}
 
 		switch {
@@ -69,6 +121,8 @@ func (p *Numerical) Latest(versions []string) (string, error) {
 			// First iteration, nothing to compare
 		case p.Order == NumericalOrderAsc && cv < pv, p.Order == NumericalOrderDesc && cv > pv:
 			continue
+		case cv == pv && p.Created != nil && !p.Created[version].After(p.Created[latest]):
+			continue
 		}
 
 		latest = version
@@ -77,3 +131,66 @@ func (p *Numerical) Latest(versions []string) (string, error) {
 
 	return latest, nil
 }
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest
+func (p *Numerical) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(versions))
+	sorted := make([]string, len(versions))
+	for i, version := range versions {
+		cv, err := p.parse(version)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = cv
+		sorted[i] = version
+	}
+
+	var created []time.Time
+	if p.Created != nil {
+		created = make([]time.Time, len(sorted))
+		for i, version := range sorted {
+			created[i] = p.Created[version]
+		}
+	}
+
+	sort.Stable(numericalSort{versions: sorted, values: values, created: created, desc: p.Order == NumericalOrderDesc})
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n], nil
+}
+
+// numericalSort sorts versions by their numerical values, placing the latest
+// first. The versions with the same value are ordered by their created times,
+// if any, the most recent first.
+type numericalSort struct {
+	versions []string
+	values   []float64
+	created  []time.Time
+	desc     bool
+}
+
+func (s numericalSort) Len() int { return len(s.versions) }
+
+func (s numericalSort) Less(i, j int) bool {
+	if s.created != nil && s.values[i] == s.values[j] {
+		return s.created[i].After(s.created[j])
+	}
+	if s.desc {
+		return s.values[i] < s.values[j]
+	}
+	return s.values[i] > s.values[j]
+}
+
+func (s numericalSort) Swap(i, j int) {
+	s.versions[i], s.versions[j] = s.versions[j], s.versions[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	if s.created != nil {
+		s.created[i], s.created[j] = s.created[j], s.created[i]
+	}
+}
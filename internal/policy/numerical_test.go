@@ -18,13 +18,16 @@ package policy
 
 import (
 	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewNumerical(t *testing.T) {
 	cases := []struct {
 		label     string
 		order     string
+		base      int
 		expectErr bool
 	}{
 		{
@@ -44,11 +47,24 @@ func TestNewNumerical(t *testing.T) {
 			order:     "invalid",
 			expectErr: true,
 		},
+		{
+			label: "With valid decimal base",
+			base:  NumericalBaseDecimal,
+		},
+		{
+			label: "With valid hexadecimal base",
+			base:  NumericalBaseHexadecimal,
+		},
+		{
+			label:     "With invalid base",
+			base:      8,
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.label, func(t *testing.T) {
-			_, err := NewNumerical(tt.order)
+			_, err := NewNumerical(tt.order, tt.base)
 			if tt.expectErr && err == nil {
 				t.Fatalf("expecting error, got nil")
 			}
@@ -63,6 +79,9 @@ func TestNumerical_Latest(t *testing.T) {
 	cases := []struct {
 		label           string
 		order           string
+		base            int
+		trimPrefix      string
+		trimSuffix      string
 		versions        []string
 		expectedVersion string
 		expectErr       bool
@@ -116,6 +135,57 @@ func TestNumerical_Latest(t *testing.T) {
 			versions:  []string{"0", "1a", "b"},
 			expectErr: true,
 		},
+		{
+			label:           "With hexadecimal values ascending",
+			base:            NumericalBaseHexadecimal,
+			versions:        shuffle([]string{"1a", "ff", "0c", "100", "9"}),
+			expectedVersion: "100",
+		},
+		{
+			label:           "With hexadecimal values descending",
+			base:            NumericalBaseHexadecimal,
+			order:           NumericalOrderDesc,
+			versions:        shuffle([]string{"1a", "ff", "0c", "100", "9"}),
+			expectedVersion: "9",
+		},
+		{
+			label:     "With invalid hexadecimal value",
+			base:      NumericalBaseHexadecimal,
+			versions:  []string{"1a", "1g"},
+			expectErr: true,
+		},
+		{
+			label:           "With trimmed prefix",
+			trimPrefix:      "build-",
+			versions:        shuffle([]string{"build-9", "build-1234", "build-87", "100"}),
+			expectedVersion: "build-1234",
+		},
+		{
+			label:           "With trimmed prefix and untrimmed latest",
+			trimPrefix:      "build-",
+			versions:        shuffle([]string{"build-9", "build-1234", "build-87", "2000"}),
+			expectedVersion: "2000",
+		},
+		{
+			label:           "With trimmed suffix",
+			trimSuffix:      "-amd64",
+			versions:        shuffle([]string{"3-amd64", "12-amd64", "7-amd64"}),
+			expectedVersion: "12-amd64",
+		},
+		{
+			label:           "With trimmed prefix and suffix in hexadecimal",
+			base:            NumericalBaseHexadecimal,
+			trimPrefix:      "g",
+			trimSuffix:      "-dirty",
+			versions:        shuffle([]string{"g1a-dirty", "gff", "0c-dirty"}),
+			expectedVersion: "gff",
+		},
+		{
+			label:      "With trimmed prefix and invalid value",
+			trimPrefix: "build-",
+			versions:   []string{"build-1", "release-2"},
+			expectErr:  true,
+		},
 		{
 			label:     "Empty version list",
 			versions:  []string{},
@@ -125,10 +195,12 @@ func TestNumerical_Latest(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.label, func(t *testing.T) {
-			policy, err := NewNumerical(tt.order)
+			policy, err := NewNumerical(tt.order, tt.base)
 			if err != nil {
 				t.Fatalf("returned unexpected error: %s", err)
 			}
+			policy.TrimPrefix = tt.trimPrefix
+			policy.TrimSuffix = tt.trimSuffix
 			latest, err := policy.Latest(tt.versions)
 			if tt.expectErr && err == nil {
 				t.Fatalf("expecting error, got nil")
@@ -144,6 +216,118 @@ func TestNumerical_Latest(t *testing.T) {
 	}
 }
 
+func TestNumerical_LatestN(t *testing.T) {
+	cases := []struct {
+		label            string
+		order            string
+		base             int
+		versions         []string
+		n                int
+		expectedVersions []string
+		expectErr        bool
+	}{
+		{
+			label:            "With ascending order",
+			versions:         []string{"15", "-62", "73", "2.5", "16"},
+			n:                3,
+			expectedVersions: []string{"73", "16", "15"},
+		},
+		{
+			label:            "With descending order",
+			versions:         []string{"15", "-62", "73", "2.5", "16"},
+			order:            NumericalOrderDesc,
+			n:                2,
+			expectedVersions: []string{"-62", "2.5"},
+		},
+		{
+			label:            "With n larger than the list",
+			versions:         []string{"1", "2"},
+			n:                5,
+			expectedVersions: []string{"2", "1"},
+		},
+		{
+			label:     "With invalid numerical value",
+			versions:  []string{"0", "1a", "b"},
+			n:         1,
+			expectErr: true,
+		},
+		{
+			label:     "With zero n",
+			versions:  []string{"1"},
+			n:         0,
+			expectErr: true,
+		},
+		{
+			label:     "Empty version list",
+			versions:  []string{},
+			n:         1,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewNumerical(tt.order, tt.base)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.LatestN(tt.versions, tt.n)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
+
+func TestNumerical_createdAsTiebreak(t *testing.T) {
+	now := time.Now()
+	created := map[string]time.Time{
+		"1":   now.Add(-2 * time.Hour),
+		"1.0": now,
+		"01":  now.Add(-time.Hour),
+		"0":   now.Add(time.Hour),
+	}
+
+	for _, order := range []string{NumericalOrderAsc, NumericalOrderDesc} {
+		policy, err := NewNumerical(order, NumericalBaseDecimal)
+		if err != nil {
+			t.Fatalf("returned unexpected error: %s", err)
+		}
+		policy.CreatedAsTiebreak = true
+		policy.Created = created
+
+		// The versions with the same value are ordered by their created
+		// times, the most recent first, whatever their order in the list.
+		want := []string{"1.0", "01", "1", "0"}
+		if order == NumericalOrderDesc {
+			want = []string{"0", "1.0", "01", "1"}
+		}
+		for _, versions := range [][]string{{"1", "1.0", "01", "0"}, {"0", "01", "1.0", "1"}} {
+			latest, err := policy.Latest(versions)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if latest != want[0] {
+				t.Errorf("incorrect computed version returned for order %s, got '%s', expected '%s'", order, latest, want[0])
+			}
+			ordered, err := policy.LatestN(versions, len(versions))
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ordered, want) {
+				t.Errorf("incorrect computed versions returned for order %s, got '%v', expected '%v'", order, ordered, want)
+			}
+		}
+	}
+}
+
 func shuffle(list []string) []string {
 	rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
 	return list
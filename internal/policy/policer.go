@@ -16,7 +16,23 @@ limitations under the License.
 
 package policy
 
+import "fmt"
+
 // Policer is an interface representing a policy implementation type
 type Policer interface {
 	Latest([]string) (string, error)
+	// LatestN returns up to n versions from the provided list of strings,
+	// ordered from the latest.
+	LatestN([]string, int) ([]string, error)
+}
+
+// validateLatestN checks the arguments of a LatestN call.
+func validateLatestN(versions []string, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("number of versions must be greater than zero, got %d", n)
+	}
+	if len(versions) == 0 {
+		return ErrNoTags
+	}
+	return nil
 }
@@ -17,49 +17,231 @@ limitations under the License.
 package policy
 
 import (
+	"cmp"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/fluxcd/pkg/version"
 )
 
+const (
+	// SemVerOrderAsc ascending order, selects the lowest matching version
+	SemVerOrderAsc = "ASC"
+	// SemVerOrderDesc descending order, selects the highest matching version
+	SemVerOrderDesc = "DESC"
+)
+
+// prereleaseRangeRegexp matches a semver range referencing a prerelease
+// version, e.g. `>=1.2.0-0` or `^1.x-rc`.
+var prereleaseRangeRegexp = regexp.MustCompile(`[0-9xX*]-[0-9A-Za-z]`)
+
 // SemVer representes a SemVer policy
 type SemVer struct {
-	Range string
+	Ranges            []string
+	Order             string
+	IncludePrerelease bool
+	// LockMajor restricts the versions to the major version of the current
+	// tag given to Anchor.
+	LockMajor bool
+	// BuildMetadataAsTiebreak orders the versions that are equal by their
+	// build metadata, which is otherwise ignored.
+	BuildMetadataAsTiebreak bool
 
-	constraint *semver.Constraints
+	constraints []*semver.Constraints
+	// prereleaseRanges records which of the constraints reference a
+	// prerelease version.
+	prereleaseRanges []bool
+	// lockedMajor is the major version the versions are restricted to, when
+	// the major version is locked and anchored.
+	lockedMajor *uint64
 }
 
-// NewSemVer constructs a SemVer object validating the provided semver constraint
-func NewSemVer(r string) (*SemVer, error) {
-	constraint, err := semver.NewConstraint(r)
-	if err != nil {
-		return nil, err
+// NewSemVer constructs a SemVer object validating the provided semver
+// constraint and order argument
+func NewSemVer(r string, order string, includePrerelease bool) (*SemVer, error) {
+	return NewSemVerRanges([]string{r}, order, includePrerelease)
+}
+
+// NewSemVerRanges constructs a SemVer object validating the provided semver
+// constraints and order argument. A version is matched if it satisfies any of
+// the constraints. Unless includePrerelease is set, prerelease versions are
+// only matched by the constraints that reference a prerelease version.
+func NewSemVerRanges(ranges []string, order string, includePrerelease bool) (*SemVer, error) {
+	switch order {
+	case "":
+		order = SemVerOrderDesc
+	case SemVerOrderAsc, SemVerOrderDesc:
+		break
+	default:
+		return nil, fmt.Errorf("invalid order argument provided: '%s', must be one of: %s, %s", order, SemVerOrderAsc, SemVerOrderDesc)
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("at least one semver range must be provided")
+	}
+
+	constraints := make([]*semver.Constraints, 0, len(ranges))
+	prereleaseRanges := make([]bool, 0, len(ranges))
+	for _, r := range ranges {
+		constraint, err := semver.NewConstraint(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w '%s': %w", ErrInvalidRange, r, err)
+		}
+		constraints = append(constraints, constraint)
+		prereleaseRanges = append(prereleaseRanges, prereleaseRangeRegexp.MatchString(r))
 	}
 
 	return &SemVer{
-		Range:      r,
-		constraint: constraint,
+		Ranges:            ranges,
+		Order:             order,
+		IncludePrerelease: includePrerelease,
+		constraints:       constraints,
+		prereleaseRanges:  prereleaseRanges,
 	}, nil
 }
 
+// Anchor restricts the versions to the major version of the current tag when
+// LockMajor is set. Without a current tag, or with one that isn't a semantic
+// version, the versions aren't restricted.
+func (p *SemVer) Anchor(current string) {
+	p.lockedMajor = nil
+	if !p.LockMajor || current == "" {
+		return
+	}
+	if v, err := version.ParseVersion(current); err == nil {
+		major := v.Major()
+		p.lockedMajor = &major
+	}
+}
+
 // Latest returns latest version from a provided list of strings
 func (p *SemVer) Latest(versions []string) (string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return "", ErrNoTags
 	}
 
 	var latestVersion *semver.Version
 	for _, tag := range versions {
 		if v, err := version.ParseVersion(tag); err == nil {
-			if p.constraint.Check(v) && (latestVersion == nil || v.GreaterThan(latestVersion)) {
+			if p.check(v) && (latestVersion == nil || p.isAfter(v, latestVersion)) {
 				latestVersion = v
 			}
 		}
 	}
-
 	if latestVersion != nil {
 		return latestVersion.Original(), nil
 	}
-	return "", fmt.Errorf("unable to determine latest version from provided list")
+	return "", fmt.Errorf("%w: unable to determine latest version from provided list", ErrNoMatchingTag)
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest
+func (p *SemVer) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	var matching []*semver.Version
+	for _, tag := range versions {
+		if v, err := version.ParseVersion(tag); err == nil && p.check(v) {
+			matching = append(matching, v)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w: unable to determine latest version from provided list", ErrNoMatchingTag)
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return p.isAfter(matching[i], matching[j])
+	})
+	if n > len(matching) {
+		n = len(matching)
+	}
+	result := make([]string, n)
+	for i := range result {
+		result[i] = matching[i].Original()
+	}
+	return result, nil
+}
+
+// check reports whether v satisfies any of the constraints of the policy,
+// within the locked major version if any.
+// Prerelease versions are only matched by the constraints that reference a
+// prerelease version, unless the policy includes prereleases, in which case a
+// prerelease version is matched when the version it is a prerelease of is.
+func (p *SemVer) check(v *semver.Version) bool {
+	if p.lockedMajor != nil && v.Major() != *p.lockedMajor {
+		return false
+	}
+	for i, c := range p.constraints {
+		if v.Prerelease() != "" && !p.prereleaseRanges[i] {
+			if !p.IncludePrerelease {
+				continue
+			}
+			if rv, err := v.SetPrerelease(""); err == nil && c.Check(&rv) {
+				return true
+			}
+			continue
+		}
+		if c.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAfter reports whether v should be selected over current according to the
+// order of the policy.
+func (p *SemVer) isAfter(v, current *semver.Version) bool {
+	c := v.Compare(current)
+	if c == 0 && p.BuildMetadataAsTiebreak {
+		c = compareMetadata(v.Metadata(), current.Metadata())
+	}
+	if p.Order == SemVerOrderAsc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// compareMetadata compares two build metadata strings the way semver compares
+// prerelease versions: identifier by identifier, numerically when both are
+// numeric and lexically otherwise, a longer list of identifiers being greater
+// when the shorter one is a prefix. No metadata is lower than any metadata.
+func compareMetadata(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareIdentifier(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// compareIdentifier compares two build metadata identifiers, numerically when
+// both are numeric, in which case they're lower than the other identifiers.
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
 }
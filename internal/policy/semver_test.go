@@ -17,6 +17,7 @@ limitations under the License.
 package policy
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -40,7 +41,7 @@ func TestNewSemVer(t *testing.T) {
 	for _, tt := range cases {
 		for _, r := range tt.semverRanges {
 			t.Run(tt.label, func(t *testing.T) {
-				_, err := NewSemVer(r)
+				_, err := NewSemVer(r, "", false)
 				if tt.expectErr && err == nil {
 					t.Fatalf("expecting error, got nil for range value: '%s'", r)
 				}
@@ -52,13 +53,148 @@ func TestNewSemVer(t *testing.T) {
 	}
 }
 
-func TestSemVer_Latest(t *testing.T) {
+func TestNewSemVer_Order(t *testing.T) {
+	cases := []struct {
+		label     string
+		order     string
+		expectErr bool
+	}{
+		{
+			label: "With valid empty order",
+			order: "",
+		},
+		{
+			label: "With valid asc order",
+			order: SemVerOrderAsc,
+		},
+		{
+			label: "With valid desc order",
+			order: SemVerOrderDesc,
+		},
+		{
+			label:     "With invalid order",
+			order:     "invalid",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			p, err := NewSemVer("1.0.x", tt.order, false)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if tt.order == "" && p.Order != SemVerOrderDesc {
+				t.Errorf("expected default order %s, got %s", SemVerOrderDesc, p.Order)
+			}
+		})
+	}
+}
+
+func TestNewSemVerRanges(t *testing.T) {
+	cases := []struct {
+		label     string
+		ranges    []string
+		expectErr bool
+	}{
+		{
+			label:  "With valid ranges",
+			ranges: []string{"1.4.x", "2.1.x"},
+		},
+		{
+			label:     "With an invalid entry",
+			ranges:    []string{"1.4.x", "1x"},
+			expectErr: true,
+		},
+		{
+			label:     "With no ranges",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewSemVerRanges(tt.ranges, "", false)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSemVerRanges_Latest(t *testing.T) {
 	cases := []struct {
 		label           string
-		semverRange     string
+		ranges          []string
+		order           string
 		versions        []string
 		expectedVersion string
 		expectErr       bool
+	}{
+		{
+			label:           "With highest in the second range",
+			ranges:          []string{"1.4.x", "2.1.x"},
+			versions:        []string{"1.4.0", "1.4.3", "1.5.0", "2.0.0", "2.1.1", "2.2.0"},
+			expectedVersion: "2.1.1",
+		},
+		{
+			label:           "With highest in the first range",
+			ranges:          []string{"2.1.x", "1.4.x"},
+			versions:        []string{"1.4.0", "1.4.3", "1.5.0", "2.0.0", "2.2.0"},
+			expectedVersion: "1.4.3",
+		},
+		{
+			label:           "With ascending order",
+			ranges:          []string{"2.1.x", "1.4.x"},
+			order:           SemVerOrderAsc,
+			versions:        []string{"1.3.0", "1.4.2", "1.4.3", "2.1.0"},
+			expectedVersion: "1.4.2",
+		},
+		{
+			label:     "With no version matching any range",
+			ranges:    []string{"1.4.x", "2.1.x"},
+			versions:  []string{"1.5.0", "2.0.0"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVerRanges(tt.ranges, tt.order, false)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			latest, err := policy.Latest(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSemVer_Latest(t *testing.T) {
+	cases := []struct {
+		label             string
+		semverRange       string
+		order             string
+		includePrerelease bool
+		versions          []string
+		expectedVersion   string
+		expectErr         bool
 	}{
 		{
 			label:           "With valid format",
@@ -78,6 +214,65 @@ func TestSemVer_Latest(t *testing.T) {
 			semverRange: "1.0.x",
 			expectErr:   true,
 		},
+		{
+			label:           "With ascending order",
+			versions:        []string{"1.0.0", "1.0.0.1", "1.0.0p", "1.0.1", "1.2.0", "0.1.0"},
+			semverRange:     "1.0.x",
+			order:           SemVerOrderAsc,
+			expectedVersion: "1.0.0",
+		},
+		{
+			label:           "With descending order",
+			versions:        []string{"1.0.2", "1.0.1", "1.2.0", "0.1.0"},
+			semverRange:     ">=1.0.0",
+			order:           SemVerOrderDesc,
+			expectedVersion: "1.2.0",
+		},
+		{
+			label:           "With mixed stable and prerelease versions",
+			versions:        []string{"1.1.0", "1.2.0-rc.1", "1.2.0-rc.2", "1.1.1"},
+			semverRange:     ">=1.0.0",
+			expectedVersion: "1.1.1",
+		},
+		{
+			label:             "With mixed stable and prerelease versions, including prereleases",
+			versions:          []string{"1.1.0", "1.2.0-rc.1", "1.2.0-rc.2", "1.1.1"},
+			semverRange:       ">=1.0.0",
+			includePrerelease: true,
+			expectedVersion:   "1.2.0-rc.2",
+		},
+		{
+			label:             "With prerelease outside of the range, including prereleases",
+			versions:          []string{"1.1.0", "1.2.0-rc.1", "1.1.1"},
+			semverRange:       "1.1.x",
+			includePrerelease: true,
+			expectedVersion:   "1.1.1",
+		},
+		{
+			label:             "With prerelease within the range, including prereleases",
+			versions:          []string{"1.1.0", "1.1.2-rc.1", "1.1.1"},
+			semverRange:       "1.1.x",
+			includePrerelease: true,
+			expectedVersion:   "1.1.2-rc.1",
+		},
+		{
+			label:           "With mixed stable and prerelease versions, prerelease range",
+			versions:        []string{"1.1.0", "1.2.0-rc.1", "1.2.0-rc.2", "1.1.1"},
+			semverRange:     ">=1.2.0-0",
+			expectedVersion: "1.2.0-rc.2",
+		},
+		{
+			label:           "With prerelease versions only, prerelease range",
+			versions:        []string{"1.2.0-alpha", "1.2.0-beta"},
+			semverRange:     "^1.x-0",
+			expectedVersion: "1.2.0-beta",
+		},
+		{
+			label:       "With prerelease versions only",
+			versions:    []string{"1.2.0-alpha", "1.2.0-beta"},
+			semverRange: ">=1.0.0",
+			expectErr:   true,
+		},
 		{
 			label:       "With empty list",
 			versions:    []string{},
@@ -94,7 +289,7 @@ func TestSemVer_Latest(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.label, func(t *testing.T) {
-			policy, err := NewSemVer(tt.semverRange)
+			policy, err := NewSemVer(tt.semverRange, tt.order, tt.includePrerelease)
 			if err != nil {
 				t.Fatalf("returned unexpected error: %s", err)
 			}
@@ -113,3 +308,212 @@ func TestSemVer_Latest(t *testing.T) {
 		})
 	}
 }
+
+func TestSemVer_LatestN(t *testing.T) {
+	cases := []struct {
+		label            string
+		order            string
+		semverRange      string
+		versions         []string
+		n                int
+		expectedVersions []string
+		expectErr        bool
+	}{
+		{
+			label:            "With descending order",
+			semverRange:      ">=1.0.0",
+			versions:         []string{"1.0.0", "v1.2.0", "0.1.0", "1.1.0", "2.0.0-rc.1"},
+			n:                2,
+			expectedVersions: []string{"v1.2.0", "1.1.0"},
+		},
+		{
+			label:            "With ascending order",
+			semverRange:      ">=1.0.0",
+			order:            SemVerOrderAsc,
+			versions:         []string{"1.0.0", "v1.2.0", "0.1.0", "1.1.0"},
+			n:                2,
+			expectedVersions: []string{"1.0.0", "1.1.0"},
+		},
+		{
+			label:            "With n larger than the matching versions",
+			semverRange:      "1.x",
+			versions:         []string{"1.0.0", "1.2.0", "2.0.0", "foo"},
+			n:                5,
+			expectedVersions: []string{"1.2.0", "1.0.0"},
+		},
+		{
+			label:       "With no matching versions",
+			semverRange: "3.x",
+			versions:    []string{"1.0.0", "2.0.0"},
+			n:           1,
+			expectErr:   true,
+		},
+		{
+			label:       "With zero n",
+			semverRange: "1.x",
+			versions:    []string{"1.0.0"},
+			n:           0,
+			expectErr:   true,
+		},
+		{
+			label:       "Empty version list",
+			semverRange: "1.x",
+			versions:    []string{},
+			n:           1,
+			expectErr:   true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVer(tt.semverRange, tt.order, false)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.LatestN(tt.versions, tt.n)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
+
+func TestSemVer_LockMajor(t *testing.T) {
+	versions := []string{"1.0.0", "1.4.2", "v1.5.0", "2.0.0", "2.1.0", "latest"}
+
+	cases := []struct {
+		label           string
+		lockMajor       bool
+		current         string
+		expectedVersion string
+		expectErr       bool
+	}{
+		{
+			label:           "First run without a current tag",
+			lockMajor:       true,
+			expectedVersion: "2.1.0",
+		},
+		{
+			label:           "Locked to the current major version",
+			lockMajor:       true,
+			current:         "1.4.2",
+			expectedVersion: "v1.5.0",
+		},
+		{
+			label:           "Current tag with a v prefix",
+			lockMajor:       true,
+			current:         "v1.5.0",
+			expectedVersion: "v1.5.0",
+		},
+		{
+			label:           "Current tag that isn't a version",
+			lockMajor:       true,
+			current:         "latest",
+			expectedVersion: "2.1.0",
+		},
+		{
+			label:           "Not locked",
+			current:         "1.4.2",
+			expectedVersion: "2.1.0",
+		},
+		{
+			label:     "No version within the locked major version",
+			lockMajor: true,
+			current:   "3.0.0",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVer(">=1.0.0", "", false)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.LockMajor = tt.lockMajor
+			policy.Anchor(tt.current)
+			latest, err := policy.Latest(versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSemVer_BuildMetadataAsTiebreak(t *testing.T) {
+	cases := []struct {
+		label            string
+		tiebreak         bool
+		order            string
+		versions         []string
+		expectedVersions []string
+	}{
+		{
+			label:            "Without tiebreak, the first of the equal versions is kept",
+			versions:         []string{"1.2.3+20240101", "1.2.3+20240102", "1.2.2+20240103"},
+			expectedVersions: []string{"1.2.3+20240101", "1.2.3+20240102", "1.2.2+20240103"},
+		},
+		{
+			label:            "With numeric metadata",
+			tiebreak:         true,
+			versions:         []string{"1.2.3+20240101", "1.2.3+20240102", "1.2.2+20240103"},
+			expectedVersions: []string{"1.2.3+20240102", "1.2.3+20240101", "1.2.2+20240103"},
+		},
+		{
+			label:            "With numeric identifiers compared numerically",
+			tiebreak:         true,
+			versions:         []string{"1.2.3+build.9", "1.2.3+build.10", "1.2.3"},
+			expectedVersions: []string{"1.2.3+build.10", "1.2.3+build.9", "1.2.3"},
+		},
+		{
+			label:            "With alphanumeric metadata compared lexically",
+			tiebreak:         true,
+			versions:         []string{"1.2.3+abc", "1.2.3+abd", "1.2.3+123"},
+			expectedVersions: []string{"1.2.3+abd", "1.2.3+abc", "1.2.3+123"},
+		},
+		{
+			label:            "With ascending order",
+			tiebreak:         true,
+			order:            SemVerOrderAsc,
+			versions:         []string{"1.2.3+20240102", "1.2.3+20240101", "1.2.4"},
+			expectedVersions: []string{"1.2.3+20240101", "1.2.3+20240102", "1.2.4"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVer("1.x", tt.order, false)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.BuildMetadataAsTiebreak = tt.tiebreak
+			latest, err := policy.Latest(tt.versions)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if latest != tt.expectedVersions[0] {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersions[0])
+			}
+			ordered, err := policy.LatestN(tt.versions, len(tt.versions))
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ordered, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", ordered, tt.expectedVersions)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Size represents a policy selecting the smallest image, from the total size
+// of the compressed layers of the image of each tag.
+type Size struct {
+	// Sizes are the sizes of the images of the tags, in bytes. The tags
+	// without a size are ignored.
+	Sizes map[string]int64
+	// Created are the times at which the tags were first seen, ordering the
+	// tags of the same size from the most recent.
+	Created map[string]time.Time
+}
+
+// NewSize constructs a Size object.
+func NewSize() *Size {
+	return &Size{}
+}
+
+// Latest returns the version of the smallest image from a provided list of
+// strings. The versions without a size are ignored.
+func (p *Size) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the smallest image. The versions of the same size are ordered from the
+// most recently seen, then by name in descending order. The versions without
+// a size are ignored.
+func (p *Size) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	var sized []string
+	for _, version := range versions {
+		if _, ok := p.Sizes[version]; ok {
+			sized = append(sized, version)
+		}
+	}
+	if len(sized) == 0 {
+		return nil, fmt.Errorf("%w: none of the provided versions has a recorded size", ErrNoMatchingTag)
+	}
+
+	sort.SliceStable(sized, func(i, j int) bool {
+		si, sj := p.Sizes[sized[i]], p.Sizes[sized[j]]
+		if si != sj {
+			return si < sj
+		}
+		ti, tj := p.Created[sized[i]], p.Created[sized[j]]
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return sized[i] > sized[j]
+	})
+	if n > len(sized) {
+		n = len(sized)
+	}
+	return sized[:n], nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSize_LatestN(t *testing.T) {
+	now := time.Now()
+	sizes := map[string]int64{
+		"1.0.0":        300,
+		"1.0.0-alpine": 100,
+		"1.1.0":        250,
+		"1.1.0-alpine": 100,
+		"1.2.0-slim":   100,
+	}
+	created := map[string]time.Time{
+		"1.0.0-alpine": now.Add(-2 * time.Hour),
+		"1.1.0-alpine": now.Add(-time.Hour),
+	}
+
+	cases := []struct {
+		label            string
+		versions         []string
+		n                int
+		expectedVersions []string
+		expectErr        error
+	}{
+		{
+			label:            "With the smallest image",
+			versions:         []string{"1.0.0", "1.1.0", "1.0.0-alpine"},
+			n:                1,
+			expectedVersions: []string{"1.0.0-alpine"},
+		},
+		{
+			label:            "With ties broken by the most recent, then by name",
+			versions:         []string{"1.0.0-alpine", "1.2.0-slim", "1.1.0-alpine", "1.1.0"},
+			n:                4,
+			expectedVersions: []string{"1.1.0-alpine", "1.0.0-alpine", "1.2.0-slim", "1.1.0"},
+		},
+		{
+			label:            "With versions without a size",
+			versions:         []string{"1.0.0", "2.0.0"},
+			n:                2,
+			expectedVersions: []string{"1.0.0"},
+		},
+		{
+			label:     "Without any size",
+			versions:  []string{"2.0.0", "2.1.0"},
+			n:         1,
+			expectErr: ErrNoMatchingTag,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy := NewSize()
+			policy.Sizes = sizes
+			policy.Created = created
+			latest, err := policy.LatestN(tt.versions, tt.n)
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expecting error %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got %v, expected %v", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
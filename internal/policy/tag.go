@@ -0,0 +1,59 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Tag represents a policy selecting a single, typically mutable, tag. There
+// is no ordering of the tags, the tag is selected as long as it's present.
+type Tag struct {
+	Name string
+}
+
+// NewTag constructs a Tag object validating the provided tag name.
+func NewTag(name string) (*Tag, error) {
+	if name == "" {
+		return nil, errors.New("the tag name of a tag policy must not be empty")
+	}
+	return &Tag{Name: name}, nil
+}
+
+// Latest returns the tag of the policy if it's in the provided list of
+// strings
+func (p *Tag) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns the tag of the policy if it's in the provided list of
+// strings. There is never more than one version.
+func (p *Tag) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(versions, p.Name) {
+		return nil, fmt.Errorf("%w: the tag '%s' is not in the provided list", ErrNoMatchingTag, p.Name)
+	}
+	return []string{p.Name}, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewTag(t *testing.T) {
+	if _, err := NewTag(""); err == nil {
+		t.Fatalf("expecting error, got nil")
+	}
+	if _, err := NewTag("main"); err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+}
+
+func TestTag_LatestN(t *testing.T) {
+	versions := []string{"1.0.0", "main", "main-abc123", "latest"}
+
+	cases := []struct {
+		label            string
+		name             string
+		n                int
+		expectedVersions []string
+		expectErr        error
+	}{
+		{
+			label:            "With present tag",
+			name:             "main",
+			n:                3,
+			expectedVersions: []string{"main"},
+		},
+		{
+			label:     "With missing tag",
+			name:      "develop",
+			n:         1,
+			expectErr: ErrNoMatchingTag,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewTag(tt.name)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.LatestN(versions, tt.n)
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expecting error %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(latest, tt.expectedVersions) {
+				t.Errorf("incorrect computed versions returned, got %v, expected %v", latest, tt.expectedVersions)
+			}
+		})
+	}
+}
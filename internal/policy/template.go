@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// TemplateOrderAsc ascending order, selects the highest version
+	TemplateOrderAsc = "ASC"
+	// TemplateOrderDesc descending order, selects the lowest version
+	TemplateOrderDesc = "DESC"
+)
+
+const (
+	// TemplateSegmentInt is the type of a segment compared numerically
+	TemplateSegmentInt = "int"
+	// TemplateSegmentString is the type of a segment compared alphabetically
+	TemplateSegmentString = "string"
+)
+
+// templateSegmentRegexp matches a segment of a template, e.g. `{major}` or
+// `{build:string}`.
+var templateSegmentRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(?::([a-z]+))?\}`)
+
+// Template represents an ordering policy based on the segments parsed from
+// the tags with a format template, e.g. `{major}.{minor}-{build:string}`.
+// The segments are compared in the order they appear in the template,
+// numerically for `int` segments, the default, and alphabetically for
+// `string` segments.
+type Template struct {
+	Format string
+	Order  string
+
+	regexp *regexp.Regexp
+	// segmentTypes records the type of each segment, in order.
+	segmentTypes []string
+}
+
+// NewTemplate constructs a Template object validating the provided format
+// template and order argument
+func NewTemplate(format string, order string) (*Template, error) {
+	switch order {
+	case "":
+		order = TemplateOrderAsc
+	case TemplateOrderAsc, TemplateOrderDesc:
+		break
+	default:
+		return nil, fmt.Errorf("invalid order argument provided: '%s', must be one of: %s, %s", order, TemplateOrderAsc, TemplateOrderDesc)
+	}
+
+	matches := templateSegmentRegexp.FindAllStringSubmatchIndex(format, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("invalid format template '%s': at least one segment must be provided", format)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	var segmentTypes []string
+	names := map[string]bool{}
+	last := 0
+	for _, m := range matches {
+		expr.WriteString(regexp.QuoteMeta(format[last:m[0]]))
+		last = m[1]
+
+		name := format[m[2]:m[3]]
+		if names[name] {
+			return nil, fmt.Errorf("invalid format template '%s': duplicate segment '%s'", format, name)
+		}
+		names[name] = true
+
+		typ := TemplateSegmentInt
+		if m[4] != -1 {
+			typ = format[m[4]:m[5]]
+		}
+		switch typ {
+		case TemplateSegmentInt:
+			expr.WriteString(`([0-9]+)`)
+		case TemplateSegmentString:
+			expr.WriteString(`(.+?)`)
+		default:
+			return nil, fmt.Errorf("invalid format template '%s': unknown type '%s' of segment '%s', must be one of: %s, %s",
+				format, typ, name, TemplateSegmentInt, TemplateSegmentString)
+		}
+		segmentTypes = append(segmentTypes, typ)
+	}
+	expr.WriteString(regexp.QuoteMeta(format[last:]))
+	expr.WriteString("$")
+
+	return &Template{
+		Format:       format,
+		Order:        order,
+		regexp:       regexp.MustCompile(expr.String()),
+		segmentTypes: segmentTypes,
+	}, nil
+}
+
+// Latest returns latest version from a provided list of strings. The versions
+// that don't match the format template are ignored.
+func (p *Template) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest. The versions that don't match the format template are
+// ignored.
+func (p *Template) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	type parsedVersion struct {
+		original string
+		segments []string
+	}
+	var parsed []parsedVersion
+	for _, version := range versions {
+		m := p.regexp.FindStringSubmatch(version)
+		if m == nil {
+			continue
+		}
+		parsed = append(parsed, parsedVersion{original: version, segments: m[1:]})
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: unable to parse any version from provided list with format template '%s'", ErrNoMatchingTag, p.Format)
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		c := p.compare(parsed[i].segments, parsed[j].segments)
+		if p.Order == TemplateOrderDesc {
+			return c < 0
+		}
+		return c > 0
+	})
+	if n > len(parsed) {
+		n = len(parsed)
+	}
+	result := make([]string, n)
+	for i := range result {
+		result[i] = parsed[i].original
+	}
+	return result, nil
+}
+
+// compare compares the segments of two versions in order, returning -1, 0 or
+// +1 when a is lower, equal or higher than b.
+func (p *Template) compare(a, b []string) int {
+	for i, typ := range p.segmentTypes {
+		var c int
+		if typ == TemplateSegmentInt {
+			c = compareNumeric(a[i], b[i])
+		} else {
+			c = strings.Compare(a[i], b[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareNumeric compares two strings of decimal digits by their numerical
+// values, without limiting their size.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
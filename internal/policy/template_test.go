@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTemplate(t *testing.T) {
+	cases := []struct {
+		label     string
+		format    string
+		order     string
+		expectErr bool
+	}{
+		{
+			label:  "With valid format and empty order",
+			format: "{major}.{minor}.{build:int}",
+		},
+		{
+			label:  "With valid format and desc order",
+			format: "{year}.{month}-{channel:string}",
+			order:  TemplateOrderDesc,
+		},
+		{
+			label:     "With invalid order",
+			format:    "{major}",
+			order:     "invalid",
+			expectErr: true,
+		},
+		{
+			label:     "Without segments",
+			format:    "1.0.0",
+			expectErr: true,
+		},
+		{
+			label:     "With unknown segment type",
+			format:    "{major:float}",
+			expectErr: true,
+		},
+		{
+			label:     "With duplicate segment",
+			format:    "{major}.{major}",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewTemplate(tt.format, tt.order)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestTemplate_Latest(t *testing.T) {
+	cases := []struct {
+		label           string
+		format          string
+		order           string
+		versions        []string
+		expectedVersion string
+		expectErr       bool
+	}{
+		{
+			label:           "With int segments",
+			format:          "{major}.{minor}.{build:int}",
+			versions:        shuffle([]string{"1.9.100", "1.10.2", "1.10.10", "1.2.300"}),
+			expectedVersion: "1.10.10",
+		},
+		{
+			label:           "With int segments descending",
+			format:          "{major}.{minor}.{build:int}",
+			order:           TemplateOrderDesc,
+			versions:        shuffle([]string{"1.9.100", "1.10.2", "1.10.10", "1.2.300"}),
+			expectedVersion: "1.2.300",
+		},
+		{
+			label:           "With calendar versions and leading zeros",
+			format:          "{year}.{month}.{patch}",
+			versions:        shuffle([]string{"2023.12.1", "2024.01.0", "2024.02.3", "2024.02.10"}),
+			expectedVersion: "2024.02.10",
+		},
+		{
+			label:           "With string segment",
+			format:          "r{build}-{channel:string}",
+			versions:        shuffle([]string{"r10-alpha", "r10-beta", "r9-stable", "r2-zeta"}),
+			expectedVersion: "r10-beta",
+		},
+		{
+			label:           "With non matching versions",
+			format:          "v{major}.{minor}",
+			versions:        shuffle([]string{"v1.2", "latest", "v1.10-rc", "1.11", "v1.9"}),
+			expectedVersion: "v1.9",
+		},
+		{
+			label:     "With no matching version",
+			format:    "v{major}",
+			versions:  []string{"latest", "main"},
+			expectErr: true,
+		},
+		{
+			label:     "Empty version list",
+			format:    "{major}",
+			versions:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewTemplate(tt.format, tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			latest, err := policy.Latest(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestTemplate_LatestN(t *testing.T) {
+	policy, err := NewTemplate("{major}.{minor}-{channel:string}", "")
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	latest, err := policy.LatestN([]string{"1.2-b", "1.10-a", "1.2-a", "1.9-z", "foo"}, 3)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	expected := []string{"1.10-a", "1.9-z", "1.2-b"}
+	if !reflect.DeepEqual(latest, expected) {
+		t.Errorf("incorrect computed versions returned, got '%v', expected '%v'", latest, expected)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tiered represents a policy ranking the versions in tiers before ordering
+// them with another policy. The latest versions are selected from the most
+// preferred tier with a version selected by the policy, falling back to the
+// next tiers.
+type Tiered struct {
+	Policer Policer
+	// Tiers are the glob patterns of the versions of each tier, from the
+	// most preferred. A version is in the first tier with a matching pattern.
+	// A tier without patterns holds the versions matching none of the other
+	// tiers, which are otherwise ranked last.
+	Tiers [][]string
+
+	tiers []*regexp.Regexp
+}
+
+// NewTiered constructs a Tiered object wrapping the given policy, validating
+// the provided tiers.
+func NewTiered(policer Policer, tiers [][]string) (*Tiered, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("at least one tier must be set")
+	}
+	p := &Tiered{Policer: policer, Tiers: tiers}
+	rest := false
+	for i, globs := range tiers {
+		if len(globs) == 0 {
+			if rest {
+				return nil, errors.New("only one tier can be without globs")
+			}
+			rest = true
+			p.tiers = append(p.tiers, nil)
+			continue
+		}
+		var patterns []string
+		for _, glob := range globs {
+			pattern, err := globToRegexp(glob)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tier %d: %w", i, err)
+			}
+			patterns = append(patterns, "(?:"+pattern+")")
+		}
+		re, err := regexp.Compile(strings.Join(patterns, "|"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %d: %w", i, err)
+		}
+		p.tiers = append(p.tiers, re)
+	}
+	return p, nil
+}
+
+// Latest returns the latest version of the most preferred tier from a
+// provided list of strings.
+func (p *Tiered) Latest(versions []string) (string, error) {
+	latest, err := p.LatestN(versions, 1)
+	if err != nil {
+		return "", err
+	}
+	return latest[0], nil
+}
+
+// LatestN returns up to n versions from a provided list of strings, ordered
+// from the latest of the most preferred tier. A tier whose versions can't be
+// selected by the policy is skipped.
+func (p *Tiered) LatestN(versions []string, n int) ([]string, error) {
+	if err := validateLatestN(versions, n); err != nil {
+		return nil, err
+	}
+
+	var latest []string
+	var lastErr error
+	for _, tier := range p.split(versions) {
+		if len(tier) == 0 {
+			continue
+		}
+		ordered, err := p.Policer.LatestN(tier, n-len(latest))
+		if errors.Is(err, ErrNoMatchingTag) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		latest = append(latest, ordered...)
+		if len(latest) == n {
+			break
+		}
+	}
+	if len(latest) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%w: none of the tiers has a tag", ErrNoMatchingTag)
+		}
+		return nil, lastErr
+	}
+	return latest, nil
+}
+
+// split returns the versions of each tier, followed by the versions matching
+// none of the tiers when there's no tier without globs to hold them.
+func (p *Tiered) split(versions []string) [][]string {
+	split := make([][]string, len(p.tiers)+1)
+	rest := len(p.tiers)
+	for i, re := range p.tiers {
+		if re == nil {
+			rest = i
+		}
+	}
+	for _, version := range versions {
+		tier := rest
+		for i, re := range p.tiers {
+			if re != nil && re.MatchString(version) {
+				tier = i
+				break
+			}
+		}
+		split[tier] = append(split[tier], version)
+	}
+	return split
+}
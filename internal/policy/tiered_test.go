@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNewTiered(t *testing.T) {
+	semver, err := NewSemVer(">=1.0.0-0", SemVerOrderDesc, false)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		label   string
+		tiers   [][]string
+		wantErr string
+	}{
+		{
+			label: "With tiers",
+			tiers: [][]string{nil, {"*-rc.*"}, {"*-beta.*", "*-alpha.*"}},
+		},
+		{
+			label:   "Without tiers",
+			wantErr: "at least one tier must be set",
+		},
+		{
+			label:   "With several tiers without globs",
+			tiers:   [][]string{nil, {"*-rc.*"}, {}},
+			wantErr: "only one tier can be without globs",
+		},
+		{
+			label:   "With an invalid glob",
+			tiers:   [][]string{{"*-rc.[0-9"}},
+			wantErr: "invalid tier 0",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := NewTiered(semver, tt.tiers)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestTiered_LatestN(t *testing.T) {
+	cases := []struct {
+		label    string
+		tiers    [][]string
+		versions []string
+		n        int
+		expected []string
+		wantErr  error
+	}{
+		{
+			label:    "Stable releases first",
+			tiers:    [][]string{nil, {"*-rc.*"}, {"*-beta.*"}},
+			versions: []string{"1.0.0", "1.1.0-rc.1", "1.1.0-beta.1", "1.0.1"},
+			n:        1,
+			expected: []string{"1.0.1"},
+		},
+		{
+			label:    "Falling back to the release candidates",
+			tiers:    [][]string{nil, {"*-rc.*"}, {"*-beta.*"}},
+			versions: []string{"1.1.0-rc.1", "1.1.0-beta.2", "1.1.0-rc.2"},
+			n:        1,
+			expected: []string{"1.1.0-rc.2"},
+		},
+		{
+			label:    "Falling back to the betas",
+			tiers:    [][]string{nil, {"*-rc.*"}, {"*-beta.*"}},
+			versions: []string{"1.1.0-beta.1", "1.1.0-beta.2"},
+			n:        1,
+			expected: []string{"1.1.0-beta.2"},
+		},
+		{
+			label:    "Ordered by tier then by policy",
+			tiers:    [][]string{nil, {"*-rc.*"}, {"*-beta.*"}},
+			versions: []string{"1.1.0-rc.1", "1.0.0", "1.1.0-beta.1", "1.0.1", "1.1.0-rc.2"},
+			n:        10,
+			expected: []string{"1.0.1", "1.0.0", "1.1.0-rc.2", "1.1.0-rc.1", "1.1.0-beta.1"},
+		},
+		{
+			label:    "Versions matching no tier ranked last",
+			tiers:    [][]string{{"*-lts"}},
+			versions: []string{"2.0.0", "1.9.0-lts", "1.8.0-lts"},
+			n:        3,
+			expected: []string{"1.9.0-lts", "1.8.0-lts", "2.0.0"},
+		},
+		{
+			label:    "Tier without a version within the range skipped",
+			tiers:    [][]string{{"0.*"}, nil},
+			versions: []string{"0.9.0", "1.2.0"},
+			n:        1,
+			expected: []string{"1.2.0"},
+		},
+		{
+			label:    "No version within the range",
+			tiers:    [][]string{nil, {"*-rc.*"}},
+			versions: []string{"0.9.0", "0.9.1-rc.1"},
+			n:        1,
+			wantErr:  ErrNoMatchingTag,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			g := NewWithT(t)
+
+			semver, err := NewSemVer(">=1.0.0-0", SemVerOrderDesc, false)
+			g.Expect(err).ToNot(HaveOccurred())
+			policy, err := NewTiered(semver, tt.tiers)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			latest, err := policy.LatestN(tt.versions, tt.n)
+			if tt.wantErr != nil {
+				g.Expect(errors.Is(err, tt.wantErr)).To(BeTrue(), "unexpected error %v", err)
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(latest).To(Equal(tt.expected))
+
+			if tt.n == 1 {
+				g.Expect(policy.Latest(tt.versions)).To(Equal(tt.expected[0]))
+			}
+		})
+	}
+}
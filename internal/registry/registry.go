@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Canonicalize parses the given image into a container registry repository
+// reference, and returns it along with the canonical name of the repository,
+// which is the key of its tags in the database. If insecure is set to true,
+// then the registry is deemed to be located at an HTTP endpoint. The image
+// must not have a URL scheme, a tag or a digest.
+func Canonicalize(image string, insecure bool) (name.Reference, string, error) {
+	if s := strings.Split(image, "://"); len(s) > 1 {
+		return nil, "", fmt.Errorf(".spec.image value should not start with URL scheme; remove '%s://'", s[0])
+	}
+
+	var opts []name.Option
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+
+	ref, err := name.ParseReference(image, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if digest, ok := ref.(name.Digest); ok {
+		return nil, "", fmt.Errorf(".spec.image value should not contain a digest; remove '@%s'", digest.DigestStr())
+	}
+
+	imageName := strings.TrimPrefix(image, ref.Context().RegistryStr())
+	if s := strings.Split(imageName, ":"); len(s) > 1 {
+		return nil, "", fmt.Errorf(".spec.image value should not contain a tag; remove ':%s'", s[1])
+	}
+
+	return ref, ref.Context().String(), nil
+}
+
+// variablePattern matches the references to variables in an image, e.g.
+// `${REGISTRY_HOST}`.
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandImage returns the image with the references to variables, e.g.
+// `${REGISTRY_HOST}/org/app`, replaced with the values of the variables. It
+// returns an error listing the variables that aren't defined, rather than
+// leaving them out, so that a typo doesn't make the image refer to another
+// registry.
+func ExpandImage(image string, variables map[string]string) (string, error) {
+	if !strings.Contains(image, "$") {
+		return image, nil
+	}
+	if refs := variablePattern.FindAllStringIndex(image, -1); strings.Count(image, "$") != len(refs) {
+		return "", fmt.Errorf(".spec.image value has an invalid variable reference; use '${NAME}'")
+	}
+
+	var undefined []string
+	expanded := variablePattern.ReplaceAllStringFunc(image, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := variables[name]
+		if !ok && !slices.Contains(undefined, name) {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if len(undefined) > 0 {
+		return "", fmt.Errorf(".spec.image value refers to undefined variables: %s", strings.Join(undefined, ", "))
+	}
+	return expanded, nil
+}
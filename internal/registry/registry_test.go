@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		insecure      bool
+		wantErr       string
+		wantRef       string
+		wantCanonical string
+	}{
+		{
+			name:          "simple valid url",
+			image:         "example.com/foo/bar",
+			wantRef:       "example.com/foo/bar",
+			wantCanonical: "example.com/foo/bar",
+		},
+		{
+			name:    "with scheme prefix",
+			image:   "https://example.com/foo/bar",
+			wantErr: ".spec.image value should not start with URL scheme; remove 'https://'",
+		},
+		{
+			name:    "with tag",
+			image:   "example.com/foo/bar:baz",
+			wantErr: ".spec.image value should not contain a tag; remove ':baz'",
+		},
+		{
+			name:    "with digest",
+			image:   "example.com/foo/bar@sha256:" + strings.Repeat("a", 64),
+			wantErr: ".spec.image value should not contain a digest; remove '@sha256:" + strings.Repeat("a", 64) + "'",
+		},
+		{
+			name:    "with tag and digest",
+			image:   "example.com/foo/bar:baz@sha256:" + strings.Repeat("a", 64),
+			wantErr: ".spec.image value should not contain a digest; remove '@sha256:",
+		},
+		{
+			name:          "with host port",
+			image:         "example.com:9999/foo/bar",
+			wantRef:       "example.com:9999/foo/bar",
+			wantCanonical: "example.com:9999/foo/bar",
+		},
+		{
+			name:          "with insecure registry",
+			image:         "example.com/foo/bar",
+			insecure:      true,
+			wantRef:       "example.com/foo/bar",
+			wantCanonical: "example.com/foo/bar",
+		},
+		{
+			name:          "docker hub short name",
+			image:         "foo/bar",
+			wantRef:       "foo/bar",
+			wantCanonical: "index.docker.io/foo/bar",
+		},
+		{
+			name:          "docker hub official image",
+			image:         "alpine",
+			wantRef:       "alpine",
+			wantCanonical: "index.docker.io/library/alpine",
+		},
+		{
+			name:    "invalid reference",
+			image:   "example.com/Foo",
+			wantErr: "could not parse reference",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref, canonical, err := Canonicalize(tt.image, tt.insecure)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(ref.String()).To(Equal(tt.wantRef))
+			g.Expect(canonical).To(Equal(tt.wantCanonical))
+			g.Expect(canonical).To(Equal(ref.Context().String()))
+			if tt.insecure {
+				g.Expect(ref.Context().Registry.Scheme()).To(Equal("http"))
+			}
+		})
+	}
+}
+
+func TestExpandImage(t *testing.T) {
+	variables := map[string]string{
+		"REGISTRY_HOST": "mirror.example.com:5000",
+		"ORG":           "acme",
+	}
+
+	tests := []struct {
+		name    string
+		image   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "without variables",
+			image: "example.com/foo/bar",
+			want:  "example.com/foo/bar",
+		},
+		{
+			name:  "with variables",
+			image: "${REGISTRY_HOST}/${ORG}/app",
+			want:  "mirror.example.com:5000/acme/app",
+		},
+		{
+			name:  "with a variable within a path element",
+			image: "${REGISTRY_HOST}/${ORG}-team/app",
+			want:  "mirror.example.com:5000/acme-team/app",
+		},
+		{
+			name:    "with undefined variables",
+			image:   "${REGISTRY_HOTS}/${ORG}/${APP}/${APP}",
+			wantErr: "undefined variables: REGISTRY_HOTS, APP",
+		},
+		{
+			name:    "with an invalid reference",
+			image:   "$REGISTRY_HOST/acme/app",
+			wantErr: "invalid variable reference",
+		},
+		{
+			name:    "with an unterminated reference",
+			image:   "${REGISTRY_HOST/acme/app",
+			wantErr: "invalid variable reference",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			image, err := ExpandImage(tt.image, variables)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(image).To(Equal(tt.want))
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"testing"
 
@@ -95,6 +96,32 @@ func TransportFromKubeTLSSecret(certSecret *corev1.Secret) (*http.Transport, err
 	return transport, nil
 }
 
+// TransportFromCAFile reads the PEM-encoded CA certificates in the file at
+// the given path and returns a transport that trusts them, in addition to the
+// system certificate pool. An error is returned if the file can't be read or
+// doesn't contain any valid CA certificate.
+func TransportFromCAFile(path string) (*http.Transport, error) {
+	caBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA file: %w", err)
+	}
+	if len(bytes.TrimSpace(caBytes)) == 0 {
+		return nil, fmt.Errorf("CA file '%s' is empty", path)
+	}
+
+	cp, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve system certificate pool: %w", err)
+	}
+	if !cp.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("cannot append certificate from '%s' into certificate pool: invalid CA certificate", path)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: cp}
+	return transport, nil
+}
+
 // tlsClientConfigFromSecret attempts to construct and return a TLS client
 // config from the given Secret. If the Secret does not contain any TLS
 // data, it returns nil.
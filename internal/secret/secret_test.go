@@ -18,7 +18,11 @@ package secret
 
 import (
 	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -109,3 +113,66 @@ func TestExtractAuthForURLs(t *testing.T) {
 		}
 	}
 }
+
+func TestTransportFromCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cases := []struct {
+		label     string
+		path      string
+		expectErr bool
+	}{
+		{
+			label: "With valid CA file",
+			path:  write("ca.crt", caPEM),
+		},
+		{
+			label:     "With missing CA file",
+			path:      filepath.Join(dir, "missing.crt"),
+			expectErr: true,
+		},
+		{
+			label:     "With empty CA file",
+			path:      write("empty.crt", []byte("\n")),
+			expectErr: true,
+		},
+		{
+			label:     "With invalid CA file",
+			path:      write("invalid.crt", []byte("not a certificate")),
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			tr, err := TransportFromCAFile(tt.path)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("expecting error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			// The transport must trust the server certificate.
+			resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+			if err != nil {
+				t.Fatalf("request with CA file transport failed: %s", err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
@@ -19,10 +19,14 @@ package test
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -42,7 +46,7 @@ var convenientTags = map[string][]string{
 // set up a local registry for testing scanning
 func NewRegistryServer() *httptest.Server {
 	logOpt := registry.Logger(log.New(io.Discard, "", log.LstdFlags))
-	regHandler := registry.New(logOpt)
+	regHandler := registry.New(logOpt, registry.WithReferrersSupport(true))
 	srv := httptest.NewServer(&TagListHandler{
 		RegistryHandler: regHandler,
 		Imagetags:       convenientTags,
@@ -105,6 +109,9 @@ func LoadImages(srv *httptest.Server, imageName string, versions []string, optio
 type TagListHandler struct {
 	RegistryHandler http.Handler
 	Imagetags       map[string][]string
+	// PageSize, if set, is the maximum number of tags in a response, the
+	// following ones being linked to with a Link header.
+	PageSize int
 }
 
 type TagListResult struct {
@@ -117,6 +124,9 @@ func (h *TagListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if withoutTagsList := strings.TrimSuffix(r.URL.Path, "/tags/list"); r.Method == "GET" && withoutTagsList != r.URL.Path {
 		repo := strings.TrimPrefix(withoutTagsList, "/v2/")
 		if tags, ok := h.Imagetags[repo]; ok {
+			if h.PageSize > 0 {
+				tags = h.page(w, r, repo, tags)
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
 			result := TagListResult{
@@ -133,18 +143,47 @@ func (h *TagListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// record the fact of a PUT to a tag; the path looks like: /v2/<repo>/manifests/<tag>
+	// record the fact of a PUT or a DELETE of a tag; the path looks like:
+	// /v2/<repo>/manifests/<tag>
 	h.RegistryHandler.ServeHTTP(w, r)
-	if r.Method == "PUT" {
-		pathElements := strings.Split(r.URL.Path, "/")
-		if len(pathElements) == 5 && pathElements[1] == "v2" && pathElements[3] == "manifests" {
-			repo, tag := pathElements[2], pathElements[4]
+	pathElements := strings.Split(r.URL.Path, "/")
+	if len(pathElements) == 5 && pathElements[1] == "v2" && pathElements[3] == "manifests" {
+		repo, tag := pathElements[2], pathElements[4]
+		switch r.Method {
+		case "PUT":
 			println("Recording tag", repo, tag)
 			h.Imagetags[repo] = append(h.Imagetags[repo], tag)
+		case "DELETE":
+			println("Deleting tag", repo, tag)
+			h.Imagetags[repo] = slices.DeleteFunc(h.Imagetags[repo], func(t string) bool { return t == tag })
 		}
 	}
 }
 
+// page returns the page of tags following the `last` query parameter of the
+// request, and sets the Link header to the next page if there's one.
+func (h *TagListHandler) page(w http.ResponseWriter, r *http.Request, repo string, tags []string) []string {
+	start := 0
+	if last := r.URL.Query().Get("last"); last != "" {
+		for i, tag := range tags {
+			if tag == last {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := start + h.PageSize
+	if end >= len(tags) {
+		return tags[start:]
+	}
+	next := url.URL{
+		Path:     "/v2/" + repo + "/tags/list",
+		RawQuery: url.Values{"n": {strconv.Itoa(h.PageSize)}, "last": {tags[end-1]}}.Encode(),
+	}
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	return tags[start:end]
+}
+
 // there's no authentication in go-containerregistry/pkg/registry;
 // this wrapper adds basic auth to a registry handler. NB: the
 // important thing is to be able to test that the credentials get from
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/policy"
+)
+
+// +kubebuilder:webhook:path=/validate-image-toolkit-fluxcd-io-v1beta2-imagepolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=image.toolkit.fluxcd.io,resources=imagepolicies,verbs=create;update,versions=v1beta2,name=vimagepolicy.image.toolkit.fluxcd.io,admissionReviewVersions=v1
+
+// ImagePolicyValidator rejects the ImagePolicies whose repository reference,
+// policy or tag filter can't be used, which would otherwise only be reported
+// as stalled once reconciled. It validates them with the same functions as the reconciler.
+type ImagePolicyValidator struct{}
+
+var _ admission.CustomValidator = &ImagePolicyValidator{}
+
+// SetupWebhookWithManager registers the validating webhook with the manager.
+func (v *ImagePolicyValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&imagev1.ImagePolicy{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *ImagePolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *ImagePolicyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *ImagePolicyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ImagePolicyValidator) validate(obj runtime.Object) error {
+	p, ok := obj.(*imagev1.ImagePolicy)
+	if !ok {
+		return fmt.Errorf("expected an ImagePolicy, got %T", obj)
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	switch {
+	case p.Spec.ImageRepositorySelector == nil && p.Spec.ImageRepositoryRef.Name == "":
+		errs = append(errs, field.Required(specPath.Child("imageRepositoryRef"), "either the imageRepositoryRef name or the imageRepositorySelector must be set"))
+	case p.Spec.ImageRepositorySelector != nil && p.Spec.ImageRepositoryRef.Name != "":
+		errs = append(errs, invalid(specPath.Child("imageRepositorySelector"), "the imageRepositoryRef name and the imageRepositorySelector can't be used together"))
+	case p.Spec.ImageRepositorySelector != nil:
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ImageRepositorySelector); err != nil {
+			errs = append(errs, invalid(specPath.Child("imageRepositorySelector"), err.Error()))
+		}
+	}
+
+	if _, err := policy.PolicerFromSpec(p.Spec.Policy); err != nil {
+		errs = append(errs, invalid(specPath.Child("policy"), err.Error()))
+	}
+
+	if p.Spec.FilterTags != nil {
+		if _, err := policy.RegexFilterFromSpec(*p.Spec.FilterTags); err != nil {
+			errs = append(errs, invalid(specPath.Child("filterTags"), err.Error()))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(imagev1.GroupVersion.WithKind(imagev1.ImagePolicyKind).GroupKind(), p.GetName(), errs)
+}
+
+// invalid returns an invalid field error without the value of the field, as
+// the detail already refers to the invalid part of it.
+func invalid(path *field.Path, detail string) *field.Error {
+	return &field.Error{Type: field.ErrorTypeInvalid, Field: path.String(), BadValue: field.OmitValueType{}, Detail: detail}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/pkg/apis/meta"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+func TestImagePolicyValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    imagev1.ImagePolicySpec
+		wantErr string
+	}{
+		{
+			name: "valid",
+			spec: imagev1.ImagePolicySpec{
+				ImageRepositoryRef: meta.NamespacedObjectReference{Name: "repo"},
+				Policy:             imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+				FilterTags:         &imagev1.TagFilter{Pattern: `^v(?P<v>.*)$`, Extract: "$v"},
+			},
+		},
+		{
+			name: "valid repository selector",
+			spec: imagev1.ImagePolicySpec{
+				ImageRepositorySelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+				Policy:                  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			},
+		},
+		{
+			name: "no repository reference",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			},
+			wantErr: "spec.imageRepositoryRef: Required value: either the imageRepositoryRef name or the imageRepositorySelector must be set",
+		},
+		{
+			name: "repository reference and selector",
+			spec: imagev1.ImagePolicySpec{
+				ImageRepositoryRef:      meta.NamespacedObjectReference{Name: "repo"},
+				ImageRepositorySelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+				Policy:                  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			},
+			wantErr: "spec.imageRepositorySelector: Invalid value: the imageRepositoryRef name and the imageRepositorySelector can't be used together",
+		},
+		{
+			name: "invalid repository selector",
+			spec: imagev1.ImagePolicySpec{
+				ImageRepositorySelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: "Equals"},
+				}},
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.x"}},
+			},
+			wantErr: `spec.imageRepositorySelector: Invalid value: "Equals" is not a valid label selector operator`,
+		},
+		{
+			name:    "no policy",
+			spec:    imagev1.ImagePolicySpec{},
+			wantErr: "spec.policy: Invalid value: given ImagePolicyChoice object is invalid",
+		},
+		{
+			name: "several policies",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{
+					SemVer:       &imagev1.SemVerPolicy{Range: "1.x"},
+					Alphabetical: &imagev1.AlphabeticalPolicy{},
+				},
+			},
+			wantErr: "spec.policy: Invalid value: only one policy may be set, got semver and alphabetical",
+		},
+		{
+			name: "invalid semver range",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "*-*"}},
+			},
+			wantErr: "spec.policy",
+		},
+		{
+			name: "invalid pattern",
+			spec: imagev1.ImagePolicySpec{
+				Policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{Pattern: "[="},
+			},
+			wantErr: "spec.filterTags: Invalid value: invalid regular expression pattern '[='",
+		},
+		{
+			name: "invalid exclude",
+			spec: imagev1.ImagePolicySpec{
+				Policy:     imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				FilterTags: &imagev1.TagFilter{Pattern: "^v", Exclude: "[="},
+			},
+			wantErr: "invalid regular expression exclude pattern '[='",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			obj := &imagev1.ImagePolicy{Spec: tt.spec}
+			obj.Name = "test"
+			v := &ImagePolicyValidator{}
+
+			_, createErr := v.ValidateCreate(context.TODO(), obj)
+			_, updateErr := v.ValidateUpdate(context.TODO(), &imagev1.ImagePolicy{}, obj)
+			if tt.wantErr == "" {
+				g.Expect(createErr).ToNot(HaveOccurred())
+				g.Expect(updateErr).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(apierrors.IsInvalid(createErr)).To(BeTrue())
+			g.Expect(createErr.Error()).To(ContainSubstring(tt.wantErr))
+			g.Expect(updateErr).To(Equal(createErr))
+		})
+	}
+}
+
+func TestImagePolicyValidator_ValidateDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := (&ImagePolicyValidator{}).ValidateDelete(context.TODO(), &imagev1.ImagePolicy{})
+	g.Expect(err).ToNot(HaveOccurred())
+}
@@ -19,9 +19,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"time"
 
-	"github.com/dgraph-io/badger/v3"
 	flag "github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,6 +36,7 @@ import (
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/config"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"github.com/fluxcd/pkg/oci/auth/login"
 	"github.com/fluxcd/pkg/runtime/acl"
@@ -50,9 +53,12 @@ import (
 	// +kubebuilder:scaffold:imports
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/admin"
 	"github.com/fluxcd/image-reflector-controller/internal/controller"
 	"github.com/fluxcd/image-reflector-controller/internal/database"
 	"github.com/fluxcd/image-reflector-controller/internal/features"
+	"github.com/fluxcd/image-reflector-controller/internal/secret"
+	irwebhook "github.com/fluxcd/image-reflector-controller/internal/webhook"
 )
 
 const controllerName = "image-reflector-controller"
@@ -78,23 +84,60 @@ func main() {
 		logOptions              logger.Options
 		leaderElectionOptions   leaderelection.Options
 		watchOptions            helper.WatchOptions
+		storageBackend          string
 		storagePath             string
 		storageValueLogFileSize int64
 		concurrent              int
+		policyConcurrent        int
+		imageHistoryLimit       int
+		crossNamespaceAllowlist []string
 		awsAutoLogin            bool
 		gcpAutoLogin            bool
 		azureAutoLogin          bool
 		aclOptions              acl.Options
 		rateLimiterOptions      helper.RateLimiterOptions
 		featureGates            feathelper.FeatureGates
+		redactPatterns          []string
+		noInsecureRegistries    bool
+		registryCAFile          string
+		scanJitter              float64
+		scanConcurrency         int
+		webhookPort             int
+		webhookCertDir          string
+		registryProbeInterval   time.Duration
+		registryProbeReadiness  bool
+		defaultScanInterval     time.Duration
+		imageVariables          map[string]string
+		digestRetries           int
+		repoSizeThreshold       int64
+		adminAddr               string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&eventsAddr, "events-addr", "", "The address of the events receiver.")
 	flag.StringVar(&healthAddr, "health-addr", ":9440", "The address the health endpoint binds to.")
+	flag.StringVar(&adminAddr, "admin-addr", "", "The address the admin API exporting and importing the tags database binds to, e.g. 127.0.0.1:9441. The API has no authentication, bind it to a local address. Empty, the default, disables the admin API.")
+	flag.StringVar(&storageBackend, "storage-backend", database.BadgerName,
+		fmt.Sprintf("The database to store the image metadata in, one of %v.", database.Names()))
 	flag.StringVar(&storagePath, "storage-path", "/data", "Where to store the persistent database of image metadata")
 	flag.Int64Var(&storageValueLogFileSize, "storage-value-log-file-size", 1<<28, "Set the database's memory mapped value log file size in bytes. Effective memory usage is about two times this size.")
 	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent resource reconciles.")
+	flag.IntVar(&policyConcurrent, "policy-concurrent", 0, "The number of concurrent ImagePolicy reconciles. Defaults to the value of --concurrent.")
+	flag.IntVar(&imageHistoryLimit, "image-history-limit", 10, "The maximum number of images kept in the .status.imageHistory of ImagePolicies. Zero disables the image history.")
+	flag.StringSliceVar(&crossNamespaceAllowlist, "cross-namespace-refs-allowlist", nil, "Namespaces whose ImageRepositories can be referenced by ImagePolicies in other namespaces even when --no-cross-namespace-refs is set, subject to the .spec.accessFrom of the ImageRepositories.")
+	flag.StringSliceVar(&redactPatterns, "redact-patterns", nil, "Additional regular expressions whose matches are redacted from the messages of conditions and events, along with the known credential patterns.")
+	flag.BoolVar(&noInsecureRegistries, "no-insecure-registries", false, "Disallow scanning registries over plain HTTP, even for ImageRepositories with .spec.insecure set.")
+	flag.StringVar(&registryCAFile, "registry-ca-file", "", "Path to a file with PEM-encoded CA certificates to trust when connecting to registries, for ImageRepositories without .spec.certSecretRef.")
+	flag.Float64Var(&scanJitter, "scan-jitter", 0, "The maximum percentage of the scan interval of ImageRepositories added to it, to spread the scans of the ones sharing the same interval. The added duration is derived from the namespace and name of each ImageRepository.")
+	flag.IntVar(&scanConcurrency, "scan-concurrency", 4, "The maximum number of tags whose metadata, such as their referrers, is fetched at the same time during the scan of an ImageRepository.")
+	flag.IntVar(&webhookPort, "webhook-port", 0, "The port the ImagePolicy validating webhook server listens on. Zero, the default, disables the webhook.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "", "The directory with the tls.crt and tls.key files of the webhook server. Defaults to <temp-dir>/k8s-webhook-server/serving-certs.")
+	flag.DurationVar(&registryProbeInterval, "registry-probe-interval", 0, "The interval at which the /v2/ endpoint of the registries of the ImageRepositories is probed, recording whether they are reachable in the image_registry_reachable metric. Zero, the default, disables the probes.")
+	flag.BoolVar(&registryProbeReadiness, "registry-probe-readiness", false, "Report the controller as not ready while some registries are unreachable by the probes. Requires --registry-probe-interval.")
+	flag.DurationVar(&defaultScanInterval, "default-scan-interval", 0, "The scan interval of the ImageRepositories without .spec.interval. Zero, the default, leaves them without an interval, scanning them only when they change.")
+	flag.IntVar(&digestRetries, "digest-retries", 2, "The number of times resolving the digest of the latest image of an ImagePolicy is retried after a transient registry failure, with an exponential backoff bounded by the scan timeout of the ImageRepository. Zero disables the retries.")
+	flag.Int64Var(&repoSizeThreshold, "storage-repo-size-threshold", 0, "The number of bytes the tags of an ImageRepository can take in the database before it's marked with the StorageThresholdExceeded condition. Zero, the default, disables the condition.")
+	flag.StringToStringVar(&imageVariables, "image-variables", nil, "Variables referenced in the .spec.image of ImageRepositories as ${NAME}, given as NAME=value pairs, e.g. REGISTRY_HOST=mirror.example.com.")
 
 	// NOTE: Deprecated flags.
 	flag.BoolVar(&awsAutoLogin, "aws-autologin-for-ecr", false, "(AWS) Attempt to get credentials for images in Elastic Container Registry, when no secret is referenced")
@@ -124,15 +167,70 @@ func main() {
 		os.Exit(1)
 	}
 
-	badgerOpts := badger.DefaultOptions(storagePath)
-	badgerOpts.ValueLogFileSize = storageValueLogFileSize
-	badgerDB, err := badger.Open(badgerOpts)
+	var redactRegexps []*regexp.Regexp
+	for _, p := range redactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			setupLog.Error(err, "invalid redact pattern", "pattern", p)
+			os.Exit(1)
+		}
+		redactRegexps = append(redactRegexps, re)
+	}
+
+	if scanJitter < 0 || scanJitter > 100 {
+		setupLog.Error(fmt.Errorf("invalid scan jitter %v, must be between 0 and 100", scanJitter), "unable to set the scan jitter")
+		os.Exit(1)
+	}
+
+	if defaultScanInterval < 0 {
+		setupLog.Error(fmt.Errorf("invalid default scan interval %s, must not be negative", defaultScanInterval), "unable to set the default scan interval")
+		os.Exit(1)
+	}
+
+	if digestRetries < 0 {
+		setupLog.Error(fmt.Errorf("invalid digest retries %d, must not be negative", digestRetries), "unable to set the digest retries")
+		os.Exit(1)
+	}
+
+	if repoSizeThreshold < 0 {
+		setupLog.Error(fmt.Errorf("invalid repository size threshold %d, must not be negative", repoSizeThreshold), "unable to set the repository size threshold")
+		os.Exit(1)
+	}
+
+	if registryProbeReadiness && registryProbeInterval <= 0 {
+		setupLog.Error(errors.New("--registry-probe-readiness requires --registry-probe-interval"), "unable to set up the registry probes")
+		os.Exit(1)
+	}
+
+	if scanConcurrency < 1 {
+		setupLog.Error(fmt.Errorf("invalid scan concurrency %d, must be at least 1", scanConcurrency), "unable to set the scan concurrency")
+		os.Exit(1)
+	}
+
+	var registryTransport *http.Transport
+	if registryCAFile != "" {
+		tr, err := secret.TransportFromCAFile(registryCAFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load the registry CA file", "path", registryCAFile)
+			os.Exit(1)
+		}
+		registryTransport = tr
+	}
+
+	db, err := database.Open(storageBackend, database.Options{
+		StoragePath:      storagePath,
+		ValueLogFileSize: storageValueLogFileSize,
+	})
 	if err != nil {
-		setupLog.Error(err, "unable to open the Badger database")
+		setupLog.Error(err, "unable to open the database", "backend", storageBackend)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if err := controller.RegisterDatabaseMetrics(db); err != nil {
+		setupLog.Error(err, "unable to register the database metrics")
 		os.Exit(1)
 	}
-	defer badgerDB.Close()
-	db := database.NewBadgerDatabase(badgerDB)
 
 	watchNamespace := ""
 	if !watchOptions.AllNamespaces {
@@ -193,6 +291,13 @@ func main() {
 		},
 	}
 
+	if webhookPort > 0 {
+		mgrConfig.WebhookServer = webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		})
+	}
+
 	if watchNamespace != "" {
 		mgrConfig.Cache.DefaultNamespaces = map[string]ctrlcache.Config{
 			watchNamespace: ctrlcache.Config{},
@@ -215,7 +320,7 @@ func main() {
 
 	metricsH := helper.NewMetrics(mgr, metrics.MustMakeRecorder(), imagev1.ImageFinalizer)
 
-	if err := (&controller.ImageRepositoryReconciler{
+	imageRepositoryReconciler := &controller.ImageRepositoryReconciler{
 		Client:         mgr.GetClient(),
 		EventRecorder:  eventRecorder,
 		Metrics:        metricsH,
@@ -226,25 +331,74 @@ func main() {
 			AzureAutoLogin: azureAutoLogin,
 			GcpAutoLogin:   gcpAutoLogin,
 		},
-	}).SetupWithManager(mgr, controller.ImageRepositoryReconcilerOptions{
+		RedactPatterns:       redactRegexps,
+		NoInsecureRegistries: noInsecureRegistries,
+		DefaultTransport:     registryTransport,
+		ScanJitter:           scanJitter / 100,
+		ScanConcurrency:      scanConcurrency,
+		DefaultScanInterval:  defaultScanInterval,
+		ImageVariables:       imageVariables,
+		DigestRetries:        digestRetries,
+		RepoSizeThreshold:    repoSizeThreshold,
+	}
+	if err := imageRepositoryReconciler.SetupWithManager(mgr, controller.ImageRepositoryReconcilerOptions{
 		RateLimiter: helper.GetRateLimiter(rateLimiterOptions),
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", imagev1.ImageRepositoryKind)
 		os.Exit(1)
 	}
 	if err := (&controller.ImagePolicyReconciler{
-		Client:         mgr.GetClient(),
-		EventRecorder:  eventRecorder,
-		Metrics:        metricsH,
-		Database:       db,
-		ACLOptions:     aclOptions,
-		ControllerName: controllerName,
+		Client:                      mgr.GetClient(),
+		EventRecorder:               eventRecorder,
+		Metrics:                     metricsH,
+		Database:                    db,
+		ACLOptions:                  aclOptions,
+		ControllerName:              controllerName,
+		ResolveDigest:               imageRepositoryReconciler.ResolveDigest,
+		ImageHistoryLimit:           imageHistoryLimit,
+		CrossNamespaceRefsAllowlist: crossNamespaceAllowlist,
+		DefaultScanInterval:         defaultScanInterval,
+		ImageVariables:              imageVariables,
+		RedactPatterns:              redactRegexps,
 	}).SetupWithManager(mgr, controller.ImagePolicyReconcilerOptions{
-		RateLimiter: helper.GetRateLimiter(rateLimiterOptions),
+		RateLimiter:             helper.GetRateLimiter(rateLimiterOptions),
+		MaxConcurrentReconciles: policyConcurrent,
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", imagev1.ImagePolicyKind)
 		os.Exit(1)
 	}
+	if webhookPort > 0 {
+		if err := (&irwebhook.ImagePolicyValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", imagev1.ImagePolicyKind)
+			os.Exit(1)
+		}
+	}
+	if registryProbeInterval > 0 {
+		prober := &controller.RegistryProber{
+			Client:         mgr.GetClient(),
+			Interval:       registryProbeInterval,
+			ImageVariables: imageVariables,
+		}
+		if registryTransport != nil {
+			prober.Transport = registryTransport
+		}
+		if err := mgr.Add(prober); err != nil {
+			setupLog.Error(err, "unable to set up the registry probes")
+			os.Exit(1)
+		}
+		if registryProbeReadiness {
+			if err := mgr.AddReadyzCheck("registries", prober.Check); err != nil {
+				setupLog.Error(err, "unable to set up the registry readiness check")
+				os.Exit(1)
+			}
+		}
+	}
+	if adminAddr != "" {
+		if err := mgr.Add(&admin.Server{Addr: adminAddr, Database: db}); err != nil {
+			setupLog.Error(err, "unable to set up the admin API")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")